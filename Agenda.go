@@ -0,0 +1,428 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+const (
+	agendaSlotPresentation = "presentation"
+	agendaSlotBreak        = "break"
+	agendaSlotNetworking   = "networking"
+)
+
+const (
+	maxSlotMinutes         = 8 * 60
+	defaultTalkMinutes     = 30
+	defaultBreakMinutes    = 15
+	defaultBreakSlotTitle  = "Break"
+	networkingSlotTitle    = "Networking"
+	agendaDescriptionTitle = "Agenda:"
+)
+
+type AgendaSlot = api.AgendaSlot
+
+type AgendaSlotPublicView = api.AgendaSlotPublicView
+
+// Check the slots of an agenda for the meetup: presentations have to be part of the meetup and appear once,
+// and slots may not overlap. The slots are sorted by their start.
+func validateAgenda(ctx context.Context, slots []AgendaSlot, meetup *Meetup, PresentationStorage PresentationStore) ([]FieldError, error) {
+	fieldErrors := make([]FieldError, 0)
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+
+	scheduled := make([]int64, 0, len(slots))
+	for index, slot := range slots {
+		field := fmt.Sprintf("Agenda[%v]", index)
+		switch {
+		case slot.Start.IsZero():
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".Start", Message: "This field is mandatory."})
+		case !meetup.Date.IsZero() && slot.Start.Before(meetup.Date):
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".Start", Message: "Slots can't start before the meetup."})
+		}
+		if slot.Minutes < 1 || slot.Minutes > maxSlotMinutes {
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".Minutes", Message: fmt.Sprintf("Must be between 1 and %v.", maxSlotMinutes)})
+		}
+
+		switch slot.Kind {
+		case agendaSlotPresentation:
+			if !containsID(meetup.Presentations, slot.PresentationID) {
+				fieldErrors = append(fieldErrors, FieldError{Field: field + ".PresentationID", Message: fmt.Sprintf("Presentation %v isn't part of the meetup.", slot.PresentationID)})
+				break
+			}
+			if containsID(scheduled, slot.PresentationID) {
+				fieldErrors = append(fieldErrors, FieldError{Field: field + ".PresentationID", Message: fmt.Sprintf("Presentation %v is already scheduled.", slot.PresentationID)})
+				break
+			}
+			scheduled = append(scheduled, slot.PresentationID)
+			_, err := PresentationStorage.GetPresentation(ctx, slot.PresentationID)
+			if err == datastore.ErrNoSuchEntity {
+				fieldErrors = append(fieldErrors, FieldError{Field: field + ".PresentationID", Message: fmt.Sprintf("No presentation with ID: %v", slot.PresentationID)})
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+		case agendaSlotBreak, agendaSlotNetworking:
+			if slot.PresentationID != 0 {
+				fieldErrors = append(fieldErrors, FieldError{Field: field + ".PresentationID", Message: "Only presentation slots have a presentation."})
+			}
+		default:
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".Kind", Message: fmt.Sprintf("Must be %v, %v or %v.", agendaSlotPresentation, agendaSlotBreak, agendaSlotNetworking)})
+		}
+
+		if index > 0 && slot.Start.Before(slots[index-1].End()) {
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".Start", Message: fmt.Sprintf("Overlaps with the slot starting at %v.", slots[index-1].Start.Format("15:04"))})
+		}
+	}
+
+	return fieldErrors, nil
+}
+
+// Schedule the presentations one after the other from the start of the meetup, best of the tally in the voting
+// mode of the meetup first. Ties go to the presentation submitted first. With breakEvery set, a break follows
+// every breakEvery talks.
+func autoSchedule(meetup *Meetup, IDs []int64, presentations []Presentation, ballots []Ballot, talkMinutes int, breakEvery int) []AgendaSlot {
+	byID := make(map[int64]Presentation, len(IDs))
+	for index, ID := range IDs {
+		byID[ID] = presentations[index]
+	}
+	scores := make(map[int64]int, len(IDs))
+	for _, result := range tallyVotes(meetup, IDs, byID, ballots).Results {
+		scores[result.PresentationID] = result.Score
+	}
+
+	order := make([]int, 0, len(presentations))
+	for index := range presentations {
+		order = append(order, index)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := presentations[order[i]], presentations[order[j]]
+		if scores[IDs[order[i]]] != scores[IDs[order[j]]] {
+			return scores[IDs[order[i]]] > scores[IDs[order[j]]]
+		}
+		if !a.Submitted.Equal(b.Submitted) {
+			return a.Submitted.Before(b.Submitted)
+		}
+		return IDs[order[i]] < IDs[order[j]]
+	})
+
+	slots := make([]AgendaSlot, 0, len(order))
+	start := meetup.Date
+	for count, index := range order {
+		if breakEvery > 0 && count > 0 && count%breakEvery == 0 {
+			slots = append(slots, AgendaSlot{Start: start, Minutes: defaultBreakMinutes, Kind: agendaSlotBreak, Title: defaultBreakSlotTitle})
+			start = start.Add(defaultBreakMinutes * time.Minute)
+		}
+		slots = append(slots, AgendaSlot{Start: start, Minutes: talkMinutes, Kind: agendaSlotPresentation, PresentationID: IDs[index]})
+		start = start.Add(time.Duration(talkMinutes) * time.Minute)
+	}
+	return slots
+}
+
+// Drop the slots of presentations which aren't part of the meetup anymore.
+func (m *Meetup) pruneAgenda() {
+	remaining := make([]AgendaSlot, 0, len(m.Agenda))
+	for _, slot := range m.Agenda {
+		if slot.Kind != agendaSlotPresentation || containsID(m.Presentations, slot.PresentationID) {
+			remaining = append(remaining, slot)
+		}
+	}
+	m.Agenda = remaining
+}
+
+func getAgendaSlotPublicView(s *AgendaSlot, presentations map[int64]Presentation) AgendaSlotPublicView {
+	view := AgendaSlotPublicView{
+		Start:          s.Start,
+		End:            s.End(),
+		Minutes:        s.Minutes,
+		Kind:           s.Kind,
+		Title:          s.Title,
+		PresentationID: s.PresentationID,
+		Speakers:       []string{},
+	}
+	if presentation, ok := presentations[s.PresentationID]; ok && s.Kind == agendaSlotPresentation {
+		view.Title = presentation.Title
+		view.Speakers = presentation.Speakers
+	}
+	if view.Title == "" && s.Kind == agendaSlotNetworking {
+		view.Title = networkingSlotTitle
+	}
+	if view.Title == "" && s.Kind == agendaSlotBreak {
+		view.Title = defaultBreakSlotTitle
+	}
+	return view
+}
+
+// The description of the meetup with its agenda appended, as published on meetup.com.
+func agendaDescription(meetup *Meetup, presentations map[int64]Presentation) string {
+	if len(meetup.Agenda) == 0 {
+		return meetup.Description
+	}
+
+	description := strings.Builder{}
+	description.WriteString(meetup.Description)
+	description.WriteString("\n\n" + agendaDescriptionTitle + "\n")
+	for _, slot := range meetup.Agenda {
+		view := getAgendaSlotPublicView(&slot, presentations)
+		line := fmt.Sprintf("%v %v", view.Start.In(meetup.Date.Location()).Format("15:04"), view.Title)
+		if len(view.Speakers) > 0 {
+			line += " - " + strings.Join(view.Speakers, ", ")
+		}
+		description.WriteString(line + "\n")
+	}
+	return description.String()
+}
+
+// Get the meetup of the ID route variable, writing the error response if there is none.
+func (h *meetupHandler) getMeetup(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, Meetup, bool) {
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return 0, Meetup{}, false
+	}
+
+	meetup, err := h.MeetupStorage.GetMeetup(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find meetup with id: %v", ID))
+		return 0, Meetup{}, false
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get meetup: %v", err)
+		writeInternalError(w)
+		return 0, Meetup{}, false
+	}
+	return ID, meetup, true
+}
+
+// Check that the user is logged in and owns the meetup, writing the error response if not.
+func checkMeetupOwner(ctx context.Context, w http.ResponseWriter, r *http.Request, ID int64, meetup *Meetup) bool {
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/update_meetup/%v", ID))
+		writeLoginRequired(w, url)
+		return false
+	}
+	if meetup.Owner != u.Email && !u.Admin {
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
+		return false
+	}
+	return true
+}
+
+// Check that the user owns the meetup and replaces the agenda they've seen, writing the error response if not.
+func (h *meetupHandler) checkAgendaOwner(ctx context.Context, w http.ResponseWriter, r *http.Request, ID int64, meetup *Meetup) (Encoder, bool) {
+	if !checkMeetupOwner(ctx, w, r, ID, meetup) {
+		return nil, false
+	}
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return nil, false
+	}
+	presentations, err := h.getAgendaPresentations(ctx, meetup)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentations: %v", err)
+		writeInternalError(w)
+		return nil, false
+	}
+	return encoder, checkIfMatch(w, r, agendaETag(encoder, meetup, presentations))
+}
+
+func (h *meetupHandler) GetAgenda(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	_, meetup, ok := h.getMeetup(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+	presentations, err := h.getAgendaPresentations(ctx, &meetup)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentations: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if checkIfNoneMatch(w, r, agendaETag(encoder, &meetup, presentations)) {
+		return
+	}
+
+	h.writeAgenda(ctx, w, &meetup, presentations, encoder)
+}
+
+func (h *meetupHandler) writeAgenda(ctx context.Context, w http.ResponseWriter, meetup *Meetup, presentations map[int64]Presentation, encoder Encoder) {
+	agenda := make([]AgendaSlotPublicView, 0, len(meetup.Agenda))
+	for _, slot := range meetup.Agenda {
+		agenda = append(agenda, getAgendaSlotPublicView(&slot, presentations))
+	}
+
+	err := encoder.Encode(w, agenda)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write agenda: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func (h *meetupHandler) getAgendaPresentations(ctx context.Context, meetup *Meetup) (map[int64]Presentation, error) {
+	presentations := make(map[int64]Presentation)
+	for _, slot := range meetup.Agenda {
+		if slot.Kind != agendaSlotPresentation {
+			continue
+		}
+		presentation, err := h.PresentationStorage.GetPresentation(ctx, slot.PresentationID)
+		if err == datastore.ErrNoSuchEntity {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		presentations[slot.PresentationID] = presentation
+	}
+	return presentations, nil
+}
+
+// Replace the agenda of the meetup.
+func (h *meetupHandler) SetAgenda(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	ID, meetup, ok := h.getMeetup(ctx, w, r)
+	if !ok {
+		return
+	}
+	encoder, ok := h.checkAgendaOwner(ctx, w, r, ID, &meetup)
+	if !ok {
+		return
+	}
+
+	slots := make([]AgendaSlot, 0)
+	err := json.NewDecoder(r.Body).Decode(&slots)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
+	}
+
+	fieldErrors, err := validateAgenda(ctx, slots, &meetup, h.PresentationStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't validate agenda: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return
+	}
+
+	meetup.Agenda = slots
+	h.putAgenda(ctx, w, ID, &meetup, encoder)
+}
+
+// Replace the agenda of the meetup with all its presentations, most voted first.
+func (h *meetupHandler) AutoSchedule(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	ID, meetup, ok := h.getMeetup(ctx, w, r)
+	if !ok {
+		return
+	}
+	encoder, ok := h.checkAgendaOwner(ctx, w, r, ID, &meetup)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	talkMinutes := defaultTalkMinutes
+	breakEvery := 0
+	var err error
+	if value := query.Get("minutes"); value != "" {
+		talkMinutes, err = strconv.Atoi(value)
+		if err != nil || talkMinutes < 1 || talkMinutes > maxSlotMinutes {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Minutes have to be between 1 and %v: %v", maxSlotMinutes, value))
+			return
+		}
+	}
+	if value := query.Get("breakEvery"); value != "" {
+		breakEvery, err = strconv.Atoi(value)
+		if err != nil || breakEvery < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("BreakEvery not valid: %v", value))
+			return
+		}
+	}
+	if meetup.Date.IsZero() {
+		writeError(w, http.StatusConflict, "The meetup needs a date to be scheduled.")
+		return
+	}
+
+	IDs := make([]int64, 0, len(meetup.Presentations))
+	presentations := make([]Presentation, 0, len(meetup.Presentations))
+	for _, presentationID := range meetup.Presentations {
+		presentation, err := h.PresentationStorage.GetPresentation(ctx, presentationID)
+		if err == datastore.ErrNoSuchEntity {
+			continue
+		}
+		if err != nil {
+			log.Errorf(ctx, "Couldn't get presentation: %v", err)
+			writeInternalError(w)
+			return
+		}
+		IDs = append(IDs, presentationID)
+		presentations = append(presentations, presentation)
+	}
+
+	ballots, err := h.BallotStorage.GetBallots(ctx, ID)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get ballots: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	meetup.Agenda = autoSchedule(&meetup, IDs, presentations, ballots, talkMinutes, breakEvery)
+	h.putAgenda(ctx, w, ID, &meetup, encoder)
+}
+
+// Save the meetup with its new agenda and respond with the agenda.
+func (h *meetupHandler) putAgenda(ctx context.Context, w http.ResponseWriter, ID int64, meetup *Meetup, encoder Encoder) {
+	err := h.MeetupStorage.PutMeetup(ctx, ID, meetup)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Can't put meetup: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	h.Events.Publish(ctx, MeetupUpdated{ID: ID, Meetup: *meetup})
+
+	presentations, err := h.getAgendaPresentations(ctx, meetup)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentations: %v", err)
+		writeInternalError(w)
+		return
+	}
+	w.Header().Set("ETag", agendaETag(encoder, meetup, presentations))
+	h.writeAgenda(ctx, w, meetup, presentations, encoder)
+}
@@ -0,0 +1,101 @@
+package MeetupRest
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestValidateAgenda(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Date(2017, 5, 10, 18, 0, 0, 0, time.UTC)
+
+	first, err := store.AddPresentation(ctx, &Presentation{Title: "First"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := store.AddPresentation(ctx, &Presentation{Title: "Second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := store.AddPresentation(ctx, &Presentation{Title: "Other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meetup := Meetup{Date: start, Presentations: []int64{first, second}}
+
+	cases := []struct {
+		slots  []AgendaSlot
+		fields []string
+	}{
+		{[]AgendaSlot{
+			{Start: start.Add(45 * time.Minute), Minutes: 30, Kind: agendaSlotPresentation, PresentationID: second},
+			{Start: start, Minutes: 30, Kind: agendaSlotPresentation, PresentationID: first},
+			{Start: start.Add(30 * time.Minute), Minutes: 15, Kind: agendaSlotBreak},
+		}, []string{}},
+		{[]AgendaSlot{
+			{Start: start, Minutes: 30, Kind: agendaSlotPresentation, PresentationID: first},
+			{Start: start.Add(20 * time.Minute), Minutes: 30, Kind: agendaSlotNetworking},
+		}, []string{"Agenda[1].Start"}},
+		{[]AgendaSlot{
+			{Start: start, Minutes: 30, Kind: agendaSlotPresentation, PresentationID: first},
+			{Start: start.Add(30 * time.Minute), Minutes: 30, Kind: agendaSlotPresentation, PresentationID: first},
+		}, []string{"Agenda[1].PresentationID"}},
+		{[]AgendaSlot{{Start: start, Minutes: 30, Kind: agendaSlotPresentation, PresentationID: other}}, []string{"Agenda[0].PresentationID"}},
+		{[]AgendaSlot{{Start: start.Add(-time.Hour), Minutes: 0, Kind: "lunch"}}, []string{"Agenda[0].Start", "Agenda[0].Minutes", "Agenda[0].Kind"}},
+	}
+
+	for _, c := range cases {
+		fieldErrors, err := validateAgenda(ctx, c.slots, &meetup, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fieldErrors) != len(c.fields) {
+			t.Errorf("Agenda %+v should fail on %v, got %v", c.slots, c.fields, fieldErrors)
+			continue
+		}
+		for index, fieldError := range fieldErrors {
+			if fieldError.Field != c.fields[index] {
+				t.Errorf("Agenda %+v should fail on %v, got %v", c.slots, c.fields, fieldErrors)
+			}
+		}
+	}
+}
+
+func TestAutoSchedule(t *testing.T) {
+	start := time.Date(2017, 5, 10, 18, 0, 0, 0, time.UTC)
+	meetup := Meetup{Date: start}
+	IDs := []int64{1, 2, 3}
+	presentations := []Presentation{
+		{Title: "Few votes", Voters: []string{"a"}},
+		{Title: "Most votes", Voters: []string{"a", "b"}},
+		{Title: "No votes"},
+	}
+
+	slots := autoSchedule(&meetup, IDs, presentations, nil, 20, 2)
+
+	expected := []AgendaSlot{
+		{Start: start, Minutes: 20, Kind: agendaSlotPresentation, PresentationID: 2},
+		{Start: start.Add(20 * time.Minute), Minutes: 20, Kind: agendaSlotPresentation, PresentationID: 1},
+		{Start: start.Add(40 * time.Minute), Minutes: defaultBreakMinutes, Kind: agendaSlotBreak, Title: defaultBreakSlotTitle},
+		{Start: start.Add(55 * time.Minute), Minutes: 20, Kind: agendaSlotPresentation, PresentationID: 3},
+	}
+	if len(slots) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, slots)
+	}
+	for index := range expected {
+		if slots[index] != expected[index] {
+			t.Errorf("Slot %v should be %+v, got %+v", index, expected[index], slots[index])
+		}
+	}
+	// Budget voting counts the points of the ballots, not the upvotes.
+	meetup.VotingMode = VotingBudget
+	meetup.VotesPerVoter = 5
+	ballots := []Ballot{{Voter: "a", Mode: VotingBudget, Choices: []BallotChoice{{PresentationID: 3, Points: 4}, {PresentationID: 1, Points: 1}}}}
+	slots = autoSchedule(&meetup, IDs, presentations, ballots, 20, 0)
+	if len(slots) != 3 || slots[0].PresentationID != 3 || slots[1].PresentationID != 1 || slots[2].PresentationID != 2 {
+		t.Errorf("Expected the presentations by points, got %+v", slots)
+	}
+}
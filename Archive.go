@@ -0,0 +1,499 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+// Format version of the archive. Bump it when the archive changes, archives of earlier versions can still be imported.
+// Version 2 added the revisions and reviews of the presentations, version 3 the ballots of the meetups.
+const archiveVersion = 3
+
+// Exporting and importing touches everything, so it gets more time than the other requests.
+const archiveRequestTimeout = time.Minute
+
+const (
+	conflictFail      = "fail"
+	conflictSkip      = "skip"
+	conflictOverwrite = "overwrite"
+)
+
+// All the data of the application, keeping the keys so the references stay valid.
+// Deleted entities are part of it, with their Deleted flag set, and so are the blocked voters. The vote events kept to find vote fraud are not.
+type Archive struct {
+	Version       int
+	Exported      time.Time
+	Speakers      []ArchivedSpeaker
+	Presentations []ArchivedPresentation
+	Meetups       []ArchivedMeetup
+	Metadata      map[string]string
+	BlockedVoters []string
+}
+
+type ArchivedSpeaker struct {
+	Key int64
+	Speaker
+}
+
+type ArchivedPresentation struct {
+	Key int64
+	Presentation
+	Revisions []ArchivedRevision
+	Reviews   []Review
+}
+
+type ArchivedRevision struct {
+	Key int64
+	PresentationRevision
+}
+
+type ArchivedMeetup struct {
+	Key int64
+	Meetup
+	Ballots []Ballot
+}
+
+type ImportResult = api.ImportResult
+
+// All the storage an archive is exported from and imported into. GoogleDatastoreStore and MemoryStore implement it.
+type ArchiveStore interface {
+	SpeakerStore
+	PresentationStore
+	PresentationRevisionStore
+	ReviewStore
+	MeetupStore
+	BallotStore
+	VoteEventStore
+	MetadataStore
+	// Keep the IDs of the kind from being assigned to new entities, as they were stored with explicit keys.
+	ReserveIDs(ctx context.Context, kind string, IDs []int64) error
+}
+
+// Register the archive routes of the versioned API to the router.
+func RegisterArchiveAPIRoutes(m *mux.Router, Storage ArchiveStore, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering archive API routes")
+	}
+	h := archiveHandler{Storage: Storage, Events: Events}
+	m.HandleFunc("/export", h.Export).Methods("GET")
+	m.HandleFunc("/import", h.Import).Methods("POST")
+
+	return nil
+}
+
+type archiveHandler struct {
+	Storage ArchiveStore
+	Events  *EventBus
+}
+
+func (h *archiveHandler) Export(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, archiveRequestTimeout)
+	defer done()
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	archive, err := exportArchive(ctx, h.Storage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't export archive: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=meetuprest-%v.json", archive.Exported.Format("2006-01-02")))
+	err = json.NewEncoder(w).Encode(archive)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write archive: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+// Import an archive. The conflict query parameter decides what happens to entities which already exist:
+// fail (the default) refuses the whole import, skip keeps the existing ones and overwrite replaces them.
+func (h *archiveHandler) Import(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, archiveRequestTimeout)
+	defer done()
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	conflict := r.URL.Query().Get("conflict")
+	if conflict == "" {
+		conflict = conflictFail
+	}
+	if conflict != conflictFail && conflict != conflictSkip && conflict != conflictOverwrite {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Conflict mode not valid: %v", conflict))
+		return
+	}
+
+	archive := Archive{}
+	err := json.NewDecoder(r.Body).Decode(&archive)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
+	}
+	if archive.Version < 1 || archive.Version > archiveVersion {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Archive version %v not supported, expected at most %v.", archive.Version, archiveVersion))
+		return
+	}
+
+	result, err := importArchive(ctx, &archive, conflict, h.Storage)
+	if err == errImportConflict {
+		writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+			Message: "Some of the entities already exist. Import with conflict=skip or conflict=overwrite.",
+			Details: result,
+		})
+		return
+	}
+	// Whatever was written before a failure is stored too.
+	h.Events.Publish(ctx, archiveImported(&archive))
+	if err != nil {
+		log.Errorf(ctx, "Couldn't import archive: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write import result: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func (h *archiveHandler) checkAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return false
+	}
+	if !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin.")
+		return false
+	}
+	return true
+}
+
+func exportArchive(ctx context.Context, Storage ArchiveStore) (Archive, error) {
+	archive := Archive{
+		Version:       archiveVersion,
+		Exported:      time.Now(),
+		Speakers:      []ArchivedSpeaker{},
+		Presentations: []ArchivedPresentation{},
+		Meetups:       []ArchivedMeetup{},
+		Metadata:      make(map[string]string),
+	}
+
+	for _, get := range []func(context.Context) ([]int64, []Speaker, error){Storage.GetAllSpeakers, Storage.GetDeletedSpeakers} {
+		IDs, speakers, err := get(ctx)
+		if err != nil {
+			return archive, err
+		}
+		for index, speaker := range speakers {
+			archive.Speakers = append(archive.Speakers, ArchivedSpeaker{Key: IDs[index], Speaker: speaker})
+		}
+	}
+
+	reviewIDs, reviews, err := Storage.GetAllReviews(ctx)
+	if err != nil {
+		return archive, err
+	}
+	presentationReviews := make(map[int64][]Review)
+	for index, review := range reviews {
+		presentationReviews[reviewIDs[index]] = append(presentationReviews[reviewIDs[index]], review)
+	}
+
+	for _, get := range []func(context.Context) ([]int64, []Presentation, error){Storage.GetAllPresentations, Storage.GetDeletedPresentations} {
+		IDs, presentations, err := get(ctx)
+		if err != nil {
+			return archive, err
+		}
+		for index, presentation := range presentations {
+			archived := ArchivedPresentation{Key: IDs[index], Presentation: presentation, Revisions: []ArchivedRevision{}, Reviews: presentationReviews[IDs[index]]}
+			if archived.Reviews == nil {
+				archived.Reviews = []Review{}
+			}
+
+			revisionIDs, revisions, err := Storage.GetPresentationRevisions(ctx, IDs[index])
+			if err != nil {
+				return archive, err
+			}
+			for revisionIndex, revision := range revisions {
+				archived.Revisions = append(archived.Revisions, ArchivedRevision{Key: revisionIDs[revisionIndex], PresentationRevision: revision})
+			}
+			archive.Presentations = append(archive.Presentations, archived)
+		}
+	}
+
+	for _, get := range []func(context.Context) ([]int64, []Meetup, error){Storage.GetAllMeetups, Storage.GetDeletedMeetups} {
+		IDs, meetups, err := get(ctx)
+		if err != nil {
+			return archive, err
+		}
+		for index, meetup := range meetups {
+			ballots, err := Storage.GetBallots(ctx, IDs[index])
+			if err != nil {
+				return archive, err
+			}
+			archive.Meetups = append(archive.Meetups, ArchivedMeetup{Key: IDs[index], Meetup: meetup, Ballots: append([]Ballot{}, ballots...)})
+		}
+	}
+
+	keys, values, err := Storage.GetAllData(ctx)
+	if err != nil {
+		return archive, err
+	}
+	for index, key := range keys {
+		archive.Metadata[key] = values[index]
+	}
+
+	archive.BlockedVoters, err = Storage.GetBlockedVoters(ctx)
+	if err != nil {
+		return archive, err
+	}
+
+	return archive, nil
+}
+
+func archiveImported(archive *Archive) ArchiveImported {
+	event := ArchiveImported{}
+	for _, speaker := range archive.Speakers {
+		event.Speakers = append(event.Speakers, speaker.Key)
+	}
+	for _, presentation := range archive.Presentations {
+		event.Presentations = append(event.Presentations, presentation.Key)
+	}
+	for _, meetup := range archive.Meetups {
+		event.Meetups = append(event.Meetups, meetup.Key)
+	}
+	return event
+}
+
+var errImportConflict = errors.New("entities of the archive already exist")
+
+// Write the archive into the store, keeping the keys. It works with any store implementation, as it only
+// uses the store interfaces. The import isn't atomic, but with the fail mode conflicts are found before
+// anything is written. The revisions and reviews of a presentation and the ballots of a meetup are written along with it.
+func importArchive(ctx context.Context, archive *Archive, conflict string, Storage ArchiveStore) (ImportResult, error) {
+	result := ImportResult{}
+
+	speakerVersions, err := speakerVersions(ctx, Storage)
+	if err != nil {
+		return result, err
+	}
+	presentationVersions, err := presentationVersions(ctx, Storage)
+	if err != nil {
+		return result, err
+	}
+	meetupVersions, err := meetupVersions(ctx, Storage)
+	if err != nil {
+		return result, err
+	}
+	metadataKeys, _, err := Storage.GetAllData(ctx)
+	if err != nil {
+		return result, err
+	}
+	existingMetadata := make(map[string]bool)
+	for _, key := range metadataKeys {
+		existingMetadata[key] = true
+	}
+
+	if conflict == conflictFail {
+		for _, speaker := range archive.Speakers {
+			if _, ok := speakerVersions[speaker.Key]; ok {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("speaker %v", speaker.Key))
+			}
+		}
+		for _, presentation := range archive.Presentations {
+			if _, ok := presentationVersions[presentation.Key]; ok {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("presentation %v", presentation.Key))
+			}
+		}
+		for _, meetup := range archive.Meetups {
+			if _, ok := meetupVersions[meetup.Key]; ok {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("meetup %v", meetup.Key))
+			}
+		}
+		for key := range archive.Metadata {
+			if existingMetadata[key] {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("metadata %v", key))
+			}
+		}
+		if len(result.Conflicts) > 0 {
+			return result, errImportConflict
+		}
+	}
+
+	// So that entities added later don't get the keys of the imported ones.
+	speakerIDs := make([]int64, 0, len(archive.Speakers))
+	for _, archived := range archive.Speakers {
+		speakerIDs = append(speakerIDs, archived.Key)
+	}
+	presentationIDs := make([]int64, 0, len(archive.Presentations))
+	for _, archived := range archive.Presentations {
+		presentationIDs = append(presentationIDs, archived.Key)
+	}
+	meetupIDs := make([]int64, 0, len(archive.Meetups))
+	for _, archived := range archive.Meetups {
+		meetupIDs = append(meetupIDs, archived.Key)
+	}
+	err = firstError(firstError(
+		Storage.ReserveIDs(ctx, datastoreSpeakersKind, speakerIDs),
+		Storage.ReserveIDs(ctx, datastorePresentationsKind, presentationIDs)),
+		Storage.ReserveIDs(ctx, datastoreMeetupsKind, meetupIDs))
+	if err != nil {
+		return result, err
+	}
+
+	// Existing entities are only written when overwriting. Their stored version is used, so the version check passes.
+	count := func(exists bool) bool {
+		switch {
+		case !exists:
+			result.Created++
+		case conflict == conflictOverwrite:
+			result.Overwritten++
+		default:
+			result.Skipped++
+			return false
+		}
+		return true
+	}
+
+	for _, archived := range archive.Speakers {
+		version, exists := speakerVersions[archived.Key]
+		if !count(exists) {
+			continue
+		}
+		speaker := archived.Speaker
+		speaker.Version = version
+		err = Storage.PutSpeaker(ctx, archived.Key, &speaker)
+		if err != nil {
+			return result, fmt.Errorf("speaker %v: %v", archived.Key, err)
+		}
+	}
+
+	for _, archived := range archive.Presentations {
+		version, exists := presentationVersions[archived.Key]
+		if !count(exists) {
+			continue
+		}
+		presentation := archived.Presentation
+		presentation.Version = version
+		err = Storage.PutPresentation(ctx, archived.Key, &presentation)
+		if err != nil {
+			return result, fmt.Errorf("presentation %v: %v", archived.Key, err)
+		}
+
+		for _, revision := range archived.Revisions {
+			err = Storage.PutPresentationRevision(ctx, archived.Key, revision.Key, &revision.PresentationRevision)
+			if err != nil {
+				return result, fmt.Errorf("revision %v of presentation %v: %v", revision.Key, archived.Key, err)
+			}
+		}
+		for _, review := range archived.Reviews {
+			err = Storage.PutReview(ctx, archived.Key, &review)
+			if err != nil {
+				return result, fmt.Errorf("review of presentation %v: %v", archived.Key, err)
+			}
+		}
+	}
+
+	for _, archived := range archive.Meetups {
+		version, exists := meetupVersions[archived.Key]
+		if !count(exists) {
+			continue
+		}
+		meetup := archived.Meetup
+		meetup.Version = version
+		err = Storage.PutMeetup(ctx, archived.Key, &meetup)
+		if err != nil {
+			return result, fmt.Errorf("meetup %v: %v", archived.Key, err)
+		}
+
+		for _, ballot := range archived.Ballots {
+			err = Storage.PutBallot(ctx, archived.Key, &ballot)
+			if err != nil {
+				return result, fmt.Errorf("ballot of meetup %v: %v", archived.Key, err)
+			}
+		}
+	}
+
+	for key, value := range archive.Metadata {
+		if !count(existingMetadata[key]) {
+			continue
+		}
+		err = Storage.PutData(ctx, key, value)
+		if err != nil {
+			return result, fmt.Errorf("metadata %v: %v", key, err)
+		}
+	}
+
+	// Blocking a voter again changes nothing, so blocked voters never conflict.
+	for _, voter := range archive.BlockedVoters {
+		err = Storage.BlockVoter(ctx, voter)
+		if err != nil {
+			return result, fmt.Errorf("blocked voter %v: %v", voter, err)
+		}
+	}
+
+	return result, nil
+}
+
+// Versions of all the stored speakers, including the deleted ones.
+func speakerVersions(ctx context.Context, SpeakerStorage SpeakerStore) (map[int64]int64, error) {
+	versions := make(map[int64]int64)
+	for _, get := range []func(context.Context) ([]int64, []Speaker, error){SpeakerStorage.GetAllSpeakers, SpeakerStorage.GetDeletedSpeakers} {
+		IDs, speakers, err := get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for index, speaker := range speakers {
+			versions[IDs[index]] = speaker.Version
+		}
+	}
+	return versions, nil
+}
+
+func presentationVersions(ctx context.Context, PresentationStorage PresentationStore) (map[int64]int64, error) {
+	versions := make(map[int64]int64)
+	for _, get := range []func(context.Context) ([]int64, []Presentation, error){PresentationStorage.GetAllPresentations, PresentationStorage.GetDeletedPresentations} {
+		IDs, presentations, err := get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for index, presentation := range presentations {
+			versions[IDs[index]] = presentation.Version
+		}
+	}
+	return versions, nil
+}
+
+func meetupVersions(ctx context.Context, MeetupStorage MeetupStore) (map[int64]int64, error) {
+	versions := make(map[int64]int64)
+	for _, get := range []func(context.Context) ([]int64, []Meetup, error){MeetupStorage.GetAllMeetups, MeetupStorage.GetDeletedMeetups} {
+		IDs, meetups, err := get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for index, meetup := range meetups {
+			versions[IDs[index]] = meetup.Version
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,75 @@
+package MeetupRest
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := NewMemoryStore()
+	speakerID, _ := source.AddSpeaker(ctx, &Speaker{Name: "Jan", Surname: "Kowalski"})
+	presentationID, _ := source.AddPresentation(ctx, &Presentation{Title: "Go", Speakers: []string{"Jan Kowalski"}, Voters: []string{"a@example.com"}})
+	source.PutPresentation(ctx, presentationID, &Presentation{Version: 1, Title: "Go 2", Speakers: []string{"Jan Kowalski"}, Voters: []string{"a@example.com"}})
+	source.PutReview(ctx, presentationID, &Review{Reviewer: "reviewer@example.com", Relevance: 5, Clarity: 4, Novelty: 3})
+	meetupID, _ := source.AddMeetup(ctx, &Meetup{Title: "Meetup", VotingMode: VotingRanked, Presentations: []int64{presentationID}})
+	source.PutBallot(ctx, meetupID, &Ballot{Voter: "voter", Mode: VotingRanked, Choices: []BallotChoice{{PresentationID: presentationID, Points: 1}}})
+	source.DeleteSpeaker(ctx, speakerID)
+	source.PutData(ctx, "GroupName", "gophers")
+	source.BlockVoter(ctx, "blocked")
+
+	archive, err := exportArchive(ctx, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := NewMemoryStore()
+	result, err := importArchive(ctx, &archive, conflictFail, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != 4 {
+		t.Errorf("Expected 4 created entities. Received: %+v", result)
+	}
+
+	presentation, err := target.GetPresentation(ctx, presentationID)
+	if err != nil || presentation.Title != "Go 2" || len(presentation.Voters) != 1 {
+		t.Errorf("Expected the presentation with its voters under the same key. Received: %+v, %v", presentation, err)
+	}
+	revisionIDs, revisions, _ := target.GetPresentationRevisions(ctx, presentationID)
+	if len(revisions) != 1 || revisions[0].Title != "Go" {
+		t.Errorf("Expected the revision of the presentation. Received: %+v", revisions)
+	}
+	reviews, _ := target.GetReviews(ctx, presentationID)
+	if len(reviews) != 1 || reviews[0].Reviewer != "reviewer@example.com" || reviews[0].Relevance != 5 {
+		t.Errorf("Expected the review of the presentation. Received: %+v", reviews)
+	}
+	ballots, _ := target.GetBallots(ctx, meetupID)
+	if len(ballots) != 1 || ballots[0].Voter != "voter" || len(ballots[0].Choices) != 1 {
+		t.Errorf("Expected the ballot of the meetup. Received: %+v", ballots)
+	}
+	if blocked, _ := target.IsVoterBlocked(ctx, "blocked"); !blocked {
+		t.Error("Expected the blocked voter to stay blocked")
+	}
+	newID, _ := target.AddSpeaker(ctx, &Speaker{Name: "Anna", Surname: "Nowak"})
+	for _, ID := range append(revisionIDs, speakerID, presentationID) {
+		if newID == ID {
+			t.Errorf("Imported key %v was given to a new speaker", ID)
+		}
+	}
+	_, deleted, _ := target.GetDeletedSpeakers(ctx)
+	if len(deleted) != 1 {
+		t.Errorf("Expected the deleted speaker to stay in the trash. Received: %+v", deleted)
+	}
+
+	result, err = importArchive(ctx, &archive, conflictFail, target)
+	if err != errImportConflict || len(result.Conflicts) != 4 {
+		t.Errorf("Importing again should fail on every entity. Received: %+v, %v", result, err)
+	}
+
+	result, err = importArchive(ctx, &archive, conflictOverwrite, target)
+	if err != nil || result.Overwritten != 4 {
+		t.Errorf("Expected every entity to be overwritten. Received: %+v, %v", result, err)
+	}
+}
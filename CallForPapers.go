@@ -0,0 +1,130 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+
+	"github.com/cube2222/MeetupRest/api"
+)
+
+const (
+	maxCallForPapersQuestions = 20
+	maxAnswerLength           = 5000
+)
+
+type CallForPapers = api.CallForPapers
+
+// Check the call for papers of a meetup form. An end in the past is only accepted if it didn't change.
+func validateCallForPapers(c *CallForPapers, date time.Time, previous *CallForPapers) []FieldError {
+	fieldErrors := make([]FieldError, 0)
+	if c.Start.IsZero() {
+		if !c.End.IsZero() || len(c.Questions) > 0 || c.MaxPerSpeaker != 0 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.Start", Message: "This field is mandatory to open a call for papers."})
+		}
+		return fieldErrors
+	}
+
+	switch {
+	case c.End.IsZero():
+		fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.End", Message: "This field is mandatory."})
+	case !c.End.After(c.Start):
+		fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.End", Message: "The call for papers has to end after it starts."})
+	case c.End.Before(time.Now()) && (previous == nil || !c.End.Equal(previous.End)):
+		fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.End", Message: "Must be in the future."})
+	case !date.IsZero() && c.End.After(date):
+		fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.End", Message: "The call for papers has to end before the meetup starts."})
+	}
+
+	if len(c.Questions) > maxCallForPapersQuestions {
+		fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.Questions", Message: fmt.Sprintf("Must be at most %v questions.", maxCallForPapersQuestions)})
+	}
+	for _, question := range c.Questions {
+		if strings.TrimSpace(question) == "" || len(question) > 500 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.Questions", Message: "Questions may not be empty or longer than 500 characters."})
+			break
+		}
+	}
+	if c.MaxPerSpeaker < 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "CallForPapers.MaxPerSpeaker", Message: "Must be at least 0."})
+	}
+	return fieldErrors
+}
+
+// Check the meetup a presentation is submitted to. The call for papers of the meetup has to be open,
+// all its questions answered, and no speaker may go over the maximum number of submissions.
+// ID is the key of the presentation, 0 for a new one. Presentations which stay in the same meetup with the same answers
+// aren't checked again, so they can still be edited after the call for papers closed.
+func validateSubmission(ctx context.Context, f *PresentationForm, ID int64, previous *Presentation, MeetupStorage MeetupStore, PresentationStorage PresentationStore) ([]FieldError, error) {
+	fieldErrors := make([]FieldError, 0)
+	if f.MeetupID == 0 {
+		if len(f.Answers) > 0 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Answers", Message: "Answers need a meetup to be submitted to."})
+		}
+		return fieldErrors, nil
+	}
+	if previous != nil && previous.MeetupID == f.MeetupID && equalStrings(previous.Answers, f.Answers) {
+		return fieldErrors, nil
+	}
+
+	meetup, err := MeetupStorage.GetMeetup(ctx, f.MeetupID)
+	if err == datastore.ErrNoSuchEntity {
+		fieldErrors = append(fieldErrors, FieldError{Field: "MeetupID", Message: fmt.Sprintf("No meetup with ID: %v", f.MeetupID)})
+		return fieldErrors, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cfp := meetup.CallForPapers
+	if !cfp.IsOpen(time.Now()) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "MeetupID", Message: "The call for papers of the meetup isn't open."})
+		return fieldErrors, nil
+	}
+
+	if len(f.Answers) != len(cfp.Questions) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Answers", Message: fmt.Sprintf("All the %v questions of the call for papers have to be answered.", len(cfp.Questions))})
+	} else {
+		for index, answer := range f.Answers {
+			if strings.TrimSpace(answer) == "" || len(answer) > maxAnswerLength {
+				fieldErrors = append(fieldErrors, FieldError{Field: "Answers", Message: fmt.Sprintf("Answer to %q may not be empty or longer than %v characters.", cfp.Questions[index], maxAnswerLength)})
+			}
+		}
+	}
+
+	if cfp.MaxPerSpeaker > 0 {
+		IDs, presentations, err := PresentationStorage.GetAllPresentations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, speaker := range splitSpeakers(f.Speakers) {
+			submitted := 0
+			for index, presentation := range presentations {
+				if IDs[index] != ID && presentation.MeetupID == f.MeetupID && presentation.GetStatus() != StatusWithdrawn && contains(presentation.Speakers, speaker) {
+					submitted++
+				}
+			}
+			if submitted >= cfp.MaxPerSpeaker {
+				fieldErrors = append(fieldErrors, FieldError{Field: "Speakers", Message: fmt.Sprintf("%v already submitted %v presentations to this meetup, the maximum.", speaker, submitted)})
+			}
+		}
+	}
+
+	return fieldErrors, nil
+}
+
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package MeetupRest
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestValidateSubmission(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+
+	openID, err := store.AddMeetup(ctx, &Meetup{Title: "Open", CallForPapers: CallForPapers{
+		Start:         now.Add(-time.Hour),
+		End:           now.Add(time.Hour),
+		Questions:     []string{"Why this talk?"},
+		MaxPerSpeaker: 1,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedID, err := store.AddMeetup(ctx, &Meetup{Title: "Closed", CallForPapers: CallForPapers{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.AddPresentation(ctx, &Presentation{Title: "First", Speakers: []string{"Anna Nowak"}, MeetupID: openID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		form   PresentationForm
+		fields []string
+	}{
+		{PresentationForm{Speakers: "Jan Kowalski"}, []string{}},
+		{PresentationForm{Speakers: "Jan Kowalski", MeetupID: openID, Answers: []string{"Because."}}, []string{}},
+		{PresentationForm{Speakers: "Jan Kowalski", MeetupID: openID}, []string{"Answers"}},
+		{PresentationForm{Speakers: "Anna Nowak", MeetupID: openID, Answers: []string{"Because."}}, []string{"Speakers"}},
+		{PresentationForm{Speakers: "Jan Kowalski", MeetupID: closedID}, []string{"MeetupID"}},
+		{PresentationForm{Speakers: "Jan Kowalski", MeetupID: 1000}, []string{"MeetupID"}},
+	}
+
+	for _, c := range cases {
+		fieldErrors, err := validateSubmission(ctx, &c.form, 0, nil, store, store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fieldErrors) != len(c.fields) {
+			t.Errorf("Submission %+v should fail on %v, got %v", c.form, c.fields, fieldErrors)
+			continue
+		}
+		for index, fieldError := range fieldErrors {
+			if fieldError.Field != c.fields[index] {
+				t.Errorf("Submission %+v should fail on %v, got %v", c.form, c.fields, fieldErrors)
+			}
+		}
+	}
+}
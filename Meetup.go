@@ -12,8 +12,8 @@ import (
 
 	"strconv"
 
+	"github.com/cube2222/MeetupRest/api"
 	"github.com/gorilla/mux"
-	"google.golang.org/appengine"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/log"
 	"google.golang.org/appengine/user"
@@ -24,30 +24,31 @@ const datastoreMeetupsKind = "Meetups"
 type Meetup struct {
 	Owner         string
 	Title         string
-	Description   string
+	Description   string `datastore:",noindex"`
 	Presentations []int64
 	Date          time.Time
 	VoteTimeEnd   time.Time
+	// Approval if empty.
+	VotingMode VotingMode
+	// Votes for limited voting, points for budget voting, at most ranked presentations for ranked choice.
+	VotesPerVoter int
 	Latitude      float64
 	Longitude     float64
 	ExternalID    string
+	CallForPapers CallForPapers
+	// Ordered by start, see validateAgenda.
+	Agenda []AgendaSlot
+	// When the presentations were selected after the voting closed, zero before.
+	TalksSelected time.Time
+	Deleted       bool
+	DeletedAt     time.Time
+	// Incremented on every write, the ETag of the meetup.
+	Version int64
 }
 
-type MeetupPublicView struct {
-	Key           int64
-	Title         string
-	Description   string
-	Presentations []int64
-	Date          time.Time
-	VoteTimeEnd   time.Time
-}
+type MeetupPublicView = api.MeetupPublicView
 
-type MeetupForm struct {
-	Title       string
-	Description string
-	Date        time.Time
-	VoteTimeEnd time.Time
-}
+type MeetupForm = api.MeetupForm
 
 type MeetupStore interface {
 	GetMeetup(ctx context.Context, id int64) (Meetup, error)
@@ -55,286 +56,440 @@ type MeetupStore interface {
 	PutMeetup(ctx context.Context, id int64, meetup *Meetup) error
 	AddMeetup(ctx context.Context, meetup *Meetup) (int64, error)
 	DeleteMeetup(ctx context.Context, id int64) error
+	GetDeletedMeetups(ctx context.Context) ([]int64, []Meetup, error)
+	UndeleteMeetup(ctx context.Context, id int64) error
+	PurgeMeetup(ctx context.Context, id int64) error
 }
 
 // Register meetup routes to the router
-func RegisterMeetupRoutes(m *mux.Router, MeetupStorage MeetupStore, PresentationStorage PresentationStore, SpeakerStorage SpeakerStore, MeetupAPIUpdateFunction func(ctx context.Context) error, MeetupAPICreateFunction func(context.Context, int64) error) error {
+func RegisterMeetupRoutes(m *mux.Router, MeetupStorage MeetupStore, PresentationStorage PresentationStore, SpeakerStorage SpeakerStore, TransactionStorage TransactionStore, Events *EventBus) error {
 	if m == nil {
 		return errors.New("m may not be nil when regitering meetup routes")
 	}
-	h := meetupHandler{MeetupStorage: MeetupStorage, PresentationStorage: PresentationStorage, SpeakerStorage: SpeakerStorage, MeetupAPIUpdateFunction: MeetupAPIUpdateFunction, MeetupAPICreateFunction: MeetupAPICreateFunction}
+	h := meetupHandler{MeetupStorage: MeetupStorage, PresentationStorage: PresentationStorage, SpeakerStorage: SpeakerStorage, TransactionStorage: TransactionStorage, Events: Events}
 	m.HandleFunc("/{ID}/", h.GetMeetup).Methods("GET")
 	m.HandleFunc("/", h.AddMeetup).Methods("POST")
-	m.HandleFunc("/{id}/delete", h.DeleteMeetup).Methods("GET")
+	m.HandleFunc("/{ID}/delete", h.DeleteMeetup).Methods("GET")
 	m.HandleFunc("/{ID}/update", h.UpdateMeetup).Methods("POST")
 	m.HandleFunc("/list", h.ListMeetups).Methods("GET")
+	m.HandleFunc("/{ID}/agenda", h.GetAgenda).Methods("GET")
+
+	return nil
+}
+
+// Register the RESTful meetup routes of the versioned API to the router.
+func RegisterMeetupAPIRoutes(m *mux.Router, MeetupStorage MeetupStore, PresentationStorage PresentationStore, SpeakerStorage SpeakerStore, BallotStorage BallotStore, TransactionStorage TransactionStore, Events *EventBus, MeetupAPIUpdateFunction func(ctx context.Context) error) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering meetup API routes")
+	}
+	h := meetupHandler{MeetupStorage: MeetupStorage, PresentationStorage: PresentationStorage, SpeakerStorage: SpeakerStorage, BallotStorage: BallotStorage, TransactionStorage: TransactionStorage, Events: Events, MeetupAPIUpdateFunction: MeetupAPIUpdateFunction}
+	m.HandleFunc("/meetups", h.ListMeetups).Methods("GET")
+	m.HandleFunc("/meetups", h.AddMeetup).Methods("POST")
+	m.HandleFunc("/meetups/{ID}", h.GetMeetup).Methods("GET")
+	m.HandleFunc("/meetups/{ID}", h.UpdateMeetup).Methods("PUT", "PATCH")
+	m.HandleFunc("/meetups/{ID}", h.DeleteMeetup).Methods("DELETE")
+	m.HandleFunc("/meetups/{ID}/agenda", h.GetAgenda).Methods("GET")
+	m.HandleFunc("/meetups/{ID}/agenda", h.SetAgenda).Methods("PUT")
+	m.HandleFunc("/meetups/{ID}/agenda/auto", h.AutoSchedule).Methods("POST")
+	m.HandleFunc("/sync", h.Sync).Methods("POST")
 
 	return nil
 }
 
 type meetupHandler struct {
-	MeetupStorage           MeetupStore
-	PresentationStorage     PresentationStore
-	SpeakerStorage          SpeakerStore
+	MeetupStorage       MeetupStore
+	PresentationStorage PresentationStore
+	SpeakerStorage      SpeakerStore
+	// Only used to schedule in the voting mode of the meetup.
+	BallotStorage      BallotStore
+	TransactionStorage TransactionStore
+	Events             *EventBus
+	// Only used to sync on demand, the changes are synced by the subscriber of the events.
 	MeetupAPIUpdateFunction func(context.Context) error
-	MeetupAPICreateFunction func(context.Context, int64) error
 }
 
 func (h *meetupHandler) GetMeetup(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
 	}
 
 	meetup, err := h.MeetupStorage.GetMeetup(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprintf(w, "Couldn't find meetup with id: %v", ID)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find meetup with id: %v", ID))
 		return
 	}
 	if err != nil {
 		log.Errorf(ctx, "Couldn't get meetup: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	if checkIfNoneMatch(w, r, meetupETag(&meetup, time.Now())) {
 		return
 	}
 
 	meetupPublicView := meetup.GetPublicView(ID)
-	err = meetupPublicView.WriteTo(w)
+	err = encoder.Encode(w, &meetupPublicView)
 	if err != nil {
 		log.Errorf(ctx, "Failed to write meetup: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 }
 
 func (h *meetupHandler) AddMeetup(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
-		w.WriteHeader(http.StatusForbidden)
 		url, _ := user.LoginURL(ctx, fmt.Sprint("/meetup/form/add"))
-		fmt.Fprintf(w, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
-	meetup := Meetup{}
-
-	err := json.NewDecoder(r.Body).Decode(&meetup)
+	maf := MeetupForm{}
+	err := json.NewDecoder(r.Body).Decode(&maf)
 	if err != nil {
-		log.Errorf(ctx, "Couldn't decode add meetup request: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
 		return
 	}
 
-	if time.Since(meetup.Date) > time.Second*0 || meetup.Title == "" || time.Since(meetup.VoteTimeEnd) > time.Second*0 || meetup.Description == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Date, title, vote time end and description are mandatory. Date and vote time end need to be in the future.")
+	fieldErrors, err := validateMeetupForm(ctx, &maf, h.PresentationStorage, nil)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't validate meetup: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
 		return
 	}
 
+	meetup := Meetup{}
+	meetup.ApplyForm(&maf)
 	meetup.Owner = u.Email
 
 	ID, err := h.MeetupStorage.AddMeetup(ctx, &meetup)
 	if err != nil {
 		log.Errorf(ctx, "Can't create datastore object: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	h.Events.Publish(ctx, MeetupCreated{ID: ID, Meetup: meetup})
+
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprintf(w, "%v", ID)
-
-	err = h.MeetupAPICreateFunction(ctx, ID)
-	if err != nil {
-		log.Errorf(ctx, "Error when updating meetup API: %v", err)
-		return
-	}
 }
 
 func (h *meetupHandler) DeleteMeetup(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprintf("/meetup/%v/delete", ID))
-		fmt.Fprint(w, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	meetup, err := h.MeetupStorage.GetMeetup(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		fmt.Fprint(w, "Speaker not found.")
+		writeError(w, http.StatusNotFound, "Meetup not found.")
 		return
 	}
 	if err != nil {
-		log.Errorf(ctx, "Can't get speaker: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Errorf(ctx, "Can't get meetup: %v", err)
+		writeInternalError(w)
 		return
 	}
 
 	if meetup.Owner != u.Email && !u.Admin {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, "You're not the owner nor the admin.")
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
+		return
+	}
+
+	if !checkIfMatch(w, r, meetupETag(&meetup, time.Now())) {
 		return
 	}
 
-	err = h.MeetupStorage.DeleteMeetup(ctx, ID)
+	err = h.TransactionStorage.RunInTransaction(ctx, func(ctx context.Context) error {
+		current, err := h.MeetupStorage.GetMeetup(ctx, ID)
+		if err != nil {
+			return err
+		}
+		if current.Version != meetup.Version {
+			return ErrVersionConflict
+		}
+		return h.MeetupStorage.DeleteMeetup(ctx, ID)
+	})
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
 		log.Errorf(ctx, "Can't delete meetup: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	h.Events.Publish(ctx, MeetupDeleted{ID: ID})
+
 	w.WriteHeader(http.StatusTeapot)
 	fmt.Fprint(w, "Meetup deleted successfully.")
-
-	err = h.MeetupAPIUpdateFunction(ctx)
-	if err != nil {
-		log.Errorf(ctx, "Error when updating meetup API: %v", err)
-		return
-	}
 }
 
 func (h *meetupHandler) UpdateMeetup(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/update_meetup/%v", ID))
-		fmt.Fprint(w, url)
-		return
-	}
-
-	muf := &MeetupForm{}
-	err = json.NewDecoder(r.Body).Decode(&muf)
-	log.Debugf(ctx, "Body: %s", r.Body)
-	if err != nil {
-		log.Errorf(ctx, "Couldn't decode JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	meetup, err := h.MeetupStorage.GetMeetup(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		fmt.Fprint(w, "No such meetup found.")
+		writeError(w, http.StatusNotFound, "No such meetup found.")
 		return
 	}
 	if err != nil {
 		log.Errorf(ctx, "Can't get meetup: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	// Check if it's the owner
 	if meetup.Owner != u.Email && !u.Admin {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, "You're not the owner nor the admin.")
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
 		return
 	}
 
-	if muf.Title != "" {
-		meetup.Title = muf.Title
+	if !checkIfMatch(w, r, meetupETag(&meetup, time.Now())) {
+		return
 	}
 
-	if muf.Description != "" {
-		meetup.Description = muf.Description
+	form := meetup.GetForm()
+	err = decodeUpdate(r, &form)
+	if err == errUnsupportedPatchType {
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
 	}
 
-	meetup.Date = muf.Date
+	fieldErrors, err := validateMeetupForm(ctx, &form, h.PresentationStorage, &meetup)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't validate meetup: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return
+	}
 
-	meetup.VoteTimeEnd = muf.VoteTimeEnd
+	meetup.ApplyForm(&form)
 
 	err = h.MeetupStorage.PutMeetup(ctx, ID, &meetup)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
 		log.Errorf(ctx, "Can't create datastore object: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	h.Events.Publish(ctx, MeetupUpdated{ID: ID, Meetup: meetup})
+
+	w.Header().Set("ETag", meetupETag(&meetup, time.Now()))
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprint(w, "Meetup updated.")
+}
+
+// Push the meetups to meetup.com right away, instead of waiting for the next change.
+func (h *meetupHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return
+	}
+	if !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin.")
+		return
+	}
 
-	err = h.MeetupAPIUpdateFunction(ctx)
+	err := h.MeetupAPIUpdateFunction(ctx)
 	if err != nil {
 		log.Errorf(ctx, "Error when updating meetup API: %v", err)
+		writeInternalError(w)
 		return
 	}
+
+	fmt.Fprint(w, "Meetups synced.")
 }
 
 func (h *meetupHandler) ListMeetups(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	keys, meetups, err := h.MeetupStorage.GetAllMeetups(ctx)
 	if err != nil {
 		log.Errorf(ctx, "Can't get meetups: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	now := time.Now()
+	etags := make([]string, 0, len(meetups))
 	meetupsPublicView := make([]MeetupPublicView, 0, len(meetups))
 	for index, meetup := range meetups {
+		etags = append(etags, meetupETag(&meetup, now))
 		meetupsPublicView = append(meetupsPublicView, meetup.GetPublicView(keys[index]))
 	}
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	if checkIfNoneMatch(w, r, listETag(encoder, keys, etags)) {
+		return
+	}
 
-	err = WriteMeetupPublicView(meetupsPublicView, w)
+	err = WriteMeetupPublicView(meetupsPublicView, w, encoder)
 	if err != nil {
 		log.Errorf(ctx, "Failed to write meetups slice: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 }
 
-func (m *Meetup) GetPublicView(key int64) MeetupPublicView {
-	return MeetupPublicView{
-		Key:           key,
+// Check the form, including the ordering of the dates and whether the presentations exist.
+// Dates in the past are only accepted if they didn't change since the previous version of the meetup.
+func validateMeetupForm(ctx context.Context, f *MeetupForm, PresentationStorage PresentationStore, previous *Meetup) ([]FieldError, error) {
+	fieldErrors := validateStruct(f)
+	now := time.Now()
+
+	if !f.Date.IsZero() && f.Date.Before(now) && (previous == nil || !f.Date.Equal(previous.Date)) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Date", Message: "Must be in the future."})
+	}
+	if !f.VoteTimeEnd.IsZero() && f.VoteTimeEnd.Before(now) && (previous == nil || !f.VoteTimeEnd.Equal(previous.VoteTimeEnd)) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "VoteTimeEnd", Message: "Must be in the future."})
+	} else if !f.VoteTimeEnd.IsZero() && !f.Date.IsZero() && f.VoteTimeEnd.After(f.Date) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "VoteTimeEnd", Message: "Voting has to end before the meetup starts."})
+	}
+
+	var previousCallForPapers *CallForPapers
+	if previous != nil {
+		previousCallForPapers = &previous.CallForPapers
+	}
+	fieldErrors = append(fieldErrors, validateCallForPapers(&f.CallForPapers, f.Date, previousCallForPapers)...)
+	fieldErrors = append(fieldErrors, validateVotingMode(f.VotingMode, f.VotesPerVoter)...)
+
+	for _, presentationID := range f.Presentations {
+		_, err := PresentationStorage.GetPresentation(ctx, presentationID)
+		if err == datastore.ErrNoSuchEntity {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Presentations", Message: fmt.Sprintf("No presentation with ID: %v", presentationID)})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fieldErrors, nil
+}
+
+func (m *Meetup) ApplyForm(f *MeetupForm) {
+	m.Title = f.Title
+	m.Description = f.Description
+	m.Date = f.Date
+	m.VoteTimeEnd = f.VoteTimeEnd
+	m.VotingMode = f.VotingMode
+	m.VotesPerVoter = f.VotesPerVoter
+	m.Presentations = f.Presentations
+	m.Latitude = f.Latitude
+	m.Longitude = f.Longitude
+	m.CallForPapers = f.CallForPapers
+	m.pruneAgenda()
+}
+
+func (m *Meetup) GetForm() MeetupForm {
+	return MeetupForm{
 		Title:         m.Title,
 		Description:   m.Description,
-		Presentations: m.Presentations,
 		Date:          m.Date,
 		VoteTimeEnd:   m.VoteTimeEnd,
+		VotingMode:    m.VotingMode,
+		VotesPerVoter: m.VotesPerVoter,
+		Presentations: m.Presentations,
+		Latitude:      m.Latitude,
+		Longitude:     m.Longitude,
+		CallForPapers: m.CallForPapers,
 	}
 }
 
-func (m *Meetup) WriteTo(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(m)
+func (m *Meetup) GetPublicView(key int64) MeetupPublicView {
+	return MeetupPublicView{
+		Key:               key,
+		Title:             m.Title,
+		Description:       m.Description,
+		Presentations:     m.Presentations,
+		Date:              m.Date,
+		VoteTimeEnd:       m.VoteTimeEnd,
+		VotingMode:        m.GetVotingMode(),
+		VotesPerVoter:     m.VotesPerVoter,
+		CallForPapers:     m.CallForPapers,
+		CallForPapersOpen: m.CallForPapers.IsOpen(time.Now()),
+	}
 }
 
-func (m *MeetupPublicView) WriteTo(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(m)
+func (m *Meetup) WriteTo(w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, m)
 }
 
-func WriteMeetupPublicView(meetups []MeetupPublicView, w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(meetups)
+func WriteMeetupPublicView(meetups []MeetupPublicView, w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, meetups)
 }
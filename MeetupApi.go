@@ -27,12 +27,13 @@ type MeetupCreateResponse struct {
 
 const URL = "https://api.meetup.com"
 
-func getMeetupUpdateFunction(MetadataStorage MetadataStore, MeetupStorage MeetupStore) func(context.Context) error {
+func getMeetupUpdateFunction(MetadataStorage MetadataStore, MeetupStorage MeetupStore, PresentationStorage PresentationStore) func(context.Context) error {
 	return func(ctx context.Context) error {
 		errorChan := make(chan error)
 		APIKEYChan := make(chan string)
 		GroupNameChan := make(chan string)
 		MeetupsChan := make(chan []Meetup)
+		PresentationsChan := make(chan map[int64]Presentation)
 		go func() {
 			APIKEY, err := MetadataStorage.GetData(ctx, "APIKEY")
 			if err != nil {
@@ -57,18 +58,32 @@ func getMeetupUpdateFunction(MetadataStorage MetadataStore, MeetupStorage Meetup
 			}
 			MeetupsChan <- meetups
 		}()
+		go func() {
+			IDs, presentations, err := PresentationStorage.GetAllPresentations(ctx)
+			if err != nil {
+				errorChan <- err
+				return
+			}
+			byID := make(map[int64]Presentation, len(IDs))
+			for index, ID := range IDs {
+				byID[ID] = presentations[index]
+			}
+			PresentationsChan <- byID
+		}()
 
 		var APIKEY string
 		var GroupName string
 		var meetups []Meetup
+		var presentations map[int64]Presentation
 
-		for i := 0; i < 3; i++ {
+		for i := 0; i < 4; i++ {
 			select {
 			case err := <-errorChan:
 				return err
 			case APIKEY = <-APIKEYChan:
 			case GroupName = <-GroupNameChan:
 			case meetups = <-MeetupsChan:
+			case presentations = <-PresentationsChan:
 			}
 		}
 
@@ -84,13 +99,14 @@ func getMeetupUpdateFunction(MetadataStorage MetadataStore, MeetupStorage Meetup
 
 				Url.Path += fmt.Sprintf("/%s/events/%s", GroupName, meetup.ExternalID)
 
+				// The scheduled presentations are published as part of the description.
+				meetup.Description = agendaDescription(&meetup, presentations)
+
 				parameters := url.Values{}
 				parameters = prepareMeetupDependentParams(parameters, meetup)
 				parameters = prepareAuthenticationParams(parameters, APIKEY)
 				Url.RawQuery = parameters.Encode()
 
-				// TODO: Do some fun stuff. Like presentation scheduling.
-
 				r, err := http.NewRequest("PATCH", Url.String(), nil)
 				if err != nil {
 					errorChan <- err
@@ -210,3 +226,23 @@ func prepareAuthenticationParams(parameters url.Values, APIKey string) url.Value
 
 	return parameters
 }
+
+// Keep meetup.com in sync with the meetups and their presentations. The update pushes all of them, so it runs
+// once per request, with the context of the request, after all of its changes.
+func subscribeMeetupSync(Events *EventBus, update func(context.Context) error, create func(context.Context, int64) error) {
+	Events.Subscribe("meetup.com", DeliverAsync, meetupSync(update, create))
+}
+
+func meetupSync(update func(context.Context) error, create func(context.Context, int64) error) EventHandler {
+	return func(ctx context.Context, event Event) error {
+		switch event := event.(type) {
+		case MeetupCreated:
+			return create(ctx, event.ID)
+		case MeetupUpdated, MeetupDeleted, PresentationCreated, PresentationUpdated, PresentationDeleted, PresentationVoted, TrashRestored, VotesInvalidated:
+			return afterEvents(ctx, "meetup.com", func() error {
+				return update(ctx)
+			})
+		}
+		return nil
+	}
+}
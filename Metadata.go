@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
-	"google.golang.org/appengine"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/log"
 	"google.golang.org/appengine/user"
@@ -21,6 +20,7 @@ type MetadataStore interface {
 	GetData(ctx context.Context, key string) (string, error)
 	PutData(ctx context.Context, key string, data string) error
 	DeleteData(ctx context.Context, key string) error
+	GetAllData(ctx context.Context) ([]string, []string, error)
 }
 
 // Register meetup routes to the router
@@ -35,24 +35,35 @@ func RegisterMetadataRoutes(m *mux.Router, Storage MetadataStore) error {
 	return nil
 }
 
+// Register the RESTful metadata routes of the versioned API to the router.
+func RegisterMetadataAPIRoutes(m *mux.Router, Storage MetadataStore) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering metadata API routes")
+	}
+	h := metadataHandler{Storage: Storage}
+	m.HandleFunc("/metadata/{key}", h.getData).Methods("GET")
+	m.HandleFunc("/metadata/{key}", h.setData).Methods("PUT")
+
+	return nil
+}
+
 type metadataHandler struct {
 	Storage MetadataStore
 }
 
 func (h *metadataHandler) getData(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
-		fmt.Fprintf(w, `<a href="%s">Sign in or register</a>`, url)
+		writeLoginRequired(w, url)
 		return
 	}
 	if !u.Admin {
-		w.WriteHeader(http.StatusForbidden)
-		fmt.Fprint(w, "You have to be admin.")
+		writeError(w, http.StatusForbidden, "You have to be admin.")
 		return
 	}
 
@@ -60,13 +71,12 @@ func (h *metadataHandler) getData(w http.ResponseWriter, r *http.Request) {
 
 	value, err := h.Storage.GetData(ctx, vars["key"])
 	if err == datastore.ErrNoSuchEntity {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprintf(w, "Couldn't find data with key: %v", vars["key"])
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find data with key: %v", vars["key"]))
 		return
 	}
 	if err != nil {
 		log.Errorf(ctx, "Couldn't get data: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -74,32 +84,29 @@ func (h *metadataHandler) getData(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *metadataHandler) setData(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
-		fmt.Fprintf(w, `<a href="%s">Sign in or register</a>`, url)
+		writeLoginRequired(w, url)
 		return
 	}
 	if !u.Admin {
-		w.WriteHeader(http.StatusForbidden)
-		fmt.Fprint(w, "You have to be admin.")
+		writeError(w, http.StatusForbidden, "You have to be admin.")
 		return
 	}
 
 	params, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
-		log.Errorf(ctx, "Error when parsing query:", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't parse query: %v", err))
 		return
 	}
 	data, ok := params["data"]
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Data is mandatory.")
+		writeError(w, http.StatusBadRequest, "Data is mandatory.")
 		return
 	}
 
@@ -107,13 +114,12 @@ func (h *metadataHandler) setData(w http.ResponseWriter, r *http.Request) {
 
 	err = h.Storage.PutData(ctx, vars["key"], data[0])
 	if err == datastore.ErrNoSuchEntity {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprintf(w, "Couldn't find data with key: %v", vars["key"])
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find data with key: %v", vars["key"]))
 		return
 	}
 	if err != nil {
 		log.Errorf(ctx, "Couldn't get data: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -8,11 +8,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/cube2222/MeetupRest/api"
 	"github.com/gorilla/mux"
-	"google.golang.org/appengine"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/log"
 	"google.golang.org/appengine/user"
@@ -23,29 +24,31 @@ const datastorePresentationsKind = "Presentations"
 type Presentation struct {
 	Owner       string
 	Title       string
-	Description string
+	Description string `datastore:",noindex"`
 	Speakers    []string
-	Voters      []string
+	// IDs of the voters, see voterID. Votes stored before hold the emails until they're anonymized.
+	Voters []string
+	// The meetup the presentation was submitted to through its call for papers, 0 if none.
+	MeetupID int64
+	// Answers to the questions of the call for papers, in the same order.
+	Answers []string `datastore:",noindex"`
+	// Set when the content changed materially after votes were cast.
+	VotesFlagged bool
+	Submitted    time.Time
+	// Empty for presentations stored before the review workflow, see GetStatus.
+	Status        PresentationStatus
+	StatusChanged time.Time
+	Deleted       bool
+	DeletedAt     time.Time
+	// Incremented on every write, the ETag of the presentation.
+	Version int64
 }
 
-type PresentationForm struct {
-	Title       string
-	Description string
-	Speakers    string
-}
+type PresentationForm = api.PresentationForm
 
-type PresentationPublicView struct {
-	Key         int64
-	Title       string
-	Description string
-	Speakers    []SpeakerForPresentationPublicView
-	Votes       int
-}
+type PresentationPublicView = api.PresentationPublicView
 
-type SpeakerForPresentationPublicView struct {
-	Name string
-	Key  int64
-}
+type SpeakerForPresentationPublicView = api.SpeakerForPresentationPublicView
 
 type PresentationStore interface {
 	GetPresentation(ctx context.Context, id int64) (Presentation, error)
@@ -53,6 +56,9 @@ type PresentationStore interface {
 	PutPresentation(ctx context.Context, id int64, presentation *Presentation) error
 	AddPresentation(ctx context.Context, presentation *Presentation) (int64, error)
 	DeletePresentation(ctx context.Context, id int64) error
+	GetDeletedPresentations(ctx context.Context) ([]int64, []Presentation, error)
+	UndeletePresentation(ctx context.Context, id int64) error
+	PurgePresentation(ctx context.Context, id int64) error
 }
 
 type Option struct {
@@ -60,50 +66,110 @@ type Option struct {
 }
 
 // Get the handler which contains all the presentation handling routes and the corresponding handlers.
-func RegisterPresentationRoutes(m *mux.Router, PresentationStorage PresentationStore, SpeakerStorage SpeakerStore, MeetupAPIUpdateFunction func(context.Context) error) error {
+func RegisterPresentationRoutes(m *mux.Router, PresentationStorage PresentationStore, SpeakerStorage SpeakerStore, MeetupStorage MeetupStore, BallotStorage BallotStore, RevisionStorage PresentationRevisionStore, MetadataStorage MetadataStore, TransactionStorage TransactionStore, Guard *VoteGuard, Bus *PresentationBus, Events *EventBus) error {
 	if m == nil {
 		return errors.New("m may not be nil when registering presentation routes")
 	}
-	h := presentationHandler{PresentationStorage: PresentationStorage, SpeakerStorage: SpeakerStorage, MeetupAPIUpdateFunction: MeetupAPIUpdateFunction}
+	h := presentationHandler{PresentationStorage: PresentationStorage, SpeakerStorage: SpeakerStorage, MeetupStorage: MeetupStorage, BallotStorage: BallotStorage, RevisionStorage: RevisionStorage, MetadataStorage: MetadataStorage, TransactionStorage: TransactionStorage, Guard: Guard, Bus: Bus, Events: Events}
 	m.HandleFunc("/{ID}/", h.GetPresentation).Methods("GET")
 	m.HandleFunc("/", h.AddPresentation).Methods("POST")
 	m.HandleFunc("/{ID}/delete", h.DeletePresentation).Methods("GET")
 	m.HandleFunc("/{ID}/update", h.UpdatePresentation).Methods("POST")
 	m.HandleFunc("/list", h.ListPresentations).Methods("GET")
+	m.HandleFunc("/stream", h.StreamPresentations).Methods("GET")
+	m.HandleFunc("/export.csv", h.ExportPresentations).Methods("GET")
 	m.HandleFunc("/{ID}/upvote", h.UpvotePresentation).Methods("GET")
 	m.HandleFunc("/{ID}/downvote", h.DownvotePresentation).Methods("GET")
 	m.HandleFunc("/{ID}/hasUpvoted", h.HasUpvoted).Methods("GET")
+	m.HandleFunc("/{ID}/status", h.SetStatus).Methods("POST")
+	m.HandleFunc("/{ID}/revisions", h.ListRevisions).Methods("GET")
+	m.HandleFunc("/{ID}/revisions/diff", h.DiffRevisions).Methods("GET")
+	m.HandleFunc("/{ID}/revisions/{Revision}/", h.GetRevision).Methods("GET")
+	m.HandleFunc("/{ID}/revisions/{Revision}/restore", h.RestoreRevision).Methods("POST")
+
+	return nil
+}
+
+// Register the RESTful presentation routes of the versioned API to the router.
+func RegisterPresentationAPIRoutes(m *mux.Router, PresentationStorage PresentationStore, SpeakerStorage SpeakerStore, MeetupStorage MeetupStore, BallotStorage BallotStore, RevisionStorage PresentationRevisionStore, MetadataStorage MetadataStore, TransactionStorage TransactionStore, Guard *VoteGuard, Bus *PresentationBus, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering presentation API routes")
+	}
+	h := presentationHandler{PresentationStorage: PresentationStorage, SpeakerStorage: SpeakerStorage, MeetupStorage: MeetupStorage, BallotStorage: BallotStorage, RevisionStorage: RevisionStorage, MetadataStorage: MetadataStorage, TransactionStorage: TransactionStorage, Guard: Guard, Bus: Bus, Events: Events}
+	m.HandleFunc("/presentations", h.ListPresentations).Methods("GET")
+	m.HandleFunc("/presentations", h.AddPresentation).Methods("POST")
+	m.HandleFunc("/presentations/export.csv", h.ExportPresentations).Methods("GET")
+	m.HandleFunc("/presentations/stream", h.StreamPresentations).Methods("GET")
+	m.HandleFunc("/presentations/{ID}", h.GetPresentation).Methods("GET")
+	m.HandleFunc("/presentations/{ID}", h.UpdatePresentation).Methods("PUT", "PATCH")
+	m.HandleFunc("/presentations/{ID}", h.DeletePresentation).Methods("DELETE")
+	m.HandleFunc("/presentations/{ID}/vote", h.HasUpvoted).Methods("GET")
+	m.HandleFunc("/presentations/{ID}/vote", h.UpvotePresentation).Methods("PUT")
+	m.HandleFunc("/presentations/{ID}/vote", h.DownvotePresentation).Methods("DELETE")
+	m.HandleFunc("/presentations/{ID}/votes", h.ListVotes).Methods("GET")
+	m.HandleFunc("/presentations/{ID}/status", h.SetStatus).Methods("PUT")
+	m.HandleFunc("/presentations/{ID}/revisions", h.ListRevisions).Methods("GET")
+	m.HandleFunc("/presentations/{ID}/revisions/diff", h.DiffRevisions).Methods("GET")
+	m.HandleFunc("/presentations/{ID}/revisions/{Revision}", h.GetRevision).Methods("GET")
+	m.HandleFunc("/presentations/{ID}/revisions/{Revision}/restore", h.RestoreRevision).Methods("POST")
 
 	return nil
 }
 
 type presentationHandler struct {
-	PresentationStorage     PresentationStore
-	SpeakerStorage          SpeakerStore
-	MeetupAPIUpdateFunction func(context.Context) error
+	PresentationStorage PresentationStore
+	SpeakerStorage      SpeakerStore
+	MeetupStorage       MeetupStore
+	BallotStorage       BallotStore
+	RevisionStorage     PresentationRevisionStore
+	MetadataStorage     MetadataStore
+	TransactionStorage  TransactionStore
+	Guard               *VoteGuard
+	Bus                 *PresentationBus
+	Events              *EventBus
 }
 
 func (h *presentationHandler) GetPresentation(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
 	}
 
+	u := currentUser(ctx, r)
 	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == nil && !presentation.IsVisibleTo(u) {
+		err = datastore.ErrNoSuchEntity
+	}
 	if err == datastore.ErrNoSuchEntity {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprintf(w, "Couldn't find presentation with id: %v", ID)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
 		return
 	}
 	if err != nil {
 		log.Errorf(ctx, "Couldn't get presentation: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	hide, err := hideSpeakersFrom(ctx, u, h.MetadataStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get blind review setting: %v", err)
+		writeInternalError(w)
+		return
+	}
+	hide = hide && presentation.Owner != u.Email
+
+	if checkIfNoneMatch(w, r, presentationETag(&presentation, hide)) {
 		return
 	}
 
@@ -114,221 +180,345 @@ func (h *presentationHandler) GetPresentation(w http.ResponseWriter, r *http.Req
 	}
 
 	presentationPublicView := presentation.GetPublicView(ID, speakerKeys)
-	err = presentationPublicView.WriteTo(w)
+	if hide {
+		redactSpeakers(&presentationPublicView)
+	}
+	err = encoder.Encode(w, &presentationPublicView)
 	if err != nil {
 		log.Errorf(ctx, "Failed to write presentation: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 }
 
 func (h *presentationHandler) AddPresentation(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
-		w.WriteHeader(http.StatusForbidden)
 		url, _ := user.LoginURL(ctx, fmt.Sprint("/public/#/add_presentation/"))
-		fmt.Fprint(w, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	puf := PresentationForm{}
 	err := json.NewDecoder(r.Body).Decode(&puf)
 	if err != nil {
-		log.Errorf(ctx, "Couldn't decode JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
 		return
 	}
 
-	if puf.Title == "" || puf.Speakers[0] == 0 || puf.Description == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Fields Title, Speakers and Description are mandatory!")
+	fieldErrors, err := validatePresentationForm(ctx, &puf, h.SpeakerStorage)
+	if err == nil {
+		var submissionErrors []FieldError
+		submissionErrors, err = validateSubmission(ctx, &puf, 0, nil, h.MeetupStorage, h.PresentationStorage)
+		fieldErrors = append(fieldErrors, submissionErrors...)
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't validate presentation: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
 		return
 	}
 
 	presentation := Presentation{}
-	presentation.Title = puf.Title
-	presentation.Description = puf.Description
-	presentation.Speakers = strings.Split(puf.Speakers, ",")
-	for index, item := range presentation.Speakers {
-		presentation.Speakers[index] = strings.Trim(item, " ")
-	}
+	presentation.ApplyForm(&puf)
 	presentation.Owner = u.Email
+	presentation.Submitted = time.Now()
+	presentation.Status = StatusSubmitted
+	if r.URL.Query().Get("draft") == "true" {
+		presentation.Status = StatusDraft
+	}
+	presentation.StatusChanged = presentation.Submitted
 
 	ID, err := h.PresentationStorage.AddPresentation(ctx, &presentation)
 	if err != nil {
 		log.Errorf(ctx, "Can't create datastore object: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	h.Events.Publish(ctx, PresentationCreated{ID: ID, Presentation: presentation})
+
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprintf(w, "%v", ID)
-
-	err = h.MeetupAPIUpdateFunction(ctx)
-	if err != nil {
-		log.Errorf(ctx, "Error when updating meetup API: %v", err)
-		return
-	}
 }
 
 func (h *presentationHandler) UpdatePresentation(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Please provide a valid ID.")
+		writeError(w, http.StatusBadRequest, "Please provide a valid ID.")
 		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		//Make you sure that ulr is correct.
 		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/update_presentation/%v/", ID))
-		fmt.Fprint(w, url)
-		return
-	}
-
-	puf := PresentationForm{}
-	err = json.NewDecoder(r.Body).Decode(&puf)
-	log.Debugf(ctx, "Body: %s", r.Body)
-	if err != nil {
-		log.Errorf(ctx, "Couldn't decode JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		fmt.Fprint(w, "No presentation with ID: %v", ID)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No presentation with ID: %v", ID))
 		return
 	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
 		return
 	}
 
 	// Check if it's the owner
 	if presentation.Owner != u.Email && !u.Admin {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, "You're not the owner nor the admin.")
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
 		return
 	}
 
-	if puf.Title != presentation.Title {
-		presentation.Title = puf.Title
+	if !checkIfMatch(w, r, etag(presentation.Version)) {
+		return
 	}
 
-	if puf.Description != presentation.Description {
-		presentation.Description = puf.Description
+	puf := presentation.GetForm()
+	err = decodeUpdate(r, &puf)
+	if err == errUnsupportedPatchType {
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
 	}
 
-	speakers := strings.Split(puf.Speakers, ",")
-	same := true
-	for _, item := range speakers {
-		found := false
-		for _, item2 := range presentation.Speakers {
-			if item == item2 {
-				found = true
-				break
-			}
-		}
-		if !found {
-			same = false
-			break
-		}
+	fieldErrors, err := validatePresentationForm(ctx, &puf, h.SpeakerStorage)
+	if err == nil {
+		var submissionErrors []FieldError
+		submissionErrors, err = validateSubmission(ctx, &puf, ID, &presentation, h.MeetupStorage, h.PresentationStorage)
+		fieldErrors = append(fieldErrors, submissionErrors...)
 	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't validate presentation: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return
+	}
+
+	previous := presentation.NewRevision(time.Now())
+	presentation.ApplyForm(&puf)
 
-	if !same {
-		presentation.Speakers = speakers
+	err = h.applyVotePolicy(ctx, &presentation, &previous)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get vote policy: %v", err)
+		writeInternalError(w)
+		return
 	}
 
 	err = h.PresentationStorage.PutPresentation(ctx, ID, &presentation)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		log.Errorf(ctx, "Couldn't put presentation into datastore: %v", err)
+		writeInternalError(w)
+		return
 	}
 
+	h.Events.Publish(ctx, PresentationUpdated{ID: ID, Presentation: presentation})
+
+	w.Header().Set("ETag", etag(presentation.Version))
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprint(w, "Presentation Updated!")
-
-	err = h.MeetupAPIUpdateFunction(ctx)
-	if err != nil {
-		log.Errorf(ctx, "Error when updating meetup API: %v", err)
-		return
-	}
 }
 
 func (h *presentationHandler) DeletePresentation(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/delete_presentation/%v/", ID))
-		fmt.Fprint(w, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		fmt.Fprint(w, "Speaker not found.")
+		writeError(w, http.StatusNotFound, "Presentation not found.")
 		return
 	}
 	if err != nil {
-		log.Errorf(ctx, "Can't get speaker: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Errorf(ctx, "Can't get presentation: %v", err)
+		writeInternalError(w)
 		return
 	}
 
 	if presentation.Owner != u.Email && !u.Admin {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, "You're not the owner nor the admin.")
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
 		return
 	}
 
-	err = h.PresentationStorage.DeletePresentation(ctx, ID)
-	if err != nil {
-		log.Errorf(ctx, "Can't delete meetup: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	if !checkIfMatch(w, r, etag(presentation.Version)) {
 		return
 	}
 
-	w.WriteHeader(http.StatusTeapot)
-	fmt.Fprintf(w, "Presentation deleted successfully. %v", ID)
+	cascade := isCascade(r)
+	if cascade && !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin to cascade deletes.")
+		return
+	}
 
-	err = h.MeetupAPIUpdateFunction(ctx)
+	meetups, err := findPresentationDependents(ctx, []int64{ID}, h.MeetupStorage)
 	if err != nil {
-		log.Errorf(ctx, "Error when updating meetup API: %v", err)
+		log.Errorf(ctx, "Can't get presentation dependents: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	if !cascade && len(meetups) > 0 {
+		writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+			Message: "The presentation is still part of meetups. Remove it from them or delete with cascade=true.",
+			Details: Dependents{Presentations: []int64{}, Meetups: meetups},
+		})
+		return
+	}
+
+	// The presentation is deleted first, so nothing is touched if somebody changed it in the meantime.
+	err = h.TransactionStorage.RunInTransaction(ctx, func(ctx context.Context) error {
+		current, err := h.PresentationStorage.GetPresentation(ctx, ID)
+		if err != nil {
+			return err
+		}
+		if current.Version != presentation.Version {
+			return ErrVersionConflict
+		}
+		return h.PresentationStorage.DeletePresentation(ctx, ID)
+	})
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
 		return
 	}
+	if err != nil {
+		log.Errorf(ctx, "Can't delete presentation: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	// The meetups are detached in batches, so that they don't go past the entity group limit of a transaction.
+	// The committed ones are published even if a later step fails.
+	events := []Event{PresentationDeleted{ID: ID}}
+	defer func() {
+		h.Events.Publish(ctx, events...)
+	}()
+
+	// Now that the presentation is deleted no meetup can add it anymore, so its dependents are looked up
+	// again. They're either detached or, if some added it since they were checked, the delete is undone.
+	if cascade {
+		more, err := detachFromMeetups(ctx, []int64{ID}, h.MeetupStorage, h.TransactionStorage)
+		events = append(events, more...)
+		if err != nil {
+			log.Errorf(ctx, "Can't detach presentation from meetups: %v", err)
+			writeInternalError(w)
+			return
+		}
+	} else {
+		meetups, err = findPresentationDependents(ctx, []int64{ID}, h.MeetupStorage)
+		if err == nil && len(meetups) > 0 {
+			err = h.PresentationStorage.UndeletePresentation(ctx, ID)
+			if err == nil {
+				events = nil
+				writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+					Message: "The presentation is still part of meetups. Remove it from them or delete with cascade=true.",
+					Details: Dependents{Presentations: []int64{}, Meetups: meetups},
+				})
+				return
+			}
+		}
+		if err != nil {
+			log.Errorf(ctx, "Can't check presentation dependents: %v", err)
+			writeInternalError(w)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusTeapot)
+	fmt.Fprintf(w, "Presentation deleted successfully. %v", ID)
 }
 
 func (h *presentationHandler) ListPresentations(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
+	statuses, err := parseStatuses(r.URL.Query().Get("status"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var meetupID int64
+	if value := r.URL.Query().Get("meetup"); value != "" {
+		meetupID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Meetup not valid: %v", value))
+			return
+		}
+	}
+
 	IDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
 	if err != nil {
 		log.Errorf(ctx, "Can't get presentations: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
+		return
+	}
+	u := currentUser(ctx, r)
+	IDs, presentations = filterVisible(IDs, presentations, u)
+	if len(statuses) > 0 {
+		IDs, presentations = filterByStatus(IDs, presentations, statuses)
+	}
+	if meetupID != 0 {
+		IDs, presentations = filterBySubmission(IDs, presentations, meetupID)
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	hide, err := hideSpeakersFrom(ctx, u, h.MetadataStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get blind review setting: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	// The list is polled for the vote counts, so the ETag also changes when the speakers get hidden or shown.
+	etags := make([]string, 0, len(presentations))
+	for _, presentation := range presentations {
+		etags = append(etags, presentationETag(&presentation, hide && presentation.Owner != u.Email))
+	}
+	if checkIfNoneMatch(w, r, listETag(encoder, IDs, etags)) {
 		return
 	}
 
@@ -341,124 +531,220 @@ func (h *presentationHandler) ListPresentations(w http.ResponseWriter, r *http.R
 			speakerKeys = append(speakerKeys, speakerKey)
 		}
 
-		presentationsPublicView = append(presentationsPublicView, presentation.GetPublicView(IDs[idx], speakerKeys))
+		view := presentation.GetPublicView(IDs[idx], speakerKeys)
+		if hide && presentation.Owner != u.Email {
+			redactSpeakers(&view)
+		}
+		presentationsPublicView = append(presentationsPublicView, view)
 	}
 
-	err = WritePresentationsPublicView(presentationsPublicView, w)
+	err = WritePresentationsPublicView(presentationsPublicView, w, encoder)
 	if err != nil {
 		log.Errorf(ctx, "Failed to write presentations slice: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 }
 
 func (h *presentationHandler) UpvotePresentation(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Please provide a valid ID.")
+		writeError(w, http.StatusBadRequest, "Please provide a valid ID.")
 		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprintf("/presentation/%v/upvote", vars["ID"]))
-		fmt.Fprintf(w, `<a href="%s">Sign in or register</a>`, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
+		return
+	}
+
+	if !presentation.IsVotable() {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Draft, withdrawn and rejected presentations can't be upvoted, this one is %v.", presentation.GetStatus()))
+		return
+	}
+	meetupID, meetup, err := h.getBallotMeetup(ctx, ID, &presentation)
+	if err != nil {
+		log.Errorf(ctx, "Can't get meetups: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if meetup != nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Meetup %v uses %v voting, cast a ballot at /api/v1/meetups/%v/ballot/%v instead.", meetupID, meetup.GetVotingMode(), meetupID, meetup.GetVotingMode()))
 		return
 	}
 
-	if contains(presentation.Voters, u.Email) {
-		fmt.Fprint(w, "Sorry, you already upvoted this presentation.")
+	salt, voter, ok := getVoterID(ctx, w, u, h.MetadataStorage, h.TransactionStorage)
+	if !ok {
+		return
+	}
+	if !h.Guard.Allow(ctx, w, r, salt, u) {
+		return
+	}
+	presentation.Voters = claimVotes(salt, u, presentation.Voters)
+	if contains(presentation.Voters, voter) {
+		writeError(w, http.StatusConflict, "Sorry, you already upvoted this presentation.")
 		return
 	}
 
-	presentation.Voters = append(presentation.Voters, u.Email)
+	presentation.Voters = append(presentation.Voters, voter)
 
 	err = h.PresentationStorage.PutPresentation(ctx, ID, &presentation)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Errorf(ctx, "Couldn't put presentation into datastore: %v", err)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
 	}
-	fmt.Fprint(w, "Upvoted!")
-
-	err = h.MeetupAPIUpdateFunction(ctx)
 	if err != nil {
-		log.Errorf(ctx, "Error when updating meetup API: %v", err)
+		log.Errorf(ctx, "Couldn't put presentation into datastore: %v", err)
+		writeInternalError(w)
 		return
 	}
+	h.Guard.Record(ctx, r, salt, voter, voteEventUpvote, ID, 0)
+	h.Events.Publish(ctx, PresentationVoted{ID: ID, Presentation: presentation})
+	fmt.Fprint(w, "Upvoted!")
 }
 
 func (h *presentationHandler) DownvotePresentation(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Please provide a valid ID.")
+		writeError(w, http.StatusBadRequest, "Please provide a valid ID.")
 		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprintf("/presentation/%v/downvote", vars["ID"]))
-		fmt.Fprintf(w, `<a href="%s">Sign in or register</a>`, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
 		return
 	}
 
-	if !contains(presentation.Voters, u.Email) {
-		fmt.Fprint(w, "Sorry, you haven't upvoted this presentation.")
+	salt, voter, ok := getVoterID(ctx, w, u, h.MetadataStorage, h.TransactionStorage)
+	if !ok {
+		return
+	}
+	if !h.Guard.Allow(ctx, w, r, salt, u) {
+		return
+	}
+	presentation.Voters = claimVotes(salt, u, presentation.Voters)
+	if !contains(presentation.Voters, voter) {
+		writeError(w, http.StatusConflict, "Sorry, you haven't upvoted this presentation.")
 		return
 	}
 
 	for i := 0; i < len(presentation.Voters); i++ {
-		if presentation.Voters[i] == u.Email {
+		if presentation.Voters[i] == voter {
 			presentation.Voters = append(presentation.Voters[:i], presentation.Voters[i+1:]...)
 			break
 		}
 	}
 
 	err = h.PresentationStorage.PutPresentation(ctx, ID, &presentation)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		log.Errorf(ctx, "Couldn't put presentation into datastore: %v", err)
+		writeInternalError(w)
+		return
 	}
+	h.Guard.Record(ctx, r, salt, voter, voteEventDownvote, ID, 0)
+	h.Events.Publish(ctx, PresentationVoted{ID: ID, Presentation: presentation, Withdrawn: true})
 	fmt.Fprint(w, "Undone upvote!")
+}
+
+// List the IDs of the voters of the presentation. Only admins may see them.
+func (h *presentationHandler) ListVotes(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
 
-	err = h.MeetupAPIUpdateFunction(ctx)
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		log.Errorf(ctx, "Error when updating meetup API: %v", err)
+		writeError(w, http.StatusBadRequest, "Please provide a valid ID.")
+		return
+	}
+
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return
+	}
+	if !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin.")
+		return
+	}
+
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
+		return
+	}
+
+	voters := presentation.Voters
+	if voters == nil {
+		voters = []string{}
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, voters)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write voters: %v", err)
+		writeInternalError(w)
 		return
 	}
 }
 
 func (h *presentationHandler) HasUpvoted(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		fmt.Fprint(w, "false")
 		return
@@ -467,25 +753,84 @@ func (h *presentationHandler) HasUpvoted(w http.ResponseWriter, r *http.Request)
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Please provide a valid ID.")
+		writeError(w, http.StatusBadRequest, "Please provide a valid ID.")
 		return
 	}
 
 	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
 		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
 		return
 	}
 
-	if contains(presentation.Voters, u.Email) {
-		fmt.Fprint(w, "true")
-		return
-	} else {
-		fmt.Fprint(w, "false")
+	salt, _, ok := getVoterID(ctx, w, u, h.MetadataStorage, h.TransactionStorage)
+	if !ok {
 		return
 	}
+	for _, voter := range presentation.Voters {
+		if isVoter(salt, u, voter) {
+			fmt.Fprint(w, "true")
+			return
+		}
+	}
+	fmt.Fprint(w, "false")
+}
+
+// Check the form, including whether all the speakers exist.
+func validatePresentationForm(ctx context.Context, f *PresentationForm, SpeakerStorage SpeakerStore) ([]FieldError, error) {
+	fieldErrors := validateStruct(f)
+
+	for _, name := range splitSpeakers(f.Speakers) {
+		if len(strings.Fields(name)) < 2 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Speakers", Message: fmt.Sprintf("Speaker needs both a name and a surname: %v", name)})
+			continue
+		}
+		_, err := SpeakerStorage.GetSpeakerIdByName(ctx, name)
+		if err == datastore.ErrNoSuchEntity {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Speakers", Message: fmt.Sprintf("No speaker named: %v", name)})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return fieldErrors, nil
+}
+
+func (p *Presentation) ApplyForm(f *PresentationForm) {
+	p.Title = f.Title
+	p.Description = f.Description
+	p.Speakers = splitSpeakers(f.Speakers)
+	p.MeetupID = f.MeetupID
+	p.Answers = f.Answers
+}
+
+func (p *Presentation) GetForm() PresentationForm {
+	return PresentationForm{
+		Title:       p.Title,
+		Description: p.Description,
+		Speakers:    strings.Join(p.Speakers, ", "),
+		MeetupID:    p.MeetupID,
+		Answers:     p.Answers,
+	}
+}
+
+// Split a comma separated list of speaker names.
+func splitSpeakers(speakers string) []string {
+	names := make([]string, 0, 2)
+	for _, name := range strings.Split(speakers, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 func contains(slice []string, text string) bool {
@@ -504,25 +849,83 @@ func (p *Presentation) GetPublicView(key int64, speakerKeys []int64) Presentatio
 	}
 
 	return PresentationPublicView{
-		Key:         key,
-		Title:       p.Title,
-		Description: p.Description,
-		Speakers:    speakers,
-		Votes:       len(p.Voters),
+		Key:          key,
+		Title:        p.Title,
+		Description:  p.Description,
+		Speakers:     speakers,
+		Votes:        len(p.Voters),
+		VotesFlagged: p.VotesFlagged,
+		Submitted:    p.Submitted,
+		Status:       p.GetStatus(),
+		MeetupID:     p.MeetupID,
+		Answers:      p.Answers,
 	}
 }
 
-func (p *Presentation) WriteTo(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(p)
+// The meetup the presentation was submitted to or is part of which is voted on with ballots, nil if there is none.
+// Upvotes aren't counted there, so they aren't taken either.
+func (h *presentationHandler) getBallotMeetup(ctx context.Context, ID int64, presentation *Presentation) (int64, *Meetup, error) {
+	meetupIDs, meetups, err := h.MeetupStorage.GetAllMeetups(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	for index := range meetups {
+		if meetups[index].GetVotingMode() == VotingApproval {
+			continue
+		}
+		if meetupIDs[index] == presentation.MeetupID || containsID(meetups[index].Presentations, ID) {
+			return meetupIDs[index], &meetups[index], nil
+		}
+	}
+	return 0, nil, nil
+}
+
+// Only keep the presentations the user may see.
+func filterVisible(IDs []int64, presentations []Presentation, u *user.User) ([]int64, []Presentation) {
+	filteredIDs := make([]int64, 0, len(IDs))
+	filtered := make([]Presentation, 0, len(presentations))
+	for index, presentation := range presentations {
+		if presentation.IsVisibleTo(u) {
+			filteredIDs = append(filteredIDs, IDs[index])
+			filtered = append(filtered, presentation)
+		}
+	}
+	return filteredIDs, filtered
+}
+
+// Only keep the presentations with one of the statuses.
+func filterByStatus(IDs []int64, presentations []Presentation, statuses []PresentationStatus) ([]int64, []Presentation) {
+	filteredIDs := make([]int64, 0, len(IDs))
+	filtered := make([]Presentation, 0, len(presentations))
+	for index, presentation := range presentations {
+		for _, status := range statuses {
+			if presentation.GetStatus() == status {
+				filteredIDs = append(filteredIDs, IDs[index])
+				filtered = append(filtered, presentation)
+				break
+			}
+		}
+	}
+	return filteredIDs, filtered
+}
+
+// Only keep the presentations submitted to the meetup.
+func filterBySubmission(IDs []int64, presentations []Presentation, meetupID int64) ([]int64, []Presentation) {
+	filteredIDs := make([]int64, 0, len(IDs))
+	filtered := make([]Presentation, 0, len(presentations))
+	for index, presentation := range presentations {
+		if presentation.MeetupID == meetupID {
+			filteredIDs = append(filteredIDs, IDs[index])
+			filtered = append(filtered, presentation)
+		}
+	}
+	return filteredIDs, filtered
 }
 
-func (p *PresentationPublicView) WriteTo(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(p)
+func (p *Presentation) WriteTo(w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, p)
 }
 
-func WritePresentationsPublicView(presentations []PresentationPublicView, w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(presentations)
+func WritePresentationsPublicView(presentations []PresentationPublicView, w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, presentations)
 }
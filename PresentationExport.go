@@ -0,0 +1,209 @@
+package MeetupRest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+var presentationExportHeader = []string{"Key", "Title", "Description", "Speakers", "Company", "Votes", "Meetups", "Meetup Voters", "Submitted"}
+
+// A row of the presentation export.
+type presentationExportRow struct {
+	Key          int64
+	Presentation Presentation
+	// The score of the tally in the voting mode of the exported meetup, the upvotes when exporting all.
+	Votes     int
+	Companies []string
+	Meetups   []string
+	// The number of people who voted in each of the meetups.
+	MeetupVoters []int
+}
+
+// Export the presentations as CSV, most voted first, for picking talks in a spreadsheet.
+// The votes of a single meetup are tallied in its voting mode.
+// With the meetup query parameter, only the presentations of that meetup are exported.
+// Admins may export everything, meetup owners only the presentations of their meetup. Drafts are never exported.
+func (h *presentationHandler) ExportPresentations(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return
+	}
+
+	meetupIDs, meetups, err := h.MeetupStorage.GetAllMeetups(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get meetups: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	var onlyMeetupID int64
+	var onlyMeetup *Meetup
+	if value := r.URL.Query().Get("meetup"); value != "" {
+		meetupID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Meetup not valid: %v", value))
+			return
+		}
+		meetup, err := h.MeetupStorage.GetMeetup(ctx, meetupID)
+		if err == datastore.ErrNoSuchEntity {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find meetup with id: %v", meetupID))
+			return
+		}
+		if err != nil {
+			log.Errorf(ctx, "Can't get meetup: %v", err)
+			writeInternalError(w)
+			return
+		}
+		onlyMeetupID = meetupID
+		onlyMeetup = &meetup
+	}
+
+	// Owners only see the presentations of their meetup, everything else is left to the admins.
+	if !u.Admin && (onlyMeetup == nil || onlyMeetup.Owner != u.Email) {
+		writeError(w, http.StatusForbidden, "You have to be admin or the owner of the exported meetup.")
+		return
+	}
+
+	rows, err := h.getExportRows(ctx, meetupIDs, meetups, onlyMeetupID, onlyMeetup)
+	if err != nil {
+		log.Errorf(ctx, "Can't get presentations: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=presentations.csv")
+	err = writePresentationsCSV(rows, w)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write presentations CSV: %v", err)
+		return
+	}
+}
+
+func (h *presentationHandler) getExportRows(ctx context.Context, meetupIDs []int64, meetups []Meetup, onlyMeetupID int64, onlyMeetup *Meetup) ([]presentationExportRow, error) {
+	IDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, speakers, err := h.SpeakerStorage.GetAllSpeakers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	companies := make(map[string]string)
+	for _, speaker := range speakers {
+		companies[speaker.GetSpeakerFullName()] = speaker.Company
+	}
+
+	byID := make(map[int64]Presentation, len(IDs))
+	for index, ID := range IDs {
+		byID[ID] = presentations[index]
+	}
+	meetupVoters := make([]int, len(meetups))
+	for meetupIndex := range meetups {
+		ballots, err := h.BallotStorage.GetBallots(ctx, meetupIDs[meetupIndex])
+		if err != nil {
+			return nil, err
+		}
+		meetupVoters[meetupIndex] = tallyVotes(&meetups[meetupIndex], meetups[meetupIndex].Presentations, byID, ballots).Ballots
+	}
+
+	// A meetup without a voting mode tallies the upvotes, as approval voting.
+	tallied := &Meetup{}
+	candidates := IDs
+	var ballots []Ballot
+	if onlyMeetup != nil {
+		tallied = onlyMeetup
+		candidates = onlyMeetup.Presentations
+		ballots, err = h.BallotStorage.GetBallots(ctx, onlyMeetupID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	votes := make(map[int64]int, len(candidates))
+	for _, result := range tallyVotes(tallied, candidates, byID, ballots).Results {
+		votes[result.PresentationID] = result.Score
+	}
+
+	rows := make([]presentationExportRow, 0, len(presentations))
+	for index, presentation := range presentations {
+		if presentation.Status == StatusDraft {
+			continue
+		}
+		if onlyMeetup != nil && !containsID(onlyMeetup.Presentations, IDs[index]) {
+			continue
+		}
+
+		row := presentationExportRow{Key: IDs[index], Presentation: presentation, Votes: votes[IDs[index]], Companies: []string{}, Meetups: []string{}, MeetupVoters: []int{}}
+		for _, speaker := range presentation.Speakers {
+			if company := companies[speaker]; company != "" && !contains(row.Companies, company) {
+				row.Companies = append(row.Companies, company)
+			}
+		}
+		for meetupIndex, meetup := range meetups {
+			if containsID(meetup.Presentations, IDs[index]) {
+				row.Meetups = append(row.Meetups, fmt.Sprintf("%v (%v)", meetup.Title, meetupIDs[meetupIndex]))
+				row.MeetupVoters = append(row.MeetupVoters, meetupVoters[meetupIndex])
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Votes > rows[j].Votes
+	})
+	return rows, nil
+}
+
+func writePresentationsCSV(rows []presentationExportRow, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(presentationExportHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		submitted := ""
+		if !row.Presentation.Submitted.IsZero() {
+			submitted = row.Presentation.Submitted.Format(time.RFC3339)
+		}
+		meetupVoters := make([]string, 0, len(row.MeetupVoters))
+		for _, count := range row.MeetupVoters {
+			meetupVoters = append(meetupVoters, strconv.Itoa(count))
+		}
+		err = writer.Write([]string{
+			strconv.FormatInt(row.Key, 10),
+			escapeCSVFormula(row.Presentation.Title),
+			escapeCSVFormula(row.Presentation.Description),
+			escapeCSVFormula(strings.Join(row.Presentation.Speakers, ", ")),
+			escapeCSVFormula(strings.Join(row.Companies, ", ")),
+			strconv.Itoa(row.Votes),
+			escapeCSVFormula(strings.Join(row.Meetups, ", ")),
+			strings.Join(meetupVoters, ", "),
+			submitted,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,76 @@
+package MeetupRest
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/user"
+)
+
+func TestWritePresentationsCSV(t *testing.T) {
+	rows := []presentationExportRow{{
+		Key:   7,
+		Votes: 2,
+		Presentation: Presentation{
+			Title:       "Go, fast",
+			Description: "About \"speed\"",
+			Speakers:    []string{"Jan Kowalski", "Anna Nowak"},
+			Voters:      []string{"a@example.com", "b@example.com"},
+			Submitted:   time.Date(2017, 3, 1, 18, 0, 0, 0, time.UTC),
+		},
+		Companies:    []string{"Company"},
+		Meetups:      []string{"March (12)", "April (13)"},
+		MeetupVoters: []int{2, 5},
+	}, {
+		Key:          8,
+		Presentation: Presentation{Title: "=HYPERLINK(\"http://example.com\")", Speakers: []string{"@admin"}},
+		Companies:    []string{"-Company"},
+		Meetups:      []string{"+March (12)"},
+		MeetupVoters: []int{2},
+	}}
+
+	buffer := bytes.Buffer{}
+	err := writePresentationsCSV(rows, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Key,Title,Description,Speakers,Company,Votes,Meetups,Meetup Voters,Submitted\n" +
+		"7,\"Go, fast\",\"About \"\"speed\"\"\",\"Jan Kowalski, Anna Nowak\",Company,2,\"March (12), April (13)\",\"2, 5\",2017-03-01T18:00:00Z\n" +
+		"8,\"'=HYPERLINK(\"\"http://example.com\"\")\",,'@admin,'-Company,0,'+March (12),2,\n"
+	if buffer.String() != expected {
+		t.Errorf("Unexpected CSV. Received:\n%v", buffer.String())
+	}
+}
+
+func TestExportPresentationsPermissions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	owner := &user.User{Email: "owner@example.com"}
+
+	submittedID, _ := store.AddPresentation(ctx, &Presentation{Title: "Go", Status: StatusSubmitted})
+	draftID, _ := store.AddPresentation(ctx, &Presentation{Title: "Secret draft", Status: StatusDraft})
+	ownedID, _ := store.AddMeetup(ctx, &Meetup{Title: "March", Owner: owner.Email, Presentations: []int64{submittedID, draftID}})
+	otherID, _ := store.AddMeetup(ctx, &Meetup{Title: "April", Owner: "other@example.com"})
+
+	if response := serveAs(router, owner, "GET", "/presentations/export.csv", nil); response.Code != http.StatusForbidden {
+		t.Errorf("Owners shouldn't export all presentations, got %v", response.Code)
+	}
+	if response := serveAs(router, owner, "GET", fmt.Sprintf("/presentations/export.csv?meetup=%v", otherID), nil); response.Code != http.StatusForbidden {
+		t.Errorf("Owners shouldn't export meetups of others, got %v", response.Code)
+	}
+	response := serveAs(router, owner, "GET", fmt.Sprintf("/presentations/export.csv?meetup=%v", ownedID), nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Owners should export their meetup, got %v", response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "Go") || strings.Contains(response.Body.String(), "Secret draft") {
+		t.Errorf("Expected the submitted presentation without the draft, got %v", response.Body.String())
+	}
+}
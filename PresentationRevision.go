@@ -0,0 +1,426 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+const datastorePresentationRevisionsKind = "PresentationRevisions"
+
+// Metadata key holding what happens to the votes of a presentation when its content changes materially.
+const votePolicyOnChangeKey = "VotePolicyOnChange"
+
+const (
+	votePolicyKeep  = "keep"
+	votePolicyFlag  = "flag"
+	votePolicyReset = "reset"
+)
+
+// Descriptions less similar than this to the previous version are considered a material change.
+const materialChangeSimilarity = 0.7
+
+// A snapshot of the content of a presentation, taken before it gets overwritten.
+type PresentationRevision struct {
+	Title       string
+	Description string `datastore:",noindex"`
+	Speakers    []string
+	Saved       time.Time
+}
+
+type PresentationRevisionPublicView struct {
+	Key         int64
+	Title       string
+	Description string
+	Speakers    []string
+	Saved       time.Time
+}
+
+// Diff between two revisions. A revision key of 0 stands for the current version of the presentation.
+type PresentationRevisionDiff struct {
+	From        int64
+	To          int64
+	Title       []DiffLine
+	Description []DiffLine
+	Speakers    []DiffLine
+}
+
+type PresentationRevisionStore interface {
+	GetPresentationRevisions(ctx context.Context, presentationID int64) ([]int64, []PresentationRevision, error)
+	GetPresentationRevision(ctx context.Context, presentationID int64, id int64) (PresentationRevision, error)
+	PutPresentationRevision(ctx context.Context, presentationID int64, id int64, revision *PresentationRevision) error
+}
+
+func (h *presentationHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
+	}
+
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == nil && !presentation.IsVisibleTo(currentUser(ctx, r)) {
+		err = datastore.ErrNoSuchEntity
+	}
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	IDs, revisions, err := h.RevisionStorage.GetPresentationRevisions(ctx, ID)
+	if err != nil {
+		log.Errorf(ctx, "Can't get presentation revisions: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	revisionsPublicView := make([]PresentationRevisionPublicView, 0, len(revisions))
+	for index, revision := range revisions {
+		revisionsPublicView = append(revisionsPublicView, revision.GetPublicView(IDs[index]))
+	}
+	// Newest first.
+	sort.Slice(revisionsPublicView, func(i, j int) bool {
+		return revisionsPublicView[i].Saved.After(revisionsPublicView[j].Saved)
+	})
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = WritePresentationRevisionsPublicView(revisionsPublicView, w, encoder)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write presentation revisions slice: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func (h *presentationHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
+	}
+	revisionID, err := strconv.ParseInt(vars["Revision"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Revision not valid: %v", vars["Revision"]))
+		return
+	}
+
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == nil && !presentation.IsVisibleTo(currentUser(ctx, r)) {
+		err = datastore.ErrNoSuchEntity
+	}
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	revision, err := h.RevisionStorage.GetPresentationRevision(ctx, ID, revisionID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find revision %v of presentation %v", revisionID, ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation revision: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	revisionPublicView := revision.GetPublicView(revisionID)
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = revisionPublicView.WriteTo(w, encoder)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write presentation revision: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+// Diff two revisions given as the from and to query parameters. A missing parameter means the current version.
+func (h *presentationHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
+	}
+
+	revisionIDs := make([]int64, 2)
+	for i, param := range []string{"from", "to"} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		revisionIDs[i], err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Revision not valid: %v", value))
+			return
+		}
+	}
+
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == nil && !presentation.IsVisibleTo(currentUser(ctx, r)) {
+		err = datastore.ErrNoSuchEntity
+	}
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	revisions := make([]PresentationRevision, 2)
+	for i, revisionID := range revisionIDs {
+		if revisionID == 0 {
+			revisions[i] = presentation.NewRevision(time.Now())
+			continue
+		}
+
+		revisions[i], err = h.RevisionStorage.GetPresentationRevision(ctx, ID, revisionID)
+		if err == datastore.ErrNoSuchEntity {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find revision %v of presentation %v", revisionID, ID))
+			return
+		}
+		if err != nil {
+			log.Errorf(ctx, "Couldn't get presentation revision: %v", err)
+			writeInternalError(w)
+			return
+		}
+	}
+
+	diff := PresentationRevisionDiff{
+		From:        revisionIDs[0],
+		To:          revisionIDs[1],
+		Title:       diffLines([]string{revisions[0].Title}, []string{revisions[1].Title}),
+		Description: diffLines(strings.Split(revisions[0].Description, "\n"), strings.Split(revisions[1].Description, "\n")),
+		Speakers:    diffLines(revisions[0].Speakers, revisions[1].Speakers),
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = diff.WriteTo(w, encoder)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write presentation revision diff: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func (h *presentationHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
+	}
+	revisionID, err := strconv.ParseInt(vars["Revision"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Revision not valid: %v", vars["Revision"]))
+		return
+	}
+
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/update_presentation/%v/", ID))
+		writeLoginRequired(w, url)
+		return
+	}
+
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
+		return
+	}
+
+	if presentation.Owner != u.Email && !u.Admin {
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(presentation.Version)) {
+		return
+	}
+
+	revision, err := h.RevisionStorage.GetPresentationRevision(ctx, ID, revisionID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find revision %v of presentation %v", revisionID, ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation revision: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	previous := presentation.NewRevision(time.Now())
+	presentation.Title = revision.Title
+	presentation.Description = revision.Description
+	presentation.Speakers = revision.Speakers
+
+	err = h.applyVotePolicy(ctx, &presentation, &previous)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get vote policy: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	err = h.PresentationStorage.PutPresentation(ctx, ID, &presentation)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't put presentation into datastore: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	h.Events.Publish(ctx, PresentationUpdated{ID: ID, Presentation: presentation})
+
+	w.Header().Set("ETag", etag(presentation.Version))
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "Presentation restored to revision %v.", revisionID)
+}
+
+// Reset or flag the votes of the presentation if it changed materially since the previous revision,
+// depending on the policy configured in the metadata.
+func (h *presentationHandler) applyVotePolicy(ctx context.Context, presentation *Presentation, previous *PresentationRevision) error {
+	if !previous.IsMaterialChange(presentation) {
+		return nil
+	}
+
+	policy, err := h.MetadataStorage.GetData(ctx, votePolicyOnChangeKey)
+	if err == datastore.ErrNoSuchEntity {
+		policy = votePolicyKeep
+	} else if err != nil {
+		return err
+	}
+
+	switch policy {
+	case votePolicyReset:
+		presentation.Voters = nil
+		presentation.VotesFlagged = false
+	case votePolicyFlag:
+		if len(presentation.Voters) > 0 {
+			presentation.VotesFlagged = true
+		}
+	}
+
+	return nil
+}
+
+func (p *Presentation) NewRevision(saved time.Time) PresentationRevision {
+	return PresentationRevision{
+		Title:       p.Title,
+		Description: p.Description,
+		Speakers:    p.Speakers,
+		Saved:       saved,
+	}
+}
+
+// Check whether the content of the presentation differs from the revision at all.
+func (pr *PresentationRevision) Differs(p *Presentation) bool {
+	if pr.Title != p.Title || pr.Description != p.Description || len(pr.Speakers) != len(p.Speakers) {
+		return true
+	}
+	for i := range pr.Speakers {
+		if pr.Speakers[i] != p.Speakers[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Check whether the presentation changed enough since the revision for existing votes to be questionable.
+func (pr *PresentationRevision) IsMaterialChange(p *Presentation) bool {
+	if !strings.EqualFold(strings.TrimSpace(pr.Title), strings.TrimSpace(p.Title)) {
+		return true
+	}
+	if len(pr.Speakers) != len(p.Speakers) {
+		return true
+	}
+	for _, speaker := range p.Speakers {
+		if !contains(pr.Speakers, speaker) {
+			return true
+		}
+	}
+	return textSimilarity(pr.Description, p.Description) < materialChangeSimilarity
+}
+
+func (pr *PresentationRevision) GetPublicView(key int64) PresentationRevisionPublicView {
+	return PresentationRevisionPublicView{
+		Key:         key,
+		Title:       pr.Title,
+		Description: pr.Description,
+		Speakers:    pr.Speakers,
+		Saved:       pr.Saved,
+	}
+}
+
+func (pr *PresentationRevisionPublicView) WriteTo(w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, pr)
+}
+
+func (d *PresentationRevisionDiff) WriteTo(w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, d)
+}
+
+func WritePresentationRevisionsPublicView(revisions []PresentationRevisionPublicView, w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, revisions)
+}
@@ -0,0 +1,140 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+func newPresentationTestRouter(t *testing.T, store *MemoryStore) *mux.Router {
+	router := mux.NewRouter()
+	err := RegisterPresentationAPIRoutes(router, store, store, store, store, store, store, store, NewVoteGuard(NewMemoryRateLimiter(), store, store), NewPresentationBus(), NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func TestDiffLines(t *testing.T) {
+	diff := diffLines([]string{"a", "b", "c"}, []string{"a", "c", "d"})
+	expected := []DiffLine{{diffEqual, "a"}, {diffDelete, "b"}, {diffEqual, "c"}, {diffInsert, "d"}}
+	if len(diff) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, diff)
+	}
+	for index := range expected {
+		if diff[index] != expected[index] {
+			t.Errorf("Expected %v, got %v", expected, diff)
+			break
+		}
+	}
+}
+
+func TestIsMaterialChange(t *testing.T) {
+	revision := PresentationRevision{Title: "Concurrency in Go", Description: "Channels, goroutines and the select statement.", Speakers: []string{"Jan Kowalski"}}
+
+	cases := []struct {
+		presentation Presentation
+		material     bool
+	}{
+		{Presentation{Title: " concurrency in go", Description: "Channels, goroutines and the select statement!", Speakers: []string{"Jan Kowalski"}}, false},
+		{Presentation{Title: "Rust", Description: revision.Description, Speakers: revision.Speakers}, true},
+		{Presentation{Title: revision.Title, Description: revision.Description, Speakers: []string{"Anna Nowak"}}, true},
+		{Presentation{Title: revision.Title, Description: "Something else entirely.", Speakers: revision.Speakers}, true},
+	}
+
+	for _, c := range cases {
+		if material := revision.IsMaterialChange(&c.presentation); material != c.material {
+			t.Errorf("Change to %+v should be material: %v, got %v", c.presentation, c.material, material)
+		}
+	}
+}
+
+func TestPresentationRevisions(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	owner := &user.User{Email: "owner@example.com"}
+
+	store.AddSpeaker(ctx, &Speaker{Name: "Jan", Surname: "Kowalski"})
+	store.AddSpeaker(ctx, &Speaker{Name: "Anna", Surname: "Nowak"})
+	ID, _ := store.AddPresentation(ctx, &Presentation{Owner: owner.Email, Title: "Go", Description: "Channels\nGoroutines", Speakers: []string{"Jan Kowalski"}})
+	url := fmt.Sprintf("/presentations/%v", ID)
+
+	response := serveAs(router, owner, "PUT", url, strings.NewReader(`{"Title": "Go 2", "Description": "Channels\nGenerics", "Speakers": "Jan Kowalski, Anna Nowak"}`))
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Owner should be able to update the presentation, got %v: %s", response.Code, response.Body)
+	}
+
+	response = serveAs(router, nil, "GET", url+"/revisions", nil)
+	var revisions []PresentationRevisionPublicView
+	json.NewDecoder(response.Body).Decode(&revisions)
+	if len(revisions) != 1 || revisions[0].Title != "Go" || len(revisions[0].Speakers) != 1 {
+		t.Fatalf("Expected the content before the update as a revision, got %+v", revisions)
+	}
+	revisionID := revisions[0].Key
+
+	response = serveAs(router, nil, "GET", fmt.Sprintf("%v/revisions/diff?from=%v", url, revisionID), nil)
+	var diff PresentationRevisionDiff
+	json.NewDecoder(response.Body).Decode(&diff)
+	if diff.From != revisionID || diff.To != 0 {
+		t.Errorf("Expected a diff from the revision to the current version, got %+v", diff)
+	}
+	if len(diff.Title) != 2 || diff.Title[0] != (DiffLine{diffDelete, "Go"}) || diff.Title[1] != (DiffLine{diffInsert, "Go 2"}) {
+		t.Errorf("Expected the title replaced, got %+v", diff.Title)
+	}
+	if len(diff.Description) != 3 || diff.Description[0].Op != diffEqual || diff.Description[1] != (DiffLine{diffDelete, "Goroutines"}) || diff.Description[2] != (DiffLine{diffInsert, "Generics"}) {
+		t.Errorf("Expected the second line of the description replaced, got %+v", diff.Description)
+	}
+	if len(diff.Speakers) != 2 || diff.Speakers[1] != (DiffLine{diffInsert, "Anna Nowak"}) {
+		t.Errorf("Expected a speaker added, got %+v", diff.Speakers)
+	}
+
+	restore := fmt.Sprintf("%v/revisions/%v/restore", url, revisionID)
+	response = serveAs(router, nil, "POST", restore, nil)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Anonymous users shouldn't restore revisions, got %v", response.Code)
+	}
+	response = serveAs(router, &user.User{Email: "other@example.com"}, "POST", restore, nil)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Only the owner should restore revisions, got %v", response.Code)
+	}
+	response = serveAs(router, owner, "POST", restore, nil)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Owner should be able to restore the revision, got %v: %s", response.Code, response.Body)
+	}
+
+	presentation, _ := store.GetPresentation(ctx, ID)
+	if presentation.Title != "Go" || presentation.Description != "Channels\nGoroutines" || len(presentation.Speakers) != 1 {
+		t.Errorf("Expected the content of the revision, got %+v", presentation)
+	}
+	_, stored, _ := store.GetPresentationRevisions(ctx, ID)
+	if len(stored) != 2 || stored[1].Title != "Go 2" {
+		t.Errorf("Restoring should keep the replaced content as a revision too, got %+v", stored)
+	}
+}
+
+func TestDraftRevisionsHidden(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	owner := &user.User{Email: "owner@example.com"}
+
+	ID, _ := store.AddPresentation(ctx, &Presentation{Owner: owner.Email, Title: "Go 2", Status: StatusDraft})
+	store.PutPresentationRevision(ctx, ID, 1, &PresentationRevision{Title: "Go"})
+	url := fmt.Sprintf("/presentations/%v/revisions", ID)
+
+	for _, path := range []string{url, url + "/1", url + "/diff?from=1"} {
+		if response := serveAs(router, &user.User{Email: "other@example.com"}, "GET", path, nil); response.Code != http.StatusNotFound {
+			t.Errorf("Revisions of drafts shouldn't be shown to others at %v, got %v", path, response.Code)
+		}
+		if response := serveAs(router, owner, "GET", path, nil); response.Code != http.StatusOK {
+			t.Errorf("Revisions of drafts should be shown to their owner at %v, got %v", path, response.Code)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+type PresentationStatus = api.PresentationStatus
+
+const (
+	StatusDraft       = api.StatusDraft
+	StatusSubmitted   = api.StatusSubmitted
+	StatusUnderReview = api.StatusUnderReview
+	StatusAccepted    = api.StatusAccepted
+	StatusRejected    = api.StatusRejected
+	StatusScheduled   = api.StatusScheduled
+	StatusDelivered   = api.StatusDelivered
+	StatusWithdrawn   = api.StatusWithdrawn
+)
+
+var presentationStatuses = []PresentationStatus{StatusDraft, StatusSubmitted, StatusUnderReview, StatusAccepted, StatusRejected, StatusScheduled, StatusDelivered, StatusWithdrawn}
+
+// Who may move a presentation into a status.
+type statusRole int
+
+const (
+	// The owner of the presentation, or an admin.
+	roleSpeaker statusRole = iota
+	// An admin or the owner of the meetup the presentation was submitted to.
+	roleOrganizer
+)
+
+type statusTransition struct {
+	To   PresentationStatus
+	Role statusRole
+}
+
+// The allowed transitions out of every status. Delivered is final.
+var statusTransitions = map[PresentationStatus][]statusTransition{
+	StatusDraft:       {{StatusSubmitted, roleSpeaker}, {StatusWithdrawn, roleSpeaker}},
+	StatusSubmitted:   {{StatusUnderReview, roleOrganizer}, {StatusWithdrawn, roleSpeaker}},
+	StatusUnderReview: {{StatusAccepted, roleOrganizer}, {StatusRejected, roleOrganizer}, {StatusWithdrawn, roleSpeaker}},
+	StatusAccepted:    {{StatusScheduled, roleOrganizer}, {StatusWithdrawn, roleSpeaker}},
+	StatusRejected:    {{StatusUnderReview, roleOrganizer}},
+	StatusScheduled:   {{StatusDelivered, roleOrganizer}, {StatusWithdrawn, roleSpeaker}},
+	StatusWithdrawn:   {{StatusSubmitted, roleSpeaker}},
+}
+
+type PresentationStatusForm = api.PresentationStatusForm
+
+func isPresentationStatus(status PresentationStatus) bool {
+	for _, known := range presentationStatuses {
+		if status == known {
+			return true
+		}
+	}
+	return false
+}
+
+// The status of the presentation. Presentations stored before the workflow existed count as submitted.
+func (p *Presentation) GetStatus() PresentationStatus {
+	if p.Status == "" {
+		return StatusSubmitted
+	}
+	return p.Status
+}
+
+// The statuses the presentation can be moved to next.
+func (p *Presentation) NextStatuses() []PresentationStatus {
+	next := make([]PresentationStatus, 0)
+	for _, transition := range statusTransitions[p.GetStatus()] {
+		next = append(next, transition.To)
+	}
+	return next
+}
+
+// The status of the presentation once it's selected for a meetup. Selecting accepts it, and schedules it
+// if it was accepted already.
+func (p *Presentation) selectedStatus() PresentationStatus {
+	switch p.GetStatus() {
+	case StatusSubmitted, StatusUnderReview:
+		return StatusAccepted
+	case StatusAccepted:
+		return StatusScheduled
+	}
+	return p.GetStatus()
+}
+
+// Parse a comma separated list of statuses, like the status query parameter of the presentation list.
+func parseStatuses(list string) ([]PresentationStatus, error) {
+	statuses := make([]PresentationStatus, 0)
+	for _, item := range strings.Split(list, ",") {
+		status := PresentationStatus(strings.TrimSpace(item))
+		if status == "" {
+			continue
+		}
+		if !isPresentationStatus(status) {
+			return nil, fmt.Errorf("Status not valid: %v", status)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Whether the user organizes the meetup the presentation was submitted to. Anyone can create a meetup and
+// add any presentation to it, so only the meetup the speaker picked counts.
+func (h *presentationHandler) isOrganizer(ctx context.Context, u *user.User, presentation *Presentation) (bool, error) {
+	if u.Admin {
+		return true, nil
+	}
+	if presentation.MeetupID == 0 {
+		return false, nil
+	}
+	meetup, err := h.MeetupStorage.GetMeetup(ctx, presentation.MeetupID)
+	if err == datastore.ErrNoSuchEntity {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return meetup.Owner == u.Email, nil
+}
+
+// Drafts are only seen by their owner and the admins.
+func (p *Presentation) IsVisibleTo(u *user.User) bool {
+	if p.GetStatus() != StatusDraft {
+		return true
+	}
+	return u != nil && (p.Owner == u.Email || u.Admin)
+}
+
+// Drafts aren't submitted yet, and withdrawn and rejected presentations won't be given, so they aren't voted on.
+func (p *Presentation) IsVotable() bool {
+	switch p.GetStatus() {
+	case StatusDraft, StatusWithdrawn, StatusRejected:
+		return false
+	}
+	return true
+}
+
+func (h *presentationHandler) SetStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
+	}
+
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/presentation/%v/", ID))
+		writeLoginRequired(w, url)
+		return
+	}
+
+	form := PresentationStatusForm{}
+	err = json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
+	}
+	if !isPresentationStatus(form.Status) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Status not valid: %v", form.Status))
+		return
+	}
+
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
+		return
+	}
+
+	var transition *statusTransition
+	for _, allowed := range statusTransitions[presentation.GetStatus()] {
+		if allowed.To == form.Status {
+			transition = &allowed
+			break
+		}
+	}
+	if transition == nil {
+		writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+			Message: fmt.Sprintf("A presentation can't go from %v to %v.", presentation.GetStatus(), form.Status),
+			Details: presentation.NextStatuses(),
+		})
+		return
+	}
+
+	switch transition.Role {
+	case roleSpeaker:
+		if presentation.Owner != u.Email && !u.Admin {
+			writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
+			return
+		}
+	case roleOrganizer:
+		organizer, err := h.isOrganizer(ctx, u, &presentation)
+		if err != nil {
+			log.Errorf(ctx, "Can't get meetups: %v", err)
+			writeInternalError(w)
+			return
+		}
+		if !organizer {
+			writeError(w, http.StatusForbidden, "You have to be admin or the owner of the meetup of the presentation.")
+			return
+		}
+	}
+
+	if !checkIfMatch(w, r, etag(presentation.Version)) {
+		return
+	}
+
+	presentation.Status = form.Status
+	presentation.StatusChanged = time.Now()
+	err = h.PresentationStorage.PutPresentation(ctx, ID, &presentation)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't put presentation into datastore: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	h.Events.Publish(ctx, PresentationUpdated{ID: ID, Presentation: presentation})
+
+	w.Header().Set("ETag", etag(presentation.Version))
+	fmt.Fprintf(w, "Presentation is now %v.", presentation.Status)
+}
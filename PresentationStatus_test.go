@@ -0,0 +1,93 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	for from, transitions := range statusTransitions {
+		if !isPresentationStatus(from) {
+			t.Errorf("Unknown status %v has transitions.", from)
+		}
+		for _, transition := range transitions {
+			if !isPresentationStatus(transition.To) || transition.To == from {
+				t.Errorf("Transition from %v to %v not valid.", from, transition.To)
+			}
+		}
+	}
+
+	presentation := Presentation{}
+	if presentation.GetStatus() != StatusSubmitted {
+		t.Errorf("Presentations without a status should be submitted, got %v", presentation.GetStatus())
+	}
+	presentation.Status = StatusDelivered
+	if len(presentation.NextStatuses()) != 0 {
+		t.Errorf("Delivered should be final, got %v", presentation.NextStatuses())
+	}
+}
+
+func TestParseStatuses(t *testing.T) {
+	statuses, err := parseStatuses("accepted, scheduled,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0] != StatusAccepted || statuses[1] != StatusScheduled {
+		t.Errorf("Statuses not parsed: %v", statuses)
+	}
+
+	_, err = parseStatuses("accepted,published")
+	if err == nil {
+		t.Error("Unknown status should not be parsed.")
+	}
+}
+
+func TestPresentationStatusRoles(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	speaker := &user.User{Email: "speaker@example.com"}
+	organizer := &user.User{Email: "organizer@example.com"}
+	other := &user.User{Email: "other@example.com"}
+
+	meetupID, _ := store.AddMeetup(ctx, &Meetup{Owner: organizer.Email, Title: "March"})
+	ID, _ := store.AddPresentation(ctx, &Presentation{Owner: speaker.Email, Title: "Go", MeetupID: meetupID, Status: StatusUnderReview})
+	draftID, _ := store.AddPresentation(ctx, &Presentation{Owner: speaker.Email, Title: "Rust", Status: StatusDraft})
+	store.AddMeetup(ctx, &Meetup{Owner: other.Email, Title: "April", Presentations: []int64{ID}})
+
+	url := fmt.Sprintf("/presentations/%v/status", ID)
+	if response := serveAs(router, other, "PUT", url, strings.NewReader(`{"Status": "accepted"}`)); response.Code != http.StatusForbidden {
+		t.Errorf("Owners of meetups the presentation was only added to shouldn't accept it, got %v", response.Code)
+	}
+	if response := serveAs(router, speaker, "PUT", fmt.Sprintf("/presentations/%v/vote", draftID), nil); response.Code != http.StatusConflict {
+		t.Errorf("Drafts shouldn't be upvoted, got %v", response.Code)
+	}
+	if response := serveAs(router, other, "PUT", fmt.Sprintf("/presentations/%v/vote", ID), nil); response.Code != http.StatusOK {
+		t.Errorf("Presentations under review should be upvoted, got %v", response.Code)
+	}
+	if response := serveAs(router, organizer, "PUT", url, strings.NewReader(`{"Status": "accepted"}`)); response.Code != http.StatusOK {
+		t.Fatalf("The organizer of the meetup should accept the presentation, got %v: %s", response.Code, response.Body)
+	}
+
+	for _, c := range []struct {
+		u     *user.User
+		draft bool
+	}{{nil, false}, {other, false}, {speaker, true}} {
+		response := serveAs(router, c.u, "GET", "/presentations", nil)
+		var presentations []PresentationPublicView
+		json.NewDecoder(response.Body).Decode(&presentations)
+		if shown := len(presentations) == 2; shown != c.draft {
+			t.Errorf("Draft shown to %v: %v, got %+v", c.u, c.draft, presentations)
+		}
+		response = serveAs(router, c.u, "GET", fmt.Sprintf("/presentations/%v", draftID), nil)
+		if found := response.Code == http.StatusOK; found != c.draft {
+			t.Errorf("Draft found by %v: %v, got %v", c.u, c.draft, response.Code)
+		}
+	}
+}
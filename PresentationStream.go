@@ -0,0 +1,145 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+)
+
+const (
+	presentationEventVotes   = "votes"
+	presentationEventAdded   = "added"
+	presentationEventUpdated = "updated"
+	presentationEventDeleted = "deleted"
+	// Many presentations changed at once, the client should load the list again.
+	presentationEventReload = "reload"
+)
+
+// Events a stream may fall behind by before it's closed. The client reconnects and reloads the list.
+const presentationStreamBuffer = 32
+
+// Comment sent when nothing happened for that long, so proxies don't drop the connection.
+const presentationStreamKeepAlive = 20 * time.Second
+
+// What the stream tells about a change of a presentation. It doesn't carry the presentation itself, the speakers
+// may be hidden from some of the listeners by the blind review, so added and updated presentations are fetched again.
+type PresentationEvent struct {
+	// votes, added, updated, deleted or reload.
+	Kind           string
+	PresentationID int64 `json:",omitempty"`
+	Votes          int   `json:",omitempty"`
+}
+
+// Fans the presentation events out to the open streams. It's in-process,
+// so a stream only hears about the changes made through the same instance.
+type PresentationBus struct {
+	mutex       sync.Mutex
+	subscribers map[chan PresentationEvent]bool
+}
+
+func NewPresentationBus() *PresentationBus {
+	return &PresentationBus{subscribers: make(map[chan PresentationEvent]bool)}
+}
+
+// Subscribe to the events published from now on. The channel is closed by cancel, or if the subscriber falls behind.
+func (b *PresentationBus) Subscribe() (<-chan PresentationEvent, func()) {
+	events := make(chan PresentationEvent, presentationStreamBuffer)
+	b.mutex.Lock()
+	b.subscribers[events] = true
+	b.mutex.Unlock()
+
+	return events, func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		if b.subscribers[events] {
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// Send the event to all the subscribers without waiting for them.
+func (b *PresentationBus) Publish(event PresentationEvent) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// Forward the changes of the presentations to the open streams. Drafts are only seen by their owners, so they're left out.
+func subscribePresentationStream(Events *EventBus, Bus *PresentationBus) {
+	Events.Subscribe("stream", DeliverSync, func(ctx context.Context, event Event) error {
+		switch event := event.(type) {
+		case PresentationCreated:
+			if event.Presentation.GetStatus() != StatusDraft {
+				Bus.Publish(PresentationEvent{Kind: presentationEventAdded, PresentationID: event.ID, Votes: len(event.Presentation.Voters)})
+			}
+		case PresentationUpdated:
+			if event.Presentation.GetStatus() != StatusDraft {
+				Bus.Publish(PresentationEvent{Kind: presentationEventUpdated, PresentationID: event.ID, Votes: len(event.Presentation.Voters)})
+			}
+		case PresentationVoted:
+			Bus.Publish(PresentationEvent{Kind: presentationEventVotes, PresentationID: event.ID, Votes: len(event.Presentation.Voters)})
+		case PresentationDeleted:
+			Bus.Publish(PresentationEvent{Kind: presentationEventDeleted, PresentationID: event.ID})
+		case TrashRestored, ArchiveImported, VotesInvalidated:
+			Bus.Publish(PresentationEvent{Kind: presentationEventReload})
+		}
+		return nil
+	})
+}
+
+// Push the vote counts and changes of the presentations as server-sent events, named by the kind of the event.
+func (h *presentationHandler) StreamPresentations(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Streaming isn't supported here, poll the list with If-None-Match instead.")
+		return
+	}
+
+	events, cancel := h.Bus.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(presentationStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Errorf(ctx, "Failed to encode presentation event: %v", err)
+				return
+			}
+			fmt.Fprintf(w, "event: %v\ndata: %s\n\n", event.Kind, data)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package MeetupRest
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestPresentationBus(t *testing.T) {
+	bus := NewPresentationBus()
+	events, cancel := bus.Subscribe()
+	slow, _ := bus.Subscribe()
+
+	bus.Publish(PresentationEvent{Kind: presentationEventVotes, PresentationID: 1, Votes: 3})
+	event := <-events
+	if event.PresentationID != 1 || event.Votes != 3 {
+		t.Errorf("Expected the published event, got %+v", event)
+	}
+
+	for index := 0; index < presentationStreamBuffer; index++ {
+		bus.Publish(PresentationEvent{Kind: presentationEventVotes, PresentationID: 2})
+		<-events
+	}
+	// The other subscriber never read, so it was closed once its buffer was full.
+	for range slow {
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Cancelling should close the channel")
+	}
+	cancel()
+	bus.Publish(PresentationEvent{Kind: presentationEventDeleted, PresentationID: 1})
+}
+
+func TestPresentationStreamSubscriber(t *testing.T) {
+	Events := NewEventBus()
+	bus := NewPresentationBus()
+	subscribePresentationStream(Events, bus)
+	events, cancel := bus.Subscribe()
+	defer cancel()
+
+	Events.Publish(context.Background(),
+		PresentationCreated{ID: 1, Presentation: Presentation{Title: "Draft", Status: StatusDraft}},
+		PresentationCreated{ID: 2, Presentation: Presentation{Title: "Go", Status: StatusSubmitted}},
+		PresentationVoted{ID: 2, Presentation: Presentation{Voters: []string{"a", "b"}}},
+		PresentationUpdated{ID: 2, Presentation: Presentation{Title: "Go 2", Status: StatusSubmitted, Voters: []string{"a", "b"}}},
+		VotesInvalidated{Presentations: []int64{2}},
+	)
+
+	expected := []PresentationEvent{
+		{Kind: presentationEventAdded, PresentationID: 2},
+		{Kind: presentationEventVotes, PresentationID: 2, Votes: 2},
+		{Kind: presentationEventUpdated, PresentationID: 2, Votes: 2},
+		{Kind: presentationEventReload},
+	}
+	for _, e := range expected {
+		if event := <-events; event != e {
+			t.Errorf("Expected %+v, got %+v", e, event)
+		}
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected the draft left out, got %v more events", len(events))
+	}
+}
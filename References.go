@@ -0,0 +1,213 @@
+package MeetupRest
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// Cross-group transactions may only touch 25 entity groups, so cascades are split into
+// transactions of this many dependents each.
+const cascadeBatchSize = 20
+
+type TransactionStore interface {
+	// Run f in a transaction. Store calls made with the context passed to f are part of the transaction.
+	RunInTransaction(ctx context.Context, f func(ctx context.Context) error) error
+}
+
+// Entities referencing the one about to be deleted.
+type Dependents struct {
+	Presentations []int64
+	Meetups       []int64
+}
+
+func (d *Dependents) Empty() bool {
+	return len(d.Presentations) == 0 && len(d.Meetups) == 0
+}
+
+// Check whether the request asks for dependents to be deleted along with the entity.
+func isCascade(r *http.Request) bool {
+	return r.URL.Query().Get("cascade") == "true"
+}
+
+// Find the presentations given by the speaker, and the meetups those presentations are part of.
+func findSpeakerDependents(ctx context.Context, speakerName string, PresentationStorage PresentationStore, MeetupStorage MeetupStore) (Dependents, error) {
+	dependents := Dependents{Presentations: []int64{}, Meetups: []int64{}}
+
+	IDs, presentations, err := PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		return dependents, err
+	}
+	for index, presentation := range presentations {
+		if contains(presentation.Speakers, speakerName) {
+			dependents.Presentations = append(dependents.Presentations, IDs[index])
+		}
+	}
+	if len(dependents.Presentations) == 0 {
+		return dependents, nil
+	}
+
+	dependents.Meetups, err = findPresentationDependents(ctx, dependents.Presentations, MeetupStorage)
+	return dependents, err
+}
+
+// Find the meetups containing any of the presentations.
+func findPresentationDependents(ctx context.Context, presentationIDs []int64, MeetupStorage MeetupStore) ([]int64, error) {
+	meetupIDs := []int64{}
+
+	IDs, meetups, err := MeetupStorage.GetAllMeetups(ctx)
+	if err != nil {
+		return meetupIDs, err
+	}
+	for index, meetup := range meetups {
+		for _, presentationID := range presentationIDs {
+			if containsID(meetup.Presentations, presentationID) {
+				meetupIDs = append(meetupIDs, IDs[index])
+				break
+			}
+		}
+	}
+
+	return meetupIDs, nil
+}
+
+// Run f for the IDs in batches of cascadeBatchSize, each in its own transaction. Returns the events of the
+// committed batches, to be published even if a later batch fails.
+func inBatches(ctx context.Context, TransactionStorage TransactionStore, IDs []int64, f func(ctx context.Context, batch []int64) ([]Event, error)) ([]Event, error) {
+	events := make([]Event, 0)
+	for start := 0; start < len(IDs); start += cascadeBatchSize {
+		end := start + cascadeBatchSize
+		if end > len(IDs) {
+			end = len(IDs)
+		}
+
+		var batchEvents []Event
+		err := TransactionStorage.RunInTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			batchEvents, err = f(ctx, IDs[start:end])
+			return err
+		})
+		if err != nil {
+			return events, err
+		}
+		events = append(events, batchEvents...)
+	}
+	return events, nil
+}
+
+// Remove the speaker from its presentations, deleting the ones left without speakers, and detach those from
+// their meetups. The dependents are looked up again and each one is checked in its transaction, so running
+// it once more after the speaker is deleted catches the presentations which named it in the meantime.
+func cascadeSpeakerDelete(ctx context.Context, speakerName string, PresentationStorage PresentationStore, MeetupStorage MeetupStore, TransactionStorage TransactionStore) ([]Event, error) {
+	dependents, err := findSpeakerDependents(ctx, speakerName, PresentationStorage, MeetupStorage)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := inBatches(ctx, TransactionStorage, dependents.Presentations, func(ctx context.Context, batch []int64) ([]Event, error) {
+		events := make([]Event, 0, len(batch))
+		for _, presentationID := range batch {
+			presentation, err := PresentationStorage.GetPresentation(ctx, presentationID)
+			if err == datastore.ErrNoSuchEntity {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			speakers := make([]string, 0, len(presentation.Speakers))
+			for _, item := range presentation.Speakers {
+				if item != speakerName {
+					speakers = append(speakers, item)
+				}
+			}
+			if len(speakers) == len(presentation.Speakers) {
+				continue
+			}
+
+			// Presentations left without speakers get deleted, the others just lose this one.
+			if len(speakers) == 0 {
+				err = PresentationStorage.DeletePresentation(ctx, presentationID)
+				events = append(events, PresentationDeleted{ID: presentationID})
+			} else {
+				presentation.Speakers = speakers
+				err = PresentationStorage.PutPresentation(ctx, presentationID, &presentation)
+				events = append(events, PresentationUpdated{ID: presentationID, Presentation: presentation})
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return events, nil
+	})
+	if err != nil {
+		return events, err
+	}
+
+	deleted := make([]int64, 0)
+	for _, event := range events {
+		if event, ok := event.(PresentationDeleted); ok {
+			deleted = append(deleted, event.ID)
+		}
+	}
+	detached, err := detachFromMeetups(ctx, deleted, MeetupStorage, TransactionStorage)
+	return append(events, detached...), err
+}
+
+// Remove the presentations from all the meetups containing them.
+func detachFromMeetups(ctx context.Context, presentationIDs []int64, MeetupStorage MeetupStore, TransactionStorage TransactionStore) ([]Event, error) {
+	if len(presentationIDs) == 0 {
+		return nil, nil
+	}
+	meetupIDs, err := findPresentationDependents(ctx, presentationIDs, MeetupStorage)
+	if err != nil {
+		return nil, err
+	}
+	return inBatches(ctx, TransactionStorage, meetupIDs, func(ctx context.Context, batch []int64) ([]Event, error) {
+		return detachPresentations(ctx, MeetupStorage, batch, presentationIDs)
+	})
+}
+
+// Remove the presentations from the given meetups. Meant to be run in a transaction, the events of the
+// changed meetups are to be published once it's committed.
+func detachPresentations(ctx context.Context, MeetupStorage MeetupStore, meetupIDs []int64, presentationIDs []int64) ([]Event, error) {
+	events := make([]Event, 0, len(meetupIDs))
+	for _, meetupID := range meetupIDs {
+		meetup, err := MeetupStorage.GetMeetup(ctx, meetupID)
+		if err == datastore.ErrNoSuchEntity {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := make([]int64, 0, len(meetup.Presentations))
+		for _, presentationID := range meetup.Presentations {
+			if !containsID(presentationIDs, presentationID) {
+				remaining = append(remaining, presentationID)
+			}
+		}
+		if len(remaining) == len(meetup.Presentations) {
+			continue
+		}
+		meetup.Presentations = remaining
+		meetup.pruneAgenda()
+
+		err = MeetupStorage.PutMeetup(ctx, meetupID, &meetup)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, MeetupUpdated{ID: meetupID, Meetup: meetup})
+	}
+	return events, nil
+}
+
+func containsID(slice []int64, ID int64) bool {
+	for _, item := range slice {
+		if item == ID {
+			return true
+		}
+	}
+	return false
+}
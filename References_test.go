@@ -0,0 +1,191 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+type writeCounterKey struct{}
+
+// Counts the entities written in each transaction, to check the cascades stay within the limit.
+type writeCountingStore struct {
+	*MemoryStore
+	maxWrites int
+}
+
+func (s *writeCountingStore) RunInTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	writes := 0
+	err := s.MemoryStore.RunInTransaction(ctx, func(ctx context.Context) error {
+		return f(context.WithValue(ctx, writeCounterKey{}, &writes))
+	})
+	if writes > s.maxWrites {
+		s.maxWrites = writes
+	}
+	return err
+}
+
+func (s *writeCountingStore) count(ctx context.Context) {
+	if writes, ok := ctx.Value(writeCounterKey{}).(*int); ok {
+		*writes++
+	}
+}
+
+func (s *writeCountingStore) PutPresentation(ctx context.Context, ID int64, presentation *Presentation) error {
+	s.count(ctx)
+	return s.MemoryStore.PutPresentation(ctx, ID, presentation)
+}
+
+func (s *writeCountingStore) DeletePresentation(ctx context.Context, ID int64) error {
+	s.count(ctx)
+	return s.MemoryStore.DeletePresentation(ctx, ID)
+}
+
+func (s *writeCountingStore) PutMeetup(ctx context.Context, ID int64, meetup *Meetup) error {
+	s.count(ctx)
+	return s.MemoryStore.PutMeetup(ctx, ID, meetup)
+}
+
+func TestCascadingDelete(t *testing.T) {
+	ctx := context.Background()
+	store := &writeCountingStore{MemoryStore: NewMemoryStore()}
+	router := mux.NewRouter()
+	err := RegisterSpeakerAPIRoutes(router, store, store, store, store, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RegisterPresentationAPIRoutes(router, store, store, store, store, store, store, store, NewVoteGuard(NewMemoryRateLimiter(), store, store), NewPresentationBus(), NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := &user.User{Email: "owner@example.com"}
+	admin := &user.User{Email: "admin@example.com", Admin: true}
+
+	speakerID, _ := store.AddSpeaker(ctx, &Speaker{Owner: owner.Email, Name: "Jan", Surname: "Kowalski"})
+	store.AddSpeaker(ctx, &Speaker{Name: "Anna", Surname: "Nowak"})
+	sharedID, _ := store.AddPresentation(ctx, &Presentation{Title: "Shared", Speakers: []string{"Jan Kowalski", "Anna Nowak"}})
+	soloIDs := make([]int64, 0)
+	meetupIDs := make([]int64, 0)
+	// More than a batch of both, so the cascade has to be split.
+	for index := 0; index < 2*cascadeBatchSize+5; index++ {
+		ID, _ := store.AddPresentation(ctx, &Presentation{Title: fmt.Sprint(index), Speakers: []string{"Jan Kowalski"}})
+		soloIDs = append(soloIDs, ID)
+		meetupID, _ := store.AddMeetup(ctx, &Meetup{Title: fmt.Sprint(index), Presentations: []int64{ID, sharedID}})
+		meetupIDs = append(meetupIDs, meetupID)
+	}
+
+	url := fmt.Sprintf("/speakers/%v", speakerID)
+	if response := serveAs(router, admin, "DELETE", url, nil); response.Code != http.StatusConflict {
+		t.Errorf("Referenced speaker shouldn't be deleted without cascade, got %v", response.Code)
+	}
+	if response := serveAs(router, owner, "DELETE", url+"?cascade=true", nil); response.Code != http.StatusForbidden {
+		t.Errorf("Only admins should cascade deletes, got %v", response.Code)
+	}
+	if _, err := store.GetSpeaker(ctx, speakerID); err != nil {
+		t.Fatalf("Speaker shouldn't be deleted yet: %v", err)
+	}
+
+	response := serveAs(router, admin, "DELETE", url+"?cascade=true", nil)
+	if response.Code != http.StatusTeapot {
+		t.Fatalf("Admin should be able to cascade the delete, got %v: %s", response.Code, response.Body)
+	}
+	if store.maxWrites > cascadeBatchSize {
+		t.Errorf("Expected at most %v writes per transaction, got %v", cascadeBatchSize, store.maxWrites)
+	}
+	if _, err := store.GetSpeaker(ctx, speakerID); err == nil {
+		t.Error("Speaker should be deleted")
+	}
+	for _, ID := range soloIDs {
+		if _, err := store.GetPresentation(ctx, ID); err == nil {
+			t.Errorf("Presentation %v left without speakers should be deleted", ID)
+		}
+	}
+	shared, _ := store.GetPresentation(ctx, sharedID)
+	if len(shared.Speakers) != 1 || shared.Speakers[0] != "Anna Nowak" {
+		t.Errorf("Expected only the other speaker left, got %v", shared.Speakers)
+	}
+	for _, ID := range meetupIDs {
+		meetup, _ := store.GetMeetup(ctx, ID)
+		if len(meetup.Presentations) != 1 || meetup.Presentations[0] != sharedID {
+			t.Errorf("Expected the deleted presentations detached from meetup %v, got %v", ID, meetup.Presentations)
+		}
+	}
+
+	url = fmt.Sprintf("/presentations/%v", sharedID)
+	if response := serveAs(router, admin, "DELETE", url, nil); response.Code != http.StatusConflict {
+		t.Errorf("Presentation in meetups shouldn't be deleted without cascade, got %v", response.Code)
+	}
+	if response := serveAs(router, admin, "DELETE", url+"?cascade=true", nil); response.Code != http.StatusTeapot {
+		t.Fatalf("Admin should be able to cascade the delete, got %v: %s", response.Code, response.Body)
+	}
+	for _, ID := range meetupIDs {
+		meetup, _ := store.GetMeetup(ctx, ID)
+		if len(meetup.Presentations) != 0 {
+			t.Errorf("Expected the presentation detached from meetup %v, got %v", ID, meetup.Presentations)
+		}
+	}
+}
+
+// Changes an entity right before the first transaction, as if another request got in between.
+type racingStore struct {
+	*MemoryStore
+	race func()
+}
+
+func (s *racingStore) RunInTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if s.race != nil {
+		s.race()
+		s.race = nil
+	}
+	return s.MemoryStore.RunInTransaction(ctx, f)
+}
+
+func TestCascadingDeleteLosesVersionRace(t *testing.T) {
+	ctx := context.Background()
+	store := &racingStore{MemoryStore: NewMemoryStore()}
+	router := mux.NewRouter()
+	err := RegisterSpeakerAPIRoutes(router, store, store, store, store, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RegisterPresentationAPIRoutes(router, store, store, store, store, store, store, store, NewVoteGuard(NewMemoryRateLimiter(), store, store), NewPresentationBus(), NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &user.User{Email: "admin@example.com", Admin: true}
+
+	speakerID, _ := store.AddSpeaker(ctx, &Speaker{Name: "Jan", Surname: "Kowalski"})
+	presentationID, _ := store.AddPresentation(ctx, &Presentation{Title: "Go", Speakers: []string{"Jan Kowalski"}})
+	meetupID, _ := store.AddMeetup(ctx, &Meetup{Title: "March", Presentations: []int64{presentationID}})
+
+	store.race = func() {
+		speaker, _ := store.GetSpeaker(ctx, speakerID)
+		speaker.Company = "Gophers"
+		store.PutSpeaker(ctx, speakerID, &speaker)
+	}
+	if response := serveAs(router, admin, "DELETE", fmt.Sprintf("/speakers/%v?cascade=true", speakerID), nil); response.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected the speaker delete to lose the race, got %v", response.Code)
+	}
+	if _, err := store.GetSpeaker(ctx, speakerID); err != nil {
+		t.Errorf("Speaker shouldn't be deleted: %v", err)
+	}
+	if presentation, err := store.GetPresentation(ctx, presentationID); err != nil || len(presentation.Speakers) != 1 {
+		t.Errorf("Presentation shouldn't be touched, got %+v, %v", presentation, err)
+	}
+
+	store.race = func() {
+		presentation, _ := store.GetPresentation(ctx, presentationID)
+		presentation.Title = "Go 2"
+		store.PutPresentation(ctx, presentationID, &presentation)
+	}
+	if response := serveAs(router, admin, "DELETE", fmt.Sprintf("/presentations/%v?cascade=true", presentationID), nil); response.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected the presentation delete to lose the race, got %v", response.Code)
+	}
+	if meetup, _ := store.GetMeetup(ctx, meetupID); len(meetup.Presentations) != 1 {
+		t.Errorf("Presentation shouldn't be detached from the meetup, got %v", meetup.Presentations)
+	}
+}
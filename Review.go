@@ -0,0 +1,459 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+const datastoreReviewsKind = "Reviews"
+
+// Metadata key holding the comma separated emails of the reviewers. Admins can always review.
+const reviewersKey = "Reviewers"
+
+// Metadata key which hides the speakers from the reviewers when set to true.
+const blindReviewKey = "BlindReview"
+
+// The private scores a reviewer gave a presentation, separate from the public votes.
+type Review struct {
+	Reviewer  string
+	Relevance int
+	Clarity   int
+	Novelty   int
+	Comment   string `datastore:",noindex"`
+	Updated   time.Time
+}
+
+type ReviewStore interface {
+	GetReview(ctx context.Context, presentationID int64, reviewer string) (Review, error)
+	GetReviews(ctx context.Context, presentationID int64) ([]Review, error)
+	// All the reviews, keyed by the presentation they belong to.
+	GetAllReviews(ctx context.Context) ([]int64, []Review, error)
+	PutReview(ctx context.Context, presentationID int64, review *Review) error
+}
+
+type ReviewForm = api.ReviewForm
+
+type ReviewPublicView = api.ReviewPublicView
+
+type ReviewScores = api.ReviewScores
+
+type PresentationReviewsPublicView = api.PresentationReviewsPublicView
+
+type ReviewQueueItem = api.ReviewQueueItem
+
+// Register the review routes of the versioned API to the router.
+func RegisterReviewAPIRoutes(m *mux.Router, ReviewStorage ReviewStore, PresentationStorage PresentationStore, MetadataStorage MetadataStore) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering review API routes")
+	}
+	h := reviewHandler{ReviewStorage: ReviewStorage, PresentationStorage: PresentationStorage, MetadataStorage: MetadataStorage}
+	m.HandleFunc("/presentations/{ID}/review", h.GetOwnReview).Methods("GET")
+	m.HandleFunc("/presentations/{ID}/review", h.PutReview).Methods("PUT")
+	m.HandleFunc("/presentations/{ID}/reviews", h.ListReviews).Methods("GET")
+	m.HandleFunc("/reviews/queue", h.Queue).Methods("GET")
+	m.HandleFunc("/reviews/scores", h.Scores).Methods("GET")
+
+	return nil
+}
+
+type reviewHandler struct {
+	ReviewStorage       ReviewStore
+	PresentationStorage PresentationStore
+	MetadataStorage     MetadataStore
+}
+
+// Check that the user is logged in and a reviewer, writing the error response if not.
+func (h *reviewHandler) checkReviewer(ctx context.Context, w http.ResponseWriter, r *http.Request) (*user.User, bool) {
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return nil, false
+	}
+	if u.Admin {
+		return u, true
+	}
+
+	reviewer, err := isReviewer(ctx, u, h.MetadataStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get reviewers: %v", err)
+		writeInternalError(w)
+		return nil, false
+	}
+	if reviewer {
+		return u, true
+	}
+
+	writeError(w, http.StatusForbidden, "You have to be a reviewer or admin.")
+	return nil, false
+}
+
+// Whether the user is on the list of reviewers. Admins review too, without being listed.
+func isReviewer(ctx context.Context, u *user.User, MetadataStorage MetadataStore) (bool, error) {
+	reviewers, err := MetadataStorage.GetData(ctx, reviewersKey)
+	if err == datastore.ErrNoSuchEntity {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, reviewer := range strings.Split(reviewers, ",") {
+		if strings.EqualFold(strings.TrimSpace(reviewer), u.Email) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func isBlindReview(ctx context.Context, MetadataStorage MetadataStore) (bool, error) {
+	value, err := MetadataStorage.GetData(ctx, blindReviewKey)
+	if err == datastore.ErrNoSuchEntity {
+		return false, nil
+	}
+	return value == "true", err
+}
+
+// Whether the speakers have to be hidden from the user, a listed reviewer during blind review.
+func hideSpeakersFrom(ctx context.Context, u *user.User, MetadataStorage MetadataStore) (bool, error) {
+	if u == nil || u.Admin {
+		return false, nil
+	}
+	blind, err := isBlindReview(ctx, MetadataStorage)
+	if err != nil || !blind {
+		return false, err
+	}
+	return isReviewer(ctx, u, MetadataStorage)
+}
+
+// Remove whatever tells who the speakers are, the answers to the call for papers may as well.
+func redactSpeakers(view *PresentationPublicView) {
+	view.Speakers = []SpeakerForPresentationPublicView{}
+	view.Answers = []string{}
+}
+
+// Get the presentation of the ID route variable, writing the error response if there is none.
+func (h *reviewHandler) getPresentation(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, Presentation, bool) {
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return 0, Presentation{}, false
+	}
+
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find presentation with id: %v", ID))
+		return 0, Presentation{}, false
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get presentation with key: %v, error: %v", ID, err)
+		writeInternalError(w)
+		return 0, Presentation{}, false
+	}
+	return ID, presentation, true
+}
+
+func (h *reviewHandler) GetOwnReview(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	u, ok := h.checkReviewer(ctx, w, r)
+	if !ok {
+		return
+	}
+	ID, _, ok := h.getPresentation(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	review, err := h.ReviewStorage.GetReview(ctx, ID, u.Email)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("You haven't reviewed presentation %v yet.", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get review: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, review.GetPublicView())
+	if err != nil {
+		log.Errorf(ctx, "Failed to write review: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func (h *reviewHandler) PutReview(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	u, ok := h.checkReviewer(ctx, w, r)
+	if !ok {
+		return
+	}
+	ID, presentation, ok := h.getPresentation(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	if presentation.Owner == u.Email {
+		writeError(w, http.StatusForbidden, "You can't review your own presentation.")
+		return
+	}
+	if status := presentation.GetStatus(); status == StatusDraft || status == StatusWithdrawn {
+		writeError(w, http.StatusConflict, fmt.Sprintf("The presentation is %v, it can't be reviewed.", status))
+		return
+	}
+
+	form := ReviewForm{}
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
+	}
+	fieldErrors := validateStruct(&form)
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return
+	}
+
+	review := Review{
+		Reviewer:  u.Email,
+		Relevance: form.Relevance,
+		Clarity:   form.Clarity,
+		Novelty:   form.Novelty,
+		Comment:   form.Comment,
+		Updated:   time.Now(),
+	}
+	err = h.ReviewStorage.PutReview(ctx, ID, &review)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't put review into datastore: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	fmt.Fprint(w, "Review saved.")
+}
+
+func (h *reviewHandler) ListReviews(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	_, ok := h.checkReviewer(ctx, w, r)
+	if !ok {
+		return
+	}
+	ID, presentation, ok := h.getPresentation(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	reviews, err := h.ReviewStorage.GetReviews(ctx, ID)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get reviews: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	view := PresentationReviewsPublicView{Scores: aggregateReviews(ID, presentation.Title, reviews), Reviews: make([]ReviewPublicView, 0, len(reviews))}
+	for _, review := range reviews {
+		view.Reviews = append(view.Reviews, review.GetPublicView())
+	}
+	sort.Slice(view.Reviews, func(i, j int) bool { return view.Reviews[i].Updated.After(view.Reviews[j].Updated) })
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, view)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write reviews: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+// The presentations in review the reviewer hasn't scored yet, oldest submissions first.
+func (h *reviewHandler) Queue(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	u, ok := h.checkReviewer(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	blind, err := isBlindReview(ctx, h.MetadataStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get blind review setting: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	IDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get presentations: %v", err)
+		writeInternalError(w)
+		return
+	}
+	reviewIDs, reviews, err := h.ReviewStorage.GetAllReviews(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get reviews: %v", err)
+		writeInternalError(w)
+		return
+	}
+	reviewed := make(map[int64]bool)
+	for index, review := range reviews {
+		if review.Reviewer == u.Email {
+			reviewed[reviewIDs[index]] = true
+		}
+	}
+
+	type queued struct {
+		item      ReviewQueueItem
+		submitted time.Time
+	}
+	queue := make([]queued, 0)
+	for index, presentation := range presentations {
+		status := presentation.GetStatus()
+		if reviewed[IDs[index]] || presentation.Owner == u.Email || (status != StatusSubmitted && status != StatusUnderReview) {
+			continue
+		}
+		item := ReviewQueueItem{
+			Key:         IDs[index],
+			Title:       presentation.Title,
+			Description: presentation.Description,
+			Speakers:    presentation.Speakers,
+			Answers:     presentation.Answers,
+			Status:      status,
+		}
+		if blind {
+			item.Speakers = []string{}
+			item.Answers = []string{}
+		}
+		queue = append(queue, queued{item: item, submitted: presentation.Submitted})
+	}
+	sort.SliceStable(queue, func(i, j int) bool { return queue[i].submitted.Before(queue[j].submitted) })
+
+	items := make([]ReviewQueueItem, 0, len(queue))
+	for _, entry := range queue {
+		items = append(items, entry.item)
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, items)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write review queue: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+// The mean scores of all the reviewed presentations, best first.
+func (h *reviewHandler) Scores(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	_, ok := h.checkReviewer(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	IDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get presentations: %v", err)
+		writeInternalError(w)
+		return
+	}
+	reviewIDs, reviews, err := h.ReviewStorage.GetAllReviews(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get reviews: %v", err)
+		writeInternalError(w)
+		return
+	}
+	reviewsByPresentation := make(map[int64][]Review)
+	for index, review := range reviews {
+		reviewsByPresentation[reviewIDs[index]] = append(reviewsByPresentation[reviewIDs[index]], review)
+	}
+
+	scores := make([]ReviewScores, 0)
+	for index, presentation := range presentations {
+		if len(reviewsByPresentation[IDs[index]]) == 0 {
+			continue
+		}
+		scores = append(scores, aggregateReviews(IDs[index], presentation.Title, reviewsByPresentation[IDs[index]]))
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Average > scores[j].Average })
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, scores)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write review scores: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func aggregateReviews(presentationID int64, title string, reviews []Review) ReviewScores {
+	scores := ReviewScores{PresentationID: presentationID, Title: title, Reviews: len(reviews)}
+	if len(reviews) == 0 {
+		return scores
+	}
+	for _, review := range reviews {
+		scores.Relevance += float64(review.Relevance)
+		scores.Clarity += float64(review.Clarity)
+		scores.Novelty += float64(review.Novelty)
+	}
+	count := float64(len(reviews))
+	scores.Relevance /= count
+	scores.Clarity /= count
+	scores.Novelty /= count
+	scores.Average = (scores.Relevance + scores.Clarity + scores.Novelty) / 3
+	return scores
+}
+
+func (r *Review) GetPublicView() ReviewPublicView {
+	return ReviewPublicView{
+		Reviewer:  r.Reviewer,
+		Relevance: r.Relevance,
+		Clarity:   r.Clarity,
+		Novelty:   r.Novelty,
+		Comment:   r.Comment,
+		Updated:   r.Updated,
+	}
+}
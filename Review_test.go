@@ -0,0 +1,104 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+func TestAggregateReviews(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	err := store.PutReview(ctx, 1, &Review{Reviewer: "a@example.com", Relevance: 5, Clarity: 4, Novelty: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.PutReview(ctx, 1, &Review{Reviewer: "b@example.com", Relevance: 3, Clarity: 2, Novelty: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Replaces the first review of the reviewer.
+	err = store.PutReview(ctx, 1, &Review{Reviewer: "b@example.com", Relevance: 3, Clarity: 4, Novelty: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reviews, err := store.GetReviews(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := aggregateReviews(1, "Title", reviews)
+	expected := ReviewScores{PresentationID: 1, Title: "Title", Reviews: 2, Relevance: 4, Clarity: 4, Novelty: 4, Average: 4}
+	if scores != expected {
+		t.Errorf("Scores should be %+v, got %+v", expected, scores)
+	}
+
+	IDs, reviews, err := store.GetAllReviews(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reviews) != 2 || IDs[0] != 1 || IDs[1] != 1 {
+		t.Errorf("All reviews should be keyed by their presentation: %v %+v", IDs, reviews)
+	}
+}
+
+func TestBlindReview(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	err := RegisterReviewAPIRoutes(router, store, store, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reviewer := &user.User{Email: "reviewer@example.com"}
+	store.PutData(ctx, reviewersKey, "Reviewer@example.com")
+	store.PutData(ctx, blindReviewKey, "true")
+
+	ID, _ := store.AddPresentation(ctx, &Presentation{Owner: "speaker@example.com", Title: "Go", Speakers: []string{"Jan Kowalski"}, Answers: []string{"I wrote the book."}})
+	url := fmt.Sprintf("/presentations/%v", ID)
+
+	for _, c := range []struct {
+		u       *user.User
+		visible bool
+	}{{nil, true}, {reviewer, false}} {
+		var presentation PresentationPublicView
+		json.NewDecoder(serveAs(router, c.u, "GET", url, nil).Body).Decode(&presentation)
+		var presentations []PresentationPublicView
+		json.NewDecoder(serveAs(router, c.u, "GET", "/presentations", nil).Body).Decode(&presentations)
+		if len(presentations) != 1 {
+			t.Fatalf("Expected the presentation listed, got %+v", presentations)
+		}
+		for _, view := range []PresentationPublicView{presentation, presentations[0]} {
+			if visible := len(view.Speakers) == 1 && len(view.Answers) == 1; visible != c.visible {
+				t.Errorf("Speakers visible to %v: %v, got %+v", c.u, c.visible, view)
+			}
+		}
+	}
+
+	request := httptest.NewRequest("GET", url, nil)
+	request.Header.Set("If-None-Match", serveAs(router, nil, "GET", url, nil).Header().Get("ETag"))
+	if response := serveRequestAs(router, reviewer, request); response.Code != http.StatusOK {
+		t.Errorf("The ETag of the presentation with speakers shouldn't match the one without, got %v", response.Code)
+	}
+
+	var queue []ReviewQueueItem
+	json.NewDecoder(serveAs(router, reviewer, "GET", "/reviews/queue", nil).Body).Decode(&queue)
+	if len(queue) != 1 || len(queue[0].Speakers) != 0 || len(queue[0].Answers) != 0 {
+		t.Errorf("Expected the speakers and answers hidden in the queue, got %+v", queue)
+	}
+
+	for _, status := range []PresentationStatus{StatusDraft, StatusWithdrawn} {
+		draftID, _ := store.AddPresentation(ctx, &Presentation{Owner: "speaker@example.com", Title: "Rust", Status: status})
+		response := serveAs(router, reviewer, "PUT", fmt.Sprintf("/presentations/%v/review", draftID), strings.NewReader(`{"Relevance": 5, "Clarity": 5, "Novelty": 5}`))
+		if response.Code != http.StatusConflict {
+			t.Errorf("Presentations %v shouldn't be reviewed, got %v", status, response.Code)
+		}
+	}
+}
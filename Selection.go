@@ -0,0 +1,386 @@
+package MeetupRest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/mail"
+	"google.golang.org/appengine/user"
+)
+
+// Metadata key holding how many presentations a meetup gets when its voting closes.
+const selectionSizeKey = "SelectionSize"
+
+// Metadata key holding the comma separated rules breaking ties in the vote count, applied in order.
+const selectionTieBreakKey = "SelectionTieBreak"
+
+// Metadata key holding the sender of the emails to speakers. Has to be allowed to send mail from the application.
+const mailSenderKey = "MailSender"
+
+const defaultSelectionSize = 5
+
+const (
+	// Higher mean review score first.
+	tieBreakReviews = "reviews"
+	// Submitted earlier first.
+	tieBreakSubmitted = "submitted"
+	// Lower key first. Always applied last, so the selection doesn't depend on the order of the datastore.
+	tieBreakKey = "key"
+)
+
+var defaultTieBreaks = []string{tieBreakReviews, tieBreakSubmitted}
+
+type selectionCandidate struct {
+	ID           int64
+	Presentation Presentation
+	// The score of the tally in the voting mode of the meetup.
+	Votes int
+	// Mean review score, 0 if not reviewed.
+	Score float64
+}
+
+// Register the route selecting the presentations of the meetups whose voting closed to the router.
+func RegisterSelectionRoutes(m *mux.Router, MeetupStorage MeetupStore, PresentationStorage PresentationStore, ReviewStorage ReviewStore, BallotStorage BallotStore, MetadataStorage MetadataStore, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering selection routes")
+	}
+	h := selectionHandler{MeetupStorage: MeetupStorage, PresentationStorage: PresentationStorage, ReviewStorage: ReviewStorage, BallotStorage: BallotStorage, MetadataStorage: MetadataStorage, Events: Events}
+	m.HandleFunc("/run", h.SelectTalks).Methods("GET")
+
+	return nil
+}
+
+type selectionHandler struct {
+	MeetupStorage       MeetupStore
+	PresentationStorage PresentationStore
+	ReviewStorage       ReviewStore
+	BallotStorage       BallotStore
+	MetadataStorage     MetadataStore
+	Events              *EventBus
+}
+
+// Parse a comma separated list of tie-breaking rules, like the SelectionTieBreak metadata.
+func parseTieBreaks(list string) ([]string, error) {
+	rules := make([]string, 0)
+	for _, item := range strings.Split(list, ",") {
+		rule := strings.TrimSpace(item)
+		switch rule {
+		case "":
+			continue
+		case tieBreakReviews, tieBreakSubmitted, tieBreakKey:
+			rules = append(rules, rule)
+		default:
+			return nil, fmt.Errorf("Tie-breaking rule not valid: %v", rule)
+		}
+	}
+	return rules, nil
+}
+
+// Whether the voting of the meetup closed and its presentations haven't been selected yet.
+func (m *Meetup) SelectionDue(now time.Time) bool {
+	return !m.Deleted && !m.VoteTimeEnd.IsZero() && m.VoteTimeEnd.Before(now) && m.TalksSelected.IsZero()
+}
+
+// The presentations which can be selected for the meetup. With a call for papers those are its submissions,
+// otherwise the presentations which weren't submitted to nor attached to any meetup.
+// Drafts and presentations which were withdrawn, rejected or already delivered can't be selected.
+func selectionCandidates(meetupID int64, meetup *Meetup, IDs []int64, presentations []Presentation, meetups []Meetup) []selectionCandidate {
+	attached := make([]int64, 0)
+	for _, other := range meetups {
+		attached = append(attached, other.Presentations...)
+	}
+
+	candidates := make([]selectionCandidate, 0)
+	for index, presentation := range presentations {
+		switch presentation.GetStatus() {
+		case StatusDraft, StatusWithdrawn, StatusRejected, StatusDelivered:
+			continue
+		}
+		if containsID(attached, IDs[index]) {
+			continue
+		}
+		if meetup.CallForPapers.Start.IsZero() && presentation.MeetupID != 0 {
+			continue
+		}
+		if !meetup.CallForPapers.Start.IsZero() && presentation.MeetupID != meetupID {
+			continue
+		}
+		candidates = append(candidates, selectionCandidate{ID: IDs[index], Presentation: presentation})
+	}
+	return candidates
+}
+
+// Pick the count candidates with the most votes, breaking ties by the rules in order, and by the key last.
+func selectTalks(candidates []selectionCandidate, count int, rules []string) []selectionCandidate {
+	rules = append(append([]string(nil), rules...), tieBreakKey)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Votes != b.Votes {
+			return a.Votes > b.Votes
+		}
+		for _, rule := range rules {
+			switch {
+			case rule == tieBreakReviews && a.Score != b.Score:
+				return a.Score > b.Score
+			case rule == tieBreakSubmitted && !a.Presentation.Submitted.Equal(b.Presentation.Submitted):
+				return a.Presentation.Submitted.Before(b.Presentation.Submitted)
+			case rule == tieBreakKey && a.ID != b.ID:
+				return a.ID < b.ID
+			}
+		}
+		return false
+	})
+
+	if count > len(candidates) {
+		count = len(candidates)
+	}
+	if count < 0 {
+		count = 0
+	}
+	return candidates[:count]
+}
+
+// Select the presentations of every meetup whose voting closed, notify their speakers and sync meetup.com.
+// Presentations already attached to a meetup count towards its SelectionSize. Meant to be run by cron.
+func (h *selectionHandler) SelectTalks(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+
+	if r.Header.Get("X-Appengine-Cron") != "true" {
+		u := currentUser(ctx, r)
+		if u == nil {
+			url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+			writeLoginRequired(w, url)
+			return
+		}
+		if !u.Admin {
+			writeError(w, http.StatusForbidden, "You have to be admin.")
+			return
+		}
+	}
+
+	size, rules, err := h.getSelectionSettings(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get selection settings: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	now := time.Now()
+	meetupIDs, meetups, err := h.MeetupStorage.GetAllMeetups(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get meetups: %v", err)
+		writeInternalError(w)
+		return
+	}
+	due := false
+	for _, meetup := range meetups {
+		due = due || meetup.SelectionDue(now)
+	}
+	if !due {
+		fmt.Fprint(w, "No meetup is due for selection.")
+		return
+	}
+
+	presentationIDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get presentations: %v", err)
+		writeInternalError(w)
+		return
+	}
+	byID := make(map[int64]Presentation, len(presentationIDs))
+	for index, presentationID := range presentationIDs {
+		byID[presentationID] = presentations[index]
+	}
+	scores := make(map[int64]float64)
+	if contains(rules, tieBreakReviews) {
+		reviewedIDs, reviews, err := h.ReviewStorage.GetAllReviews(ctx)
+		if err != nil {
+			log.Errorf(ctx, "Can't get reviews: %v", err)
+			writeInternalError(w)
+			return
+		}
+		byPresentation := make(map[int64][]Review)
+		for index, review := range reviews {
+			byPresentation[reviewedIDs[index]] = append(byPresentation[reviewedIDs[index]], review)
+		}
+		for ID, reviews := range byPresentation {
+			scores[ID] = aggregateReviews(ID, "", reviews).Average
+		}
+	}
+
+	selectedMeetups := 0
+	selectedPresentations := 0
+	for index := range meetups {
+		meetup := meetups[index]
+		if !meetup.SelectionDue(now) {
+			continue
+		}
+
+		candidates := selectionCandidates(meetupIDs[index], &meetup, presentationIDs, presentations, meetups)
+		ballots, err := h.BallotStorage.GetBallots(ctx, meetupIDs[index])
+		if err != nil {
+			log.Errorf(ctx, "Can't get ballots: %v", err)
+			writeInternalError(w)
+			return
+		}
+		candidateIDs := make([]int64, 0, len(candidates))
+		for _, candidate := range candidates {
+			candidateIDs = append(candidateIDs, candidate.ID)
+		}
+		votes := make(map[int64]int, len(candidates))
+		for _, result := range tallyVotes(&meetup, candidateIDs, byID, ballots).Results {
+			votes[result.PresentationID] = result.Score
+		}
+		for i := range candidates {
+			candidates[i].Votes = votes[candidates[i].ID]
+			candidates[i].Score = scores[candidates[i].ID]
+		}
+		selected := selectTalks(candidates, size-len(meetup.Presentations), rules)
+
+		for _, candidate := range selected {
+			meetup.Presentations = append(meetup.Presentations, candidate.ID)
+		}
+		meetup.TalksSelected = now
+		err = h.MeetupStorage.PutMeetup(ctx, meetupIDs[index], &meetup)
+		if err == ErrVersionConflict {
+			log.Warningf(ctx, "Meetup %v changed during the selection, selecting on the next run.", meetupIDs[index])
+			continue
+		}
+		if err != nil {
+			log.Errorf(ctx, "Can't put meetup: %v", err)
+			writeInternalError(w)
+			return
+		}
+		// Later meetups may not select the same presentations.
+		meetups[index] = meetup
+		selectedMeetups++
+		selectedPresentations += len(selected)
+
+		events := []Event{MeetupUpdated{ID: meetupIDs[index], Meetup: meetup}}
+		for _, candidate := range selected {
+			presentation, err := h.acceptSelected(ctx, candidate.ID, now)
+			if err != nil {
+				log.Errorf(ctx, "Can't set the status of presentation %v: %v", candidate.ID, err)
+				presentation = candidate.Presentation
+			} else {
+				events = append(events, PresentationUpdated{ID: candidate.ID, Presentation: presentation})
+			}
+			events = append(events, PresentationSelected{MeetupID: meetupIDs[index], Meetup: meetup, PresentationID: candidate.ID, Presentation: presentation})
+		}
+		h.Events.Publish(ctx, events...)
+	}
+
+	fmt.Fprintf(w, "Selected %v presentations for %v meetups.", selectedPresentations, selectedMeetups)
+}
+
+// Move the selected presentation on in the review workflow, see selectedStatus.
+func (h *selectionHandler) acceptSelected(ctx context.Context, ID int64, now time.Time) (Presentation, error) {
+	presentation, err := h.PresentationStorage.GetPresentation(ctx, ID)
+	if err != nil {
+		return presentation, err
+	}
+	status := presentation.selectedStatus()
+	if status == presentation.GetStatus() {
+		return presentation, nil
+	}
+	presentation.Status = status
+	presentation.StatusChanged = now
+	err = h.PresentationStorage.PutPresentation(ctx, ID, &presentation)
+	return presentation, err
+}
+
+func (h *selectionHandler) getSelectionSettings(ctx context.Context) (int, []string, error) {
+	size := defaultSelectionSize
+	value, err := h.MetadataStorage.GetData(ctx, selectionSizeKey)
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		return 0, nil, err
+	}
+	if err == nil {
+		size, err = strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return 0, nil, fmt.Errorf("Selection size not valid: %v", value)
+		}
+	}
+
+	rules := defaultTieBreaks
+	value, err = h.MetadataStorage.GetData(ctx, selectionTieBreakKey)
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		return 0, nil, err
+	}
+	if err == nil {
+		rules, err = parseTieBreaks(value)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return size, rules, nil
+}
+
+// Build the function emailing the owner and the speakers of a presentation that it was selected for the meetup.
+// Speakers are reached at the email of their speaker entity, if they have one.
+func getSpeakerNotifyFunction(SpeakerStorage SpeakerStore, MetadataStorage MetadataStore) func(context.Context, Meetup, Presentation) error {
+	return func(ctx context.Context, meetup Meetup, presentation Presentation) error {
+		recipients := make([]string, 0, len(presentation.Speakers)+1)
+		if presentation.Owner != "" {
+			recipients = append(recipients, presentation.Owner)
+		}
+		_, speakers, err := SpeakerStorage.GetAllSpeakers(ctx)
+		if err != nil {
+			return err
+		}
+		for _, speaker := range speakers {
+			if speaker.Email != "" && contains(presentation.Speakers, speaker.GetSpeakerFullName()) && !contains(recipients, speaker.Email) {
+				recipients = append(recipients, speaker.Email)
+			}
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+
+		sender, err := MetadataStorage.GetData(ctx, mailSenderKey)
+		if err == datastore.ErrNoSuchEntity {
+			sender = fmt.Sprintf("MeetupRest <noreply@%v.appspotmail.com>", appengine.AppID(ctx))
+		} else if err != nil {
+			return err
+		}
+
+		date := "soon"
+		if !meetup.Date.IsZero() {
+			date = "on " + meetup.Date.Format("Monday, 2 January 2006 at 15:04")
+		}
+		return mail.Send(ctx, &mail.Message{
+			Sender:  sender,
+			To:      recipients,
+			Subject: fmt.Sprintf("%q was selected for %v", presentation.Title, meetup.Title),
+			Body: fmt.Sprintf("Congratulations!\n\nVoting for %v closed and your presentation %q made it. The meetup takes place %v.\n",
+				meetup.Title, presentation.Title, date),
+		})
+	}
+}
+
+// Notify the speakers of the selected presentations.
+func subscribeSpeakerNotifications(Events *EventBus, notify func(context.Context, Meetup, Presentation) error) {
+	Events.Subscribe("notifications", DeliverAsync, func(ctx context.Context, event Event) error {
+		selected, ok := event.(PresentationSelected)
+		if !ok {
+			return nil
+		}
+		err := notify(ctx, selected.Meetup, selected.Presentation)
+		if err != nil {
+			return fmt.Errorf("Couldn't notify the speakers of presentation %v: %v", selected.PresentationID, err)
+		}
+		return nil
+	})
+}
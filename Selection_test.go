@@ -0,0 +1,107 @@
+package MeetupRest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+func TestSelectTalks(t *testing.T) {
+	start := time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)
+	candidates := func() []selectionCandidate {
+		return []selectionCandidate{
+			{ID: 1, Presentation: Presentation{Submitted: start.Add(time.Hour)}, Votes: 1, Score: 2},
+			{ID: 2, Presentation: Presentation{Submitted: start}, Votes: 3},
+			{ID: 3, Presentation: Presentation{Submitted: start}, Votes: 1, Score: 1},
+			{ID: 4, Presentation: Presentation{Submitted: start}, Votes: 1, Score: 1},
+		}
+	}
+
+	cases := []struct {
+		count    int
+		rules    []string
+		selected []int64
+	}{
+		{3, []string{tieBreakReviews, tieBreakSubmitted}, []int64{2, 1, 3}},
+		{3, []string{tieBreakSubmitted, tieBreakReviews}, []int64{2, 3, 4}},
+		{2, []string{}, []int64{2, 1}},
+		{10, nil, []int64{2, 1, 3, 4}},
+		{-1, nil, []int64{}},
+	}
+
+	for _, c := range cases {
+		selected := selectTalks(candidates(), c.count, c.rules)
+		IDs := make([]int64, 0, len(selected))
+		for _, candidate := range selected {
+			IDs = append(IDs, candidate.ID)
+		}
+		if len(IDs) != len(c.selected) {
+			t.Errorf("Selecting %v with %v should give %v, got %v", c.count, c.rules, c.selected, IDs)
+			continue
+		}
+		for index := range IDs {
+			if IDs[index] != c.selected[index] {
+				t.Errorf("Selecting %v with %v should give %v, got %v", c.count, c.rules, c.selected, IDs)
+				break
+			}
+		}
+	}
+}
+
+func TestSelectionCandidates(t *testing.T) {
+	withCFP := Meetup{CallForPapers: CallForPapers{Start: time.Now()}}
+	IDs := []int64{1, 2, 3, 4, 5}
+	presentations := []Presentation{
+		{MeetupID: 7},
+		{MeetupID: 7, Status: StatusWithdrawn},
+		{},
+		{MeetupID: 8},
+		{},
+	}
+	meetups := []Meetup{{Presentations: []int64{5}}}
+
+	for _, c := range []struct {
+		meetup   Meetup
+		expected []int64
+	}{
+		{withCFP, []int64{1}},
+		{Meetup{}, []int64{3}},
+	} {
+		candidates := selectionCandidates(7, &c.meetup, IDs, presentations, meetups)
+		if len(candidates) != len(c.expected) || candidates[0].ID != c.expected[0] {
+			t.Errorf("Candidates should be %v, got %+v", c.expected, candidates)
+		}
+	}
+}
+
+func TestSelectionAcceptsPresentations(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := mux.NewRouter()
+	err := RegisterSelectionRoutes(router, store, store, store, store, store, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submittedID, _ := store.AddPresentation(ctx, &Presentation{Title: "Go", Status: StatusUnderReview})
+	acceptedID, _ := store.AddPresentation(ctx, &Presentation{Title: "Rust", Status: StatusAccepted})
+	meetupID, _ := store.AddMeetup(ctx, &Meetup{Title: "March", VoteTimeEnd: time.Now().Add(-time.Hour)})
+
+	response := serveAs(router, &user.User{Email: "admin@example.com", Admin: true}, "GET", "/run", nil)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Admin should run the selection, got %v: %s", response.Code, response.Body)
+	}
+	meetup, _ := store.GetMeetup(ctx, meetupID)
+	if len(meetup.Presentations) != 2 {
+		t.Fatalf("Expected both presentations selected, got %v", meetup.Presentations)
+	}
+	for ID, status := range map[int64]PresentationStatus{submittedID: StatusAccepted, acceptedID: StatusScheduled} {
+		if presentation, _ := store.GetPresentation(ctx, ID); presentation.Status != status {
+			t.Errorf("Expected presentation %v to be %v, got %v", ID, status, presentation.Status)
+		}
+	}
+}
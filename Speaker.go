@@ -10,9 +10,10 @@ import (
 	"golang.org/x/net/context"
 
 	"strconv"
+	"time"
 
+	"github.com/cube2222/MeetupRest/api"
 	"github.com/gorilla/mux"
-	"google.golang.org/appengine"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/log"
 	"google.golang.org/appengine/user"
@@ -21,30 +22,21 @@ import (
 const datastoreSpeakersKind = "Speakers"
 
 type Speaker struct {
-	Owner   string
-	Name    string
-	Surname string
-	About   string
-	Email   string
-	Company string
+	Owner     string
+	Name      string
+	Surname   string
+	About     string `datastore:",noindex"`
+	Email     string
+	Company   string
+	Deleted   bool
+	DeletedAt time.Time
+	// Incremented on every write, the ETag of the speaker.
+	Version int64
 }
 
-type SpeakerPublicView struct {
-	Key     int64
-	Name    string
-	Surname string
-	About   string
-	Email   string
-	Company string
-}
+type SpeakerPublicView = api.SpeakerPublicView
 
-type SpeakerForm struct {
-	Name    string
-	Surname string
-	About   string
-	Email   string
-	Company string
-}
+type SpeakerForm = api.SpeakerForm
 
 type SpeakerStore interface {
 	GetSpeaker(ctx context.Context, id int64) (Speaker, error)
@@ -52,15 +44,18 @@ type SpeakerStore interface {
 	PutSpeaker(ctx context.Context, id int64, speaker *Speaker) error
 	AddSpeaker(ctx context.Context, speaker *Speaker) (int64, error)
 	DeleteSpeaker(ctx context.Context, id int64) error
+	GetDeletedSpeakers(ctx context.Context) ([]int64, []Speaker, error)
+	UndeleteSpeaker(ctx context.Context, id int64) error
+	PurgeSpeaker(ctx context.Context, id int64) error
 	GetSpeakerIdByName(ctx context.Context, name string) (int64, error)
 }
 
 // Get the handler which contains all the speaker handling routes and the corresponding handlers.
-func RegisterSpeakerRoutes(m *mux.Router, SpeakerStorage SpeakerStore) error {
+func RegisterSpeakerRoutes(m *mux.Router, SpeakerStorage SpeakerStore, PresentationStorage PresentationStore, MeetupStorage MeetupStore, TransactionStorage TransactionStore, Events *EventBus) error {
 	if m == nil {
 		return errors.New("m may not be nil when registering speaker routes")
 	}
-	h := speakerHandler{SpeakerStorage: SpeakerStorage}
+	h := speakerHandler{SpeakerStorage: SpeakerStorage, PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage, TransactionStorage: TransactionStorage, Events: Events}
 	m.HandleFunc("/{ID}/", h.GetSpeaker).Methods("GET")
 	m.HandleFunc("/", h.AddSpeaker).Methods("POST")
 	m.HandleFunc("/list", h.ListSpeakers).Methods("GET")
@@ -71,158 +66,186 @@ func RegisterSpeakerRoutes(m *mux.Router, SpeakerStorage SpeakerStore) error {
 	return nil
 }
 
+// Register the RESTful speaker routes of the versioned API to the router.
+func RegisterSpeakerAPIRoutes(m *mux.Router, SpeakerStorage SpeakerStore, PresentationStorage PresentationStore, MeetupStorage MeetupStore, TransactionStorage TransactionStore, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering speaker API routes")
+	}
+	h := speakerHandler{SpeakerStorage: SpeakerStorage, PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage, TransactionStorage: TransactionStorage, Events: Events}
+	m.HandleFunc("/speakers", h.ListSpeakers).Methods("GET")
+	m.HandleFunc("/speakers", h.AddSpeaker).Methods("POST")
+	m.HandleFunc("/speakers/{ID}", h.GetSpeaker).Methods("GET")
+	m.HandleFunc("/speakers/{ID}", h.UpdateSpeaker).Methods("PUT", "PATCH")
+	m.HandleFunc("/speakers/{ID}", h.DeleteSpeaker).Methods("DELETE")
+
+	return nil
+}
+
 type speakerHandler struct {
-	SpeakerStorage SpeakerStore
+	SpeakerStorage      SpeakerStore
+	PresentationStorage PresentationStore
+	MeetupStorage       MeetupStore
+	TransactionStorage  TransactionStore
+	Events              *EventBus
 }
 
 func (h *speakerHandler) GetSpeaker(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
 		return
 	}
 
 	speaker, err := h.SpeakerStorage.GetSpeaker(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		w.WriteHeader(http.StatusNotFound)
-		fmt.Fprintf(w, "Couldn't find speaker with id: %v", ID)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find speaker with id: %v", ID))
 		return
 	}
 	if err != nil {
 		log.Errorf(ctx, "Couldn't get speaker: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	if checkIfNoneMatch(w, r, etag(speaker.Version)) {
 		return
 	}
 
 	speakerPublicView := speaker.GetPublicView(ID)
-	err = speakerPublicView.WriteTo(w)
+	err = encoder.Encode(w, &speakerPublicView)
 	if err != nil {
 		log.Errorf(ctx, "Failed to write speaker: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 }
 
 func (h *speakerHandler) AddSpeaker(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprint("/public/#/add_speaker"))
-		fmt.Fprintf(w, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
-	speaker := Speaker{}
-	err := json.NewDecoder(r.Body).Decode(&speaker)
+	saf := SpeakerForm{}
+	err := json.NewDecoder(r.Body).Decode(&saf)
 	if err != nil {
-		log.Errorf(ctx, "Couldn't decode JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
 		return
 	}
 
-	if speaker.Name == "" || speaker.Surname == "" || speaker.Email == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprint(w, "Fields Name, Surname and Email are mandatory!")
+	fieldErrors := validateSpeakerForm(&saf)
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
 		return
 	}
 
+	speaker := Speaker{}
+	speaker.ApplyForm(&saf)
 	speaker.Owner = u.Email
 
 	id, err := h.SpeakerStorage.AddSpeaker(ctx, &speaker)
 	if err != nil {
 		log.Errorf(ctx, "Can't create datastore object: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
+	h.Events.Publish(ctx, SpeakerCreated{ID: id, Speaker: speaker})
 
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprintf(w, "%v", id)
 }
 
 func (h *speakerHandler) UpdateSpeaker(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		//Make you sure that ulr is correct.
 		url, _ := user.LoginURL(ctx, fmt.Sprint("/public/#/update_speaker"))
-		fmt.Fprint(w, url)
-		return
-	}
-
-	suf := SpeakerForm{}
-	err = json.NewDecoder(r.Body).Decode(&suf)
-	log.Debugf(ctx, "Body: %s", r.Body)
-	if err != nil {
-		log.Errorf(ctx, "Couldn't decode JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	speaker, err := h.SpeakerStorage.GetSpeaker(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		fmt.Fprint(w, "No speaker with ID: %v", ID)
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No speaker with ID: %v", ID))
 		return
 	}
 	// Some other error
 	if err != nil {
 		log.Errorf(ctx, "Can't get speaker: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	// Check if it's the owner
 	if speaker.Owner != u.Email && !u.Admin {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, "You're not the owner nor the admin.")
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
 		return
 	}
-	//TODO: Update speaker with function.
-	if suf.Name != "" {
-		speaker.Name = suf.Name
-	}
 
-	if suf.Surname != "" {
-		speaker.Surname = suf.Surname
+	if !checkIfMatch(w, r, etag(speaker.Version)) {
+		return
 	}
 
-	if suf.Email != "" {
-		speaker.Email = suf.Email
+	form := speaker.GetForm()
+	err = decodeUpdate(r, &form)
+	if err == errUnsupportedPatchType {
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
 	}
-
-	if suf.Company != "" {
-		speaker.Company = suf.Company
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
 	}
 
-	if suf.About != "" {
-		speaker.About = suf.About
+	fieldErrors := validateSpeakerForm(&form)
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return
 	}
 
+	speaker.ApplyForm(&form)
+
 	err = h.SpeakerStorage.PutSpeaker(ctx, ID, &speaker)
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
 		log.Errorf(ctx, "Can't create datastore object: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	h.Events.Publish(ctx, SpeakerUpdated{ID: ID, Speaker: speaker})
+
+	w.Header().Set("ETag", etag(speaker.Version))
 	w.WriteHeader(http.StatusCreated)
 	fmt.Fprint(w, "Speaker updated.")
 }
@@ -244,77 +267,185 @@ func updateSpeakerForm(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *speakerHandler) DeleteSpeaker(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	vars := mux.Vars(r)
 	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, "ID not valid: %v", vars["ID"])
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
 	}
 
-	u := user.Current(ctx)
+	u := currentUser(ctx, r)
 	if u == nil {
 		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/delete_speaker/%v/", ID))
-		fmt.Fprintf(w, url)
+		writeLoginRequired(w, url)
 		return
 	}
 
 	speaker, err := h.SpeakerStorage.GetSpeaker(ctx, ID)
 	if err == datastore.ErrNoSuchEntity {
-		fmt.Fprint(w, "Speaker not found.")
+		writeError(w, http.StatusNotFound, "Speaker not found.")
 		return
 	}
 	if err != nil {
 		log.Errorf(ctx, "Can't get speaker: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	if speaker.Owner != u.Email && !u.Admin {
-		w.WriteHeader(http.StatusUnauthorized)
-		fmt.Fprint(w, "You're not the owner nor the admin.")
+		writeError(w, http.StatusForbidden, "You're not the owner nor the admin.")
 		return
 	}
 
-	err = h.SpeakerStorage.DeleteSpeaker(ctx, ID)
+	if !checkIfMatch(w, r, etag(speaker.Version)) {
+		return
+	}
+
+	cascade := isCascade(r)
+	if cascade && !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin to cascade deletes.")
+		return
+	}
+
+	speakerName := speaker.GetSpeakerFullName()
+	dependents, err := findSpeakerDependents(ctx, speakerName, h.PresentationStorage, h.MeetupStorage)
+	if err != nil {
+		log.Errorf(ctx, "Can't get speaker dependents: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	if !cascade && !dependents.Empty() {
+		writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+			Message: "The speaker is still referenced. Remove the references or delete with cascade=true.",
+			Details: dependents,
+		})
+		return
+	}
+
+	// The speaker is deleted first, so nothing is touched if somebody changed it in the meantime.
+	err = h.TransactionStorage.RunInTransaction(ctx, func(ctx context.Context) error {
+		current, err := h.SpeakerStorage.GetSpeaker(ctx, ID)
+		if err != nil {
+			return err
+		}
+		if current.Version != speaker.Version {
+			return ErrVersionConflict
+		}
+		return h.SpeakerStorage.DeleteSpeaker(ctx, ID)
+	})
+	if err == ErrVersionConflict {
+		writeVersionConflict(w)
+		return
+	}
 	if err != nil {
 		log.Errorf(ctx, "Can't delete speaker: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	// The cascade runs in batches, so that a busy speaker doesn't go past the entity group limit of a transaction.
+	// The committed ones are published even if a later step fails.
+	events := []Event{SpeakerDeleted{ID: ID}}
+	defer func() {
+		h.Events.Publish(ctx, events...)
+	}()
+
+	// Now that the speaker is deleted no presentation can name it anymore, so its dependents are looked up
+	// again. They're either cleaned up or, if some named it since they were checked, the delete is undone.
+	if cascade {
+		more, err := cascadeSpeakerDelete(ctx, speakerName, h.PresentationStorage, h.MeetupStorage, h.TransactionStorage)
+		events = append(events, more...)
+		if err != nil {
+			log.Errorf(ctx, "Can't delete speaker dependents: %v", err)
+			writeInternalError(w)
+			return
+		}
+	} else {
+		dependents, err = findSpeakerDependents(ctx, speakerName, h.PresentationStorage, h.MeetupStorage)
+		if err == nil && !dependents.Empty() {
+			err = h.SpeakerStorage.UndeleteSpeaker(ctx, ID)
+			if err == nil {
+				events = nil
+				writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+					Message: "The speaker is still referenced. Remove the references or delete with cascade=true.",
+					Details: dependents,
+				})
+				return
+			}
+		}
+		if err != nil {
+			log.Errorf(ctx, "Can't check speaker dependents: %v", err)
+			writeInternalError(w)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusTeapot)
 	fmt.Fprint(w, "Speaker deleted successfully.")
 }
 
 func (h *speakerHandler) ListSpeakers(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
 	defer done()
 
 	IDs, speakers, err := h.SpeakerStorage.GetAllSpeakers(ctx)
 	if err != nil {
 		log.Errorf(ctx, "Can't get speakers: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
+	etags := make([]string, 0, len(speakers))
 	speakersPublicView := make([]SpeakerPublicView, 0, len(speakers))
 	for index, speaker := range speakers {
+		etags = append(etags, etag(speaker.Version))
 		speakersPublicView = append(speakersPublicView, speaker.GetPublicView(IDs[index]))
 	}
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
 
-	err = WriteSpeakersPublicView(speakersPublicView, w)
+	if checkIfNoneMatch(w, r, listETag(encoder, IDs, etags)) {
+		return
+	}
+
+	err = WriteSpeakersPublicView(speakersPublicView, w, encoder)
 	if err != nil {
 		log.Errorf(ctx, "Failed to write speakers slice: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 }
 
+func validateSpeakerForm(f *SpeakerForm) []FieldError {
+	return validateStruct(f)
+}
+
+func (s *Speaker) ApplyForm(f *SpeakerForm) {
+	s.Name = f.Name
+	s.Surname = f.Surname
+	s.About = f.About
+	s.Email = f.Email
+	s.Company = f.Company
+}
+
+func (s *Speaker) GetForm() SpeakerForm {
+	return SpeakerForm{
+		Name:    s.Name,
+		Surname: s.Surname,
+		About:   s.About,
+		Email:   s.Email,
+		Company: s.Company,
+	}
+}
+
 func (speaker *Speaker) GetSpeakerFullName() string {
 	return fmt.Sprintf("%v %v", speaker.Name, speaker.Surname)
 }
@@ -330,17 +461,10 @@ func (s *Speaker) GetPublicView(key int64) SpeakerPublicView {
 	}
 }
 
-func (s *Speaker) WriteTo(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(s)
-}
-
-func (s *SpeakerPublicView) WriteTo(w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(s)
+func (s *Speaker) WriteTo(w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, s)
 }
 
-func WriteSpeakersPublicView(speakers []SpeakerPublicView, w io.Writer) error {
-	e := json.NewEncoder(w)
-	return e.Encode(speakers)
+func WriteSpeakersPublicView(speakers []SpeakerPublicView, w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, speakers)
 }
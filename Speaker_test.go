@@ -4,16 +4,18 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/net/context"
 	"google.golang.org/appengine/aetest"
-	"google.golang.org/cloud/datastore"
+	"google.golang.org/appengine/datastore"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
+	"time"
 )
 
 type speakerStoreMock struct {
 	storage      map[int64]Speaker
+	trash        map[int64]Speaker
 	storageMutex sync.RWMutex
 	indexCounter int64
 }
@@ -43,8 +45,12 @@ func (s *speakerStoreMock) GetAllSpeakers(ctx context.Context) ([]int64, []Speak
 
 func (s *speakerStoreMock) PutSpeaker(ctx context.Context, id int64, speaker *Speaker) error {
 	s.storageMutex.Lock()
+	defer s.storageMutex.Unlock()
+	if stored, ok := s.storage[id]; ok && stored.Version != speaker.Version {
+		return ErrVersionConflict
+	}
+	speaker.Version++
 	s.storage[id] = *speaker
-	s.storageMutex.Unlock()
 	return nil
 }
 
@@ -52,6 +58,7 @@ func (s *speakerStoreMock) AddSpeaker(ctx context.Context, speaker *Speaker) (in
 	s.storageMutex.Lock()
 	for _, ok := s.storage[s.indexCounter]; ok; s.indexCounter++ {
 	}
+	speaker.Version = 1
 	s.storage[s.indexCounter] = *speaker
 	s.indexCounter++
 	s.storageMutex.Unlock()
@@ -60,13 +67,66 @@ func (s *speakerStoreMock) AddSpeaker(ctx context.Context, speaker *Speaker) (in
 
 func (s *speakerStoreMock) DeleteSpeaker(ctx context.Context, id int64) error {
 	s.storageMutex.Lock()
+	defer s.storageMutex.Unlock()
+	speaker, ok := s.storage[id]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	speaker.Deleted = true
+	speaker.Version++
+	speaker.DeletedAt = time.Now()
+	s.trash[id] = speaker
 	delete(s.storage, id)
+	return nil
+}
+
+func (s *speakerStoreMock) GetDeletedSpeakers(ctx context.Context) ([]int64, []Speaker, error) {
+	s.storageMutex.RLock()
+	speakers := make([]Speaker, 0, len(s.trash))
+	IDs := make([]int64, 0, len(s.trash))
+	for key, value := range s.trash {
+		IDs = append(IDs, key)
+		speakers = append(speakers, value)
+	}
+	s.storageMutex.RUnlock()
+	return IDs, speakers, nil
+}
+
+func (s *speakerStoreMock) UndeleteSpeaker(ctx context.Context, id int64) error {
+	s.storageMutex.Lock()
+	defer s.storageMutex.Unlock()
+	speaker, ok := s.trash[id]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	speaker.Deleted = false
+	speaker.Version++
+	speaker.DeletedAt = time.Time{}
+	s.storage[id] = speaker
+	delete(s.trash, id)
+	return nil
+}
+
+func (s *speakerStoreMock) PurgeSpeaker(ctx context.Context, id int64) error {
+	s.storageMutex.Lock()
+	delete(s.trash, id)
 	s.storageMutex.Unlock()
 	return nil
 }
 
+func (s *speakerStoreMock) GetSpeakerIdByName(ctx context.Context, name string) (int64, error) {
+	s.storageMutex.RLock()
+	defer s.storageMutex.RUnlock()
+	for key, value := range s.storage {
+		if value.GetSpeakerFullName() == name {
+			return key, nil
+		}
+	}
+	return 0, datastore.ErrNoSuchEntity
+}
+
 func NewSpeakerStoreMock() *speakerStoreMock {
-	return &speakerStoreMock{storage: make(map[int64]Speaker), storageMutex: sync.RWMutex{}}
+	return &speakerStoreMock{storage: make(map[int64]Speaker), trash: make(map[int64]Speaker), storageMutex: sync.RWMutex{}}
 }
 
 func TestGetSpeaker(t *testing.T) {
@@ -76,7 +136,7 @@ func TestGetSpeaker(t *testing.T) {
 	}
 	defer inst.Close()
 	router := mux.NewRouter()
-	err = RegisterSpeakerRoutes(router, NewSpeakerStoreMock())
+	err = RegisterSpeakerRoutes(router, NewSpeakerStoreMock(), nil, nil, nil, nil)
 	if err != nil {
 		t.Error(err)
 	}
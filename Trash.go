@@ -0,0 +1,329 @@
+package MeetupRest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+// Metadata key holding the number of days deleted entities are kept in the trash before being purged.
+const trashRetentionDaysKey = "TrashRetentionDays"
+
+const defaultTrashRetentionDays = 30
+
+const (
+	trashKindSpeaker      = "speaker"
+	trashKindPresentation = "presentation"
+	trashKindMeetup       = "meetup"
+)
+
+type TrashItemPublicView struct {
+	Kind      string
+	Key       int64
+	Title     string
+	Owner     string
+	DeletedAt time.Time
+}
+
+// Register trash routes to the router
+func RegisterTrashRoutes(m *mux.Router, SpeakerStorage SpeakerStore, PresentationStorage PresentationStore, MeetupStorage MeetupStore, MetadataStorage MetadataStore, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering trash routes")
+	}
+	h := trashHandler{SpeakerStorage: SpeakerStorage, PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage, MetadataStorage: MetadataStorage, Events: Events}
+	m.HandleFunc("/", h.ListTrash).Methods("GET")
+	m.HandleFunc("/{Kind}/{ID}/undelete", h.Undelete).Methods("POST")
+	m.HandleFunc("/purge", h.Purge).Methods("GET")
+
+	return nil
+}
+
+// Register the RESTful trash routes of the versioned API to the router.
+func RegisterTrashAPIRoutes(m *mux.Router, SpeakerStorage SpeakerStore, PresentationStorage PresentationStore, MeetupStorage MeetupStore, MetadataStorage MetadataStore, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering trash API routes")
+	}
+	h := trashHandler{SpeakerStorage: SpeakerStorage, PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage, MetadataStorage: MetadataStorage, Events: Events}
+	m.HandleFunc("/trash", h.ListTrash).Methods("GET")
+	m.HandleFunc("/trash/{Kind}/{ID}/undelete", h.Undelete).Methods("POST")
+
+	return nil
+}
+
+type trashHandler struct {
+	SpeakerStorage      SpeakerStore
+	PresentationStorage PresentationStore
+	MeetupStorage       MeetupStore
+	MetadataStorage     MetadataStore
+	Events              *EventBus
+}
+
+func (h *trashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	items, err := h.getTrash(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get trash: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = WriteTrashPublicView(items, w, encoder)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write trash slice: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func (h *trashHandler) Undelete(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return
+	}
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	dangling, err := h.findDanglingReferences(ctx, vars["Kind"], ID)
+	if err != nil {
+		log.Errorf(ctx, "Can't check references of %v %v: %v", vars["Kind"], ID, err)
+		writeInternalError(w)
+		return
+	}
+	if !dangling.Empty() {
+		writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+			Message: fmt.Sprintf("The %v references entities which don't exist anymore. Restore them first.", vars["Kind"]),
+			Details: dangling,
+		})
+		return
+	}
+
+	switch vars["Kind"] {
+	case trashKindSpeaker:
+		err = h.SpeakerStorage.UndeleteSpeaker(ctx, ID)
+	case trashKindPresentation:
+		err = h.PresentationStorage.UndeletePresentation(ctx, ID)
+	case trashKindMeetup:
+		err = h.MeetupStorage.UndeleteMeetup(ctx, ID)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Kind not valid: %v", vars["Kind"]))
+		return
+	}
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find %v with id %v in the trash", vars["Kind"], ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Can't undelete %v: %v", vars["Kind"], err)
+		writeInternalError(w)
+		return
+	}
+
+	h.Events.Publish(ctx, TrashRestored{Kind: vars["Kind"], ID: ID})
+
+	fmt.Fprintf(w, "Restored %v %v from the trash.", vars["Kind"], ID)
+}
+
+// Purge everything that has been in the trash for longer than the retention period. Meant to be run by cron.
+func (h *trashHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+
+	if r.Header.Get("X-Appengine-Cron") != "true" && !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	retentionDays := defaultTrashRetentionDays
+	value, err := h.MetadataStorage.GetData(ctx, trashRetentionDaysKey)
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		log.Errorf(ctx, "Couldn't get trash retention: %v", err)
+		writeInternalError(w)
+		return
+	}
+	if err == nil {
+		retentionDays, err = strconv.Atoi(value)
+		if err != nil {
+			log.Errorf(ctx, "Trash retention not valid: %v", value)
+			writeInternalError(w)
+			return
+		}
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	items, err := h.getTrash(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get trash: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	purged := 0
+	for _, item := range items {
+		if item.DeletedAt.After(cutoff) {
+			continue
+		}
+
+		switch item.Kind {
+		case trashKindSpeaker:
+			err = h.SpeakerStorage.PurgeSpeaker(ctx, item.Key)
+		case trashKindPresentation:
+			err = h.PresentationStorage.PurgePresentation(ctx, item.Key)
+		case trashKindMeetup:
+			err = h.MeetupStorage.PurgeMeetup(ctx, item.Key)
+		}
+		if err != nil {
+			log.Errorf(ctx, "Can't purge %v %v: %v", item.Kind, item.Key, err)
+			writeInternalError(w)
+			return
+		}
+		h.Events.Publish(ctx, TrashPurged{Kind: item.Kind, ID: item.Key})
+		purged++
+	}
+
+	fmt.Fprintf(w, "Purged %v items.", purged)
+}
+
+// References of an entity in the trash to entities which are gone, deleted or purged.
+type DanglingReferences struct {
+	Speakers      []string
+	Presentations []int64
+	Meetups       []int64
+}
+
+func (d *DanglingReferences) Empty() bool {
+	return len(d.Speakers) == 0 && len(d.Presentations) == 0 && len(d.Meetups) == 0
+}
+
+// Find what the entity in the trash references which can't be found anymore. Speakers don't reference anything,
+// and entities which aren't in the trash have nothing to check, the undelete reports them as not found.
+func (h *trashHandler) findDanglingReferences(ctx context.Context, kind string, ID int64) (DanglingReferences, error) {
+	dangling := DanglingReferences{Speakers: []string{}, Presentations: []int64{}, Meetups: []int64{}}
+
+	switch kind {
+	case trashKindPresentation:
+		IDs, presentations, err := h.PresentationStorage.GetDeletedPresentations(ctx)
+		if err != nil {
+			return dangling, err
+		}
+		for index, presentation := range presentations {
+			if IDs[index] != ID {
+				continue
+			}
+			for _, speaker := range presentation.Speakers {
+				_, err := h.SpeakerStorage.GetSpeakerIdByName(ctx, speaker)
+				if err == datastore.ErrNoSuchEntity {
+					dangling.Speakers = append(dangling.Speakers, speaker)
+					continue
+				}
+				if err != nil {
+					return dangling, err
+				}
+			}
+			if presentation.MeetupID != 0 {
+				_, err := h.MeetupStorage.GetMeetup(ctx, presentation.MeetupID)
+				if err == datastore.ErrNoSuchEntity {
+					dangling.Meetups = append(dangling.Meetups, presentation.MeetupID)
+				} else if err != nil {
+					return dangling, err
+				}
+			}
+		}
+	case trashKindMeetup:
+		IDs, meetups, err := h.MeetupStorage.GetDeletedMeetups(ctx)
+		if err != nil {
+			return dangling, err
+		}
+		for index, meetup := range meetups {
+			if IDs[index] != ID {
+				continue
+			}
+			for _, presentationID := range meetup.Presentations {
+				_, err := h.PresentationStorage.GetPresentation(ctx, presentationID)
+				if err == datastore.ErrNoSuchEntity {
+					dangling.Presentations = append(dangling.Presentations, presentationID)
+					continue
+				}
+				if err != nil {
+					return dangling, err
+				}
+			}
+		}
+	}
+	return dangling, nil
+}
+
+func (h *trashHandler) checkAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return false
+	}
+	if !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin.")
+		return false
+	}
+	return true
+}
+
+func (h *trashHandler) getTrash(ctx context.Context) ([]TrashItemPublicView, error) {
+	items := make([]TrashItemPublicView, 0, 10)
+
+	speakerIDs, speakers, err := h.SpeakerStorage.GetDeletedSpeakers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for index, speaker := range speakers {
+		items = append(items, TrashItemPublicView{Kind: trashKindSpeaker, Key: speakerIDs[index], Title: speaker.GetSpeakerFullName(), Owner: speaker.Owner, DeletedAt: speaker.DeletedAt})
+	}
+
+	presentationIDs, presentations, err := h.PresentationStorage.GetDeletedPresentations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for index, presentation := range presentations {
+		items = append(items, TrashItemPublicView{Kind: trashKindPresentation, Key: presentationIDs[index], Title: presentation.Title, Owner: presentation.Owner, DeletedAt: presentation.DeletedAt})
+	}
+
+	meetupIDs, meetups, err := h.MeetupStorage.GetDeletedMeetups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for index, meetup := range meetups {
+		items = append(items, TrashItemPublicView{Kind: trashKindMeetup, Key: meetupIDs[index], Title: meetup.Title, Owner: meetup.Owner, DeletedAt: meetup.DeletedAt})
+	}
+
+	return items, nil
+}
+
+func WriteTrashPublicView(items []TrashItemPublicView, w io.Writer, encoder Encoder) error {
+	return encoder.Encode(w, items)
+}
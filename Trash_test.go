@@ -0,0 +1,102 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+func TestTrash(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	err := RegisterTrashAPIRoutes(router, store, store, store, store, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RegisterTrashRoutes(router.PathPrefix("/trash").Subrouter(), store, store, store, store, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := &user.User{Email: "owner@example.com"}
+	admin := &user.User{Email: "admin@example.com", Admin: true}
+
+	ID, _ := store.AddPresentation(ctx, &Presentation{Owner: owner.Email, Title: "Go"})
+	keptID, _ := store.AddPresentation(ctx, &Presentation{Owner: owner.Email, Title: "Rust"})
+	url := fmt.Sprintf("/presentations/%v", ID)
+
+	response := serveAs(router, owner, "DELETE", url, nil)
+	if response.Code != http.StatusTeapot {
+		t.Fatalf("Owner should be able to delete the presentation, got %v: %s", response.Code, response.Body)
+	}
+	if response = serveAs(router, nil, "GET", url, nil); response.Code != http.StatusNotFound {
+		t.Errorf("Deleted presentation should be hidden, got %v", response.Code)
+	}
+	IDs, _, _ := store.GetAllPresentations(ctx)
+	if len(IDs) != 1 || IDs[0] != keptID {
+		t.Errorf("Deleted presentation shouldn't be listed, got %v", IDs)
+	}
+
+	response = serveAs(router, admin, "GET", "/trash", nil)
+	var items []TrashItemPublicView
+	json.NewDecoder(response.Body).Decode(&items)
+	if len(items) != 1 || items[0].Kind != trashKindPresentation || items[0].Key != ID || items[0].Owner != owner.Email {
+		t.Fatalf("Expected the deleted presentation in the trash, got %+v", items)
+	}
+
+	undelete := fmt.Sprintf("/trash/%v/%v/undelete", trashKindPresentation, ID)
+	for _, c := range []struct {
+		u      *user.User
+		status int
+	}{{nil, http.StatusUnauthorized}, {owner, http.StatusForbidden}} {
+		for _, request := range [][2]string{{"GET", "/trash"}, {"POST", undelete}, {"GET", "/trash/purge"}} {
+			if response = serveAs(router, c.u, request[0], request[1], nil); response.Code != c.status {
+				t.Errorf("%v %v as %v should be %v, got %v", request[0], request[1], c.u, c.status, response.Code)
+			}
+		}
+	}
+
+	if response = serveAs(router, admin, "POST", undelete, nil); response.Code != http.StatusOK {
+		t.Fatalf("Admin should be able to undelete, got %v: %s", response.Code, response.Body)
+	}
+	if response = serveAs(router, nil, "GET", url, nil); response.Code != http.StatusOK {
+		t.Errorf("Restored presentation should be found again, got %v", response.Code)
+	}
+	if response = serveAs(router, admin, "POST", undelete, nil); response.Code != http.StatusNotFound {
+		t.Errorf("Presentation isn't in the trash anymore, got %v", response.Code)
+	}
+
+	serveAs(router, owner, "DELETE", url, nil)
+	if response = serveAs(router, admin, "GET", "/trash/purge", nil); response.Code != http.StatusOK {
+		t.Fatalf("Admin should be able to purge, got %v: %s", response.Code, response.Body)
+	}
+	if _, deleted, _ := store.GetDeletedPresentations(ctx); len(deleted) != 1 {
+		t.Errorf("Presentations deleted within the retention period should be kept, got %+v", deleted)
+	}
+
+	store.PutData(ctx, trashRetentionDaysKey, "0")
+	serveAs(router, admin, "GET", "/trash/purge", nil)
+	if _, deleted, _ := store.GetDeletedPresentations(ctx); len(deleted) != 0 {
+		t.Errorf("Presentations deleted before the retention period should be purged, got %+v", deleted)
+	}
+	if response = serveAs(router, admin, "POST", undelete, nil); response.Code != http.StatusNotFound {
+		t.Errorf("Purged presentation can't be restored, got %v", response.Code)
+	}
+
+	speakerID, _ := store.AddSpeaker(ctx, &Speaker{Name: "Jan", Surname: "Kowalski"})
+	ID, _ = store.AddPresentation(ctx, &Presentation{Owner: owner.Email, Title: "Go", Speakers: []string{"Jan Kowalski"}})
+	store.DeletePresentation(ctx, ID)
+	store.DeleteSpeaker(ctx, speakerID)
+	undelete = fmt.Sprintf("/trash/%v/%v/undelete", trashKindPresentation, ID)
+	if response = serveAs(router, admin, "POST", undelete, nil); response.Code != http.StatusConflict {
+		t.Errorf("Presentation of a deleted speaker shouldn't be restored, got %v", response.Code)
+	}
+	store.UndeleteSpeaker(ctx, speakerID)
+	if response = serveAs(router, admin, "POST", undelete, nil); response.Code != http.StatusOK {
+		t.Errorf("Presentation should be restored once its speaker is, got %v: %s", response.Code, response.Body)
+	}
+}
@@ -0,0 +1,568 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+const datastoreVoteEventsKind = "VoteEvents"
+const datastoreBlockedVotersKind = "BlockedVoters"
+
+const (
+	voteEventUpvote   = "upvote"
+	voteEventDownvote = "downvote"
+	voteEventBallot   = "ballot"
+	voteEventWithdraw = "withdraw"
+)
+
+// Votes a voter, and all the voters behind one address together, may cast per window.
+const (
+	voterRateLimit   = 10
+	networkRateLimit = 30
+	voteRateWindow   = time.Minute
+)
+
+const (
+	defaultSuspiciousHours = 24
+	maxSuspiciousHours     = 24 * 30
+	// Distinct voters behind one address from which on their votes are reported.
+	sharedNetworkVoters = 3
+	// New voters voting for the same presentation or meetup within burstWindow from which on they are reported.
+	burstVoters = 5
+	burstWindow = 10 * time.Minute
+	// A voter is new if they didn't vote during that long before their first vote of the report.
+	newVoterAge = 7 * 24 * time.Hour
+	// The longest report looks back that far, older events are pruned.
+	voteEventRetention = maxSuspiciousHours*time.Hour + newVoterAge
+)
+
+// Counts the requests per key, in fixed windows.
+// MemcacheRateLimiter is used in production, MemoryRateLimiter when running standalone.
+type RateLimiter interface {
+	// Count the request and tell whether it's within the limit of the current window.
+	Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error)
+}
+
+// A vote as it happened, kept to find suspicious voting.
+type VoteEvent struct {
+	Voter string
+	// Salted hash of the address the vote came from, like the voter IDs.
+	Network        string
+	Kind           string
+	PresentationID int64
+	MeetupID       int64
+	Time           time.Time
+}
+
+type VoteEventStore interface {
+	AddVoteEvent(ctx context.Context, event *VoteEvent) error
+	// The events since the time, oldest first.
+	GetVoteEvents(ctx context.Context, since time.Time) ([]VoteEvent, error)
+	// Delete the events before the time, returning how many were deleted.
+	DeleteVoteEvents(ctx context.Context, before time.Time) (int, error)
+	// Keep the voter from voting. Blocking a voter again does nothing.
+	BlockVoter(ctx context.Context, voter string) error
+	// Returns datastore.ErrNoSuchEntity if the voter isn't blocked.
+	UnblockVoter(ctx context.Context, voter string) error
+	IsVoterBlocked(ctx context.Context, voter string) (bool, error)
+	// The IDs of the blocked voters, sorted.
+	GetBlockedVoters(ctx context.Context) ([]string, error)
+}
+
+// Rate limits the votes, turns away blocked voters and records the votes.
+type VoteGuard struct {
+	Limiter          RateLimiter
+	VoteEventStorage VoteEventStore
+	MetadataStorage  MetadataStore
+}
+
+func NewVoteGuard(Limiter RateLimiter, VoteEventStorage VoteEventStore, MetadataStorage MetadataStore) *VoteGuard {
+	return &VoteGuard{Limiter: Limiter, VoteEventStorage: VoteEventStorage, MetadataStorage: MetadataStorage}
+}
+
+// The address of the client. Requests reach App Engine directly, so there's no proxy header to trust.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Check whether the user may vote now, writing the error response if not.
+// Voters blocked by email are stored under the hash of the email, so both hashes are checked.
+// The limits fail open, a broken cache shouldn't stop the voting.
+func (g *VoteGuard) Allow(ctx context.Context, w http.ResponseWriter, r *http.Request, salt string, u *user.User) bool {
+	voter := voterID(salt, u)
+	for _, blockedVoter := range []string{voter, emailVoterID(salt, u.Email)} {
+		blocked, err := g.VoteEventStorage.IsVoterBlocked(ctx, blockedVoter)
+		if err != nil {
+			log.Errorf(ctx, "Couldn't check whether the voter is blocked: %v", err)
+			writeInternalError(w)
+			return false
+		}
+		if blocked {
+			writeError(w, http.StatusForbidden, "Sorry, you may not vote anymore.")
+			return false
+		}
+	}
+
+	limits := []struct {
+		key   string
+		limit int
+	}{
+		{"vote:voter:" + voter, voterRateLimit},
+		{"vote:network:" + saltedHash(salt, clientIP(r)), networkRateLimit},
+	}
+	for _, limit := range limits {
+		allowed, err := g.Limiter.Allow(ctx, limit.key, limit.limit, voteRateWindow)
+		if err != nil {
+			log.Warningf(ctx, "Couldn't check vote rate limit: %v", err)
+			continue
+		}
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(voteRateWindow/time.Second)))
+			writeError(w, http.StatusTooManyRequests, "Too many votes, please try again in a minute.")
+			return false
+		}
+	}
+	return true
+}
+
+// Record a vote. It's already stored, so failing to record it is only logged.
+func (g *VoteGuard) Record(ctx context.Context, r *http.Request, salt string, voter string, kind string, presentationID int64, meetupID int64) {
+	event := VoteEvent{
+		Voter:          voter,
+		Network:        saltedHash(salt, clientIP(r)),
+		Kind:           kind,
+		PresentationID: presentationID,
+		MeetupID:       meetupID,
+		Time:           time.Now(),
+	}
+	err := g.VoteEventStorage.AddVoteEvent(ctx, &event)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't record vote: %v", err)
+	}
+}
+
+type SuspiciousFinding = api.SuspiciousFinding
+
+type SuspiciousVotesReport = api.SuspiciousVotesReport
+
+type InvalidateVotesForm = api.InvalidateVotesForm
+
+func addSuspiciousTargets(finding *SuspiciousFinding, events []VoteEvent) {
+	for _, event := range events {
+		if event.PresentationID != 0 && !containsID(finding.Presentations, event.PresentationID) {
+			finding.Presentations = append(finding.Presentations, event.PresentationID)
+		}
+		if event.MeetupID != 0 && !containsID(finding.Meetups, event.MeetupID) {
+			finding.Meetups = append(finding.Meetups, event.MeetupID)
+		}
+	}
+	sortedIDs(finding.Presentations)
+	sortedIDs(finding.Meetups)
+}
+
+// Find the voters sharing an address and the bursts of new voters among the events since the time.
+// The events have to reach back newVoterAge further, to tell the new voters, and be ordered by time.
+func detectSuspiciousVotes(events []VoteEvent, since time.Time) []SuspiciousFinding {
+	firstVotes := make(map[string]time.Time)
+	lastVotes := make(map[string]time.Time)
+	newVoters := make(map[string]bool)
+	networks := make(map[string][]VoteEvent)
+	targets := make(map[string][]VoteEvent)
+	for _, event := range events {
+		last, seen := lastVotes[event.Voter]
+		lastVotes[event.Voter] = event.Time
+		if event.Time.Before(since) {
+			continue
+		}
+		if _, ok := firstVotes[event.Voter]; !ok {
+			firstVotes[event.Voter] = event.Time
+			newVoters[event.Voter] = !seen || event.Time.Sub(last) >= newVoterAge
+		}
+		if event.Network != "" {
+			networks[event.Network] = append(networks[event.Network], event)
+		}
+		if event.Kind == voteEventUpvote {
+			target := fmt.Sprintf("presentation %v", event.PresentationID)
+			targets[target] = append(targets[target], event)
+		}
+		if event.Kind == voteEventBallot {
+			target := fmt.Sprintf("meetup %v", event.MeetupID)
+			targets[target] = append(targets[target], event)
+		}
+	}
+
+	findings := make([]SuspiciousFinding, 0)
+
+	for _, networkEvents := range networks {
+		voters := make([]string, 0)
+		for _, event := range networkEvents {
+			if !contains(voters, event.Voter) {
+				voters = append(voters, event.Voter)
+			}
+		}
+		if len(voters) < sharedNetworkVoters {
+			continue
+		}
+		sort.Strings(voters)
+		finding := SuspiciousFinding{
+			Pattern: "shared-network",
+			Detail:  fmt.Sprintf("%v voters cast %v votes from the same address.", len(voters), len(networkEvents)),
+			Voters:  voters,
+		}
+		addSuspiciousTargets(&finding, networkEvents)
+		findings = append(findings, finding)
+	}
+
+	for target, targetEvents := range targets {
+		for start := 0; start < len(targetEvents); start++ {
+			end := start
+			voters := make([]string, 0)
+			for ; end < len(targetEvents) && targetEvents[end].Time.Sub(targetEvents[start].Time) <= burstWindow; end++ {
+				voter := targetEvents[end].Voter
+				if newVoters[voter] && !contains(voters, voter) {
+					voters = append(voters, voter)
+				}
+			}
+			if len(voters) < burstVoters {
+				continue
+			}
+			sort.Strings(voters)
+			finding := SuspiciousFinding{
+				Pattern: "burst",
+				Detail:  fmt.Sprintf("%v new voters voted for %v within %v.", len(voters), target, burstWindow),
+				Voters:  voters,
+			}
+			addSuspiciousTargets(&finding, targetEvents[start:end])
+			findings = append(findings, finding)
+			start = end - 1
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if len(findings[i].Voters) != len(findings[j].Voters) {
+			return len(findings[i].Voters) > len(findings[j].Voters)
+		}
+		if findings[i].Pattern != findings[j].Pattern {
+			return findings[i].Pattern < findings[j].Pattern
+		}
+		return findings[i].Detail < findings[j].Detail
+	})
+	return findings
+}
+
+// Register the pruning of the vote events to the router.
+func RegisterVoteFraudRoutes(m *mux.Router, VoteEventStorage VoteEventStore) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering vote fraud routes")
+	}
+	h := voteFraudHandler{VoteEventStorage: VoteEventStorage}
+	m.HandleFunc("/prune", h.Prune).Methods("GET")
+
+	return nil
+}
+
+// Register the suspicious votes report, the invalidation of votes and the blocked voters to the router.
+func RegisterVoteFraudAPIRoutes(m *mux.Router, VoteEventStorage VoteEventStore, PresentationStorage PresentationStore, MeetupStorage MeetupStore, BallotStorage BallotStore, MetadataStorage MetadataStore, TransactionStorage TransactionStore, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering vote fraud API routes")
+	}
+	h := voteFraudHandler{VoteEventStorage: VoteEventStorage, PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage, BallotStorage: BallotStorage, MetadataStorage: MetadataStorage, TransactionStorage: TransactionStorage, Events: Events}
+	m.HandleFunc("/votes/suspicious", h.Suspicious).Methods("GET")
+	m.HandleFunc("/votes/invalidate", h.Invalidate).Methods("POST")
+	m.HandleFunc("/votes/blocked", h.ListBlocked).Methods("GET")
+	m.HandleFunc("/votes/blocked/{Voter}", h.Unblock).Methods("DELETE")
+
+	return nil
+}
+
+type voteFraudHandler struct {
+	VoteEventStorage    VoteEventStore
+	PresentationStorage PresentationStore
+	MeetupStorage       MeetupStore
+	BallotStorage       BallotStore
+	MetadataStorage     MetadataStore
+	TransactionStorage  TransactionStore
+	Events              *EventBus
+}
+
+func (h *voteFraudHandler) checkAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return false
+	}
+	if !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin.")
+		return false
+	}
+	return true
+}
+
+// Report the suspicious votes of the last hours.
+func (h *voteFraudHandler) Suspicious(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	hours := defaultSuspiciousHours
+	if value := r.URL.Query().Get("hours"); value != "" {
+		var err error
+		hours, err = strconv.Atoi(value)
+		if err != nil || hours < 1 || hours > maxSuspiciousHours {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Hours have to be between 1 and %v: %v", maxSuspiciousHours, value))
+			return
+		}
+	}
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	events, err := h.VoteEventStorage.GetVoteEvents(ctx, since.Add(-newVoterAge))
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get vote events: %v", err)
+		writeInternalError(w)
+		return
+	}
+	report := SuspiciousVotesReport{Since: since, Findings: detectSuspiciousVotes(events, since)}
+	for _, event := range events {
+		if !event.Time.Before(since) {
+			report.Votes++
+		}
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, report)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write suspicious votes: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+// Remove the upvotes and ballots of the voters, and block them if asked to.
+// Upvotes of presentations changed meanwhile are skipped, running it again removes them.
+// It goes through all the presentations and ballots, so it gets the time of an archive export.
+func (h *voteFraudHandler) Invalidate(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, archiveRequestTimeout)
+	defer done()
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	form := InvalidateVotesForm{}
+	err := json.NewDecoder(r.Body).Decode(&form)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
+	}
+	if len(form.Voters) == 0 {
+		writeFieldErrors(w, []FieldError{{Field: "Voters", Message: "At least one voter is required."}})
+		return
+	}
+
+	salt, err := getVoterSalt(ctx, h.MetadataStorage, h.TransactionStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get voter salt: %v", err)
+		writeInternalError(w)
+		return
+	}
+	voters := make([]string, 0, len(form.Voters))
+	for _, voter := range form.Voters {
+		voter = strings.TrimSpace(voter)
+		if isRawVoter(voter) {
+			voter = emailVoterID(salt, voter)
+		}
+		if voter != "" && !contains(voters, voter) {
+			voters = append(voters, voter)
+		}
+	}
+
+	upvotes := 0
+	skipped := 0
+	events := make([]Event, 0)
+	changed := make([]int64, 0)
+	IDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get presentations: %v", err)
+		writeInternalError(w)
+		return
+	}
+	for index, presentation := range presentations {
+		kept := make([]string, 0, len(presentation.Voters))
+		for _, voter := range presentation.Voters {
+			if isRawVoter(voter) {
+				voter = emailVoterID(salt, voter)
+			}
+			if !contains(voters, voter) {
+				kept = append(kept, voter)
+			}
+		}
+		if len(kept) == len(presentation.Voters) {
+			continue
+		}
+		removed := len(presentation.Voters) - len(kept)
+		presentation.Voters = kept
+		err = h.PresentationStorage.PutPresentation(ctx, IDs[index], &presentation)
+		if err == ErrVersionConflict {
+			skipped++
+			continue
+		}
+		if err != nil {
+			log.Errorf(ctx, "Couldn't put presentation %v: %v", IDs[index], err)
+			writeInternalError(w)
+			return
+		}
+		upvotes += removed
+		events = append(events, PresentationVoted{ID: IDs[index], Presentation: presentation, Withdrawn: true})
+		changed = append(changed, IDs[index])
+	}
+
+	ballots := 0
+	meetupIDs, _, err := h.MeetupStorage.GetAllMeetups(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get meetups: %v", err)
+		writeInternalError(w)
+		return
+	}
+	for _, meetupID := range meetupIDs {
+		meetupBallots, err := h.BallotStorage.GetBallots(ctx, meetupID)
+		if err != nil {
+			log.Errorf(ctx, "Can't get ballots: %v", err)
+			writeInternalError(w)
+			return
+		}
+		for _, ballot := range meetupBallots {
+			voter := ballot.Voter
+			if isRawVoter(voter) {
+				voter = emailVoterID(salt, voter)
+			}
+			if !contains(voters, voter) {
+				continue
+			}
+			err = h.BallotStorage.DeleteBallot(ctx, meetupID, ballot.Voter)
+			if err != nil {
+				log.Errorf(ctx, "Couldn't delete ballot of meetup %v: %v", meetupID, err)
+				writeInternalError(w)
+				return
+			}
+			ballots++
+		}
+	}
+
+	if form.Block {
+		for _, voter := range voters {
+			err = h.VoteEventStorage.BlockVoter(ctx, voter)
+			if err != nil {
+				log.Errorf(ctx, "Couldn't block voter %v: %v", voter, err)
+				writeInternalError(w)
+				return
+			}
+		}
+	}
+
+	events = append(events, VotesInvalidated{Voters: voters, Presentations: changed, Ballots: ballots, Blocked: form.Block})
+	h.Events.Publish(ctx, events...)
+
+	fmt.Fprintf(w, "Removed %v upvotes and %v ballots, %v presentations changed meanwhile and need another run.", upvotes, ballots, skipped)
+}
+
+// List the IDs of the blocked voters.
+func (h *voteFraudHandler) ListBlocked(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	voters, err := h.VoteEventStorage.GetBlockedVoters(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get blocked voters: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, voters)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write blocked voters: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+// Let a blocked voter vote again. The votes removed when blocking them stay removed.
+func (h *voteFraudHandler) Unblock(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	if !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	voter := mux.Vars(r)["Voter"]
+	err := h.VoteEventStorage.UnblockVoter(ctx, voter)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Voter %v isn't blocked.", voter))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't unblock voter %v: %v", voter, err)
+		writeInternalError(w)
+		return
+	}
+
+	fmt.Fprintf(w, "Unblocked voter %v.", voter)
+}
+
+// Delete the vote events older than any report looks at. Meant to be run by cron.
+func (h *voteFraudHandler) Prune(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+
+	if r.Header.Get("X-Appengine-Cron") != "true" && !h.checkAdmin(ctx, w, r) {
+		return
+	}
+
+	deleted, err := h.VoteEventStorage.DeleteVoteEvents(ctx, time.Now().Add(-voteEventRetention))
+	if err != nil {
+		log.Errorf(ctx, "Couldn't delete vote events: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	fmt.Fprintf(w, "Deleted %v vote events.", deleted)
+}
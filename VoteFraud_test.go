@@ -0,0 +1,141 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/user"
+)
+
+func TestDetectSuspiciousVotes(t *testing.T) {
+	since := time.Date(2017, 5, 10, 12, 0, 0, 0, time.UTC)
+	events := []VoteEvent{
+		// Voted the day before, so not new.
+		{Voter: "regular", Network: "home", Kind: voteEventUpvote, PresentationID: 1, Time: since.Add(-24 * time.Hour)},
+		{Voter: "regular", Network: "home", Kind: voteEventUpvote, PresentationID: 1, Time: since.Add(time.Minute)},
+	}
+	for index := 0; index < 5; index++ {
+		events = append(events, VoteEvent{
+			Voter:          fmt.Sprintf("new%v", index),
+			Network:        fmt.Sprintf("mobile%v", index),
+			Kind:           voteEventUpvote,
+			PresentationID: 1,
+			Time:           since.Add(time.Duration(2+index) * time.Minute),
+		})
+	}
+	for index := 0; index < 3; index++ {
+		events = append(events, VoteEvent{
+			Voter:    fmt.Sprintf("office%v", index),
+			Network:  "office",
+			Kind:     voteEventBallot,
+			MeetupID: 7,
+			Time:     since.Add(time.Hour),
+		})
+	}
+	// Before the report, doesn't count.
+	events = append([]VoteEvent{{Voter: "old", Network: "office", Kind: voteEventUpvote, PresentationID: 2, Time: since.Add(-time.Hour)}}, events...)
+
+	findings := detectSuspiciousVotes(events, since)
+
+	if len(findings) != 2 {
+		t.Fatalf("Expected a burst and a shared network, got %+v", findings)
+	}
+	burst := findings[0]
+	if burst.Pattern != "burst" || len(burst.Voters) != 5 || len(burst.Presentations) != 1 || burst.Presentations[0] != 1 {
+		t.Errorf("Expected the 5 new voters of presentation 1 as a burst, got %+v", burst)
+	}
+	if contains(burst.Voters, "regular") {
+		t.Errorf("The regular voter isn't new, got %+v", burst)
+	}
+	shared := findings[1]
+	if shared.Pattern != "shared-network" || len(shared.Voters) != 3 || len(shared.Meetups) != 1 || shared.Meetups[0] != 7 {
+		t.Errorf("Expected the 3 office voters of meetup 7 as a shared network, got %+v", shared)
+	}
+	if contains(shared.Voters, "old") {
+		t.Errorf("Votes before the report shouldn't be reported, got %+v", shared)
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewMemoryRateLimiter()
+
+	for index := 0; index < 3; index++ {
+		allowed, err := limiter.Allow(ctx, "voter", 3, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !allowed {
+			t.Fatalf("Request %v should be within the limit", index+1)
+		}
+	}
+	allowed, _ := limiter.Allow(ctx, "voter", 3, time.Hour)
+	if allowed {
+		t.Error("The 4th request should be over the limit")
+	}
+	allowed, _ = limiter.Allow(ctx, "other", 3, time.Hour)
+	if !allowed {
+		t.Error("Other keys should have their own limit")
+	}
+}
+
+func TestBlockedVotersAndPruning(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+
+	for _, voter := range []string{"b", "a", "b"} {
+		if err := store.BlockVoter(ctx, voter); err != nil {
+			t.Fatal(err)
+		}
+	}
+	voters, _ := store.GetBlockedVoters(ctx)
+	if len(voters) != 2 || voters[0] != "a" || voters[1] != "b" {
+		t.Errorf("Expected voters a and b blocked once each, got %v", voters)
+	}
+	if err := store.UnblockVoter(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, _ := store.IsVoterBlocked(ctx, "a"); blocked {
+		t.Error("Voter a should be unblocked")
+	}
+	if err := store.UnblockVoter(ctx, "a"); err != datastore.ErrNoSuchEntity {
+		t.Errorf("Unblocking a voter who isn't blocked should fail, got %v", err)
+	}
+
+	store.AddVoteEvent(ctx, &VoteEvent{Voter: "old", Time: now.Add(-voteEventRetention - time.Hour)})
+	store.AddVoteEvent(ctx, &VoteEvent{Voter: "new", Time: now.Add(-time.Hour)})
+	deleted, err := store.DeleteVoteEvents(ctx, now.Add(-voteEventRetention))
+	if err != nil || deleted != 1 {
+		t.Errorf("Expected the old event deleted, got %v, %v", deleted, err)
+	}
+	events, _ := store.GetVoteEvents(ctx, time.Time{})
+	if len(events) != 1 || events[0].Voter != "new" {
+		t.Errorf("Expected the new event kept, got %+v", events)
+	}
+}
+
+func TestVoterBlockedByEmail(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	voter := &user.User{Email: "Voter@example.com", ID: "42"}
+
+	ID, _ := store.AddPresentation(ctx, &Presentation{Title: "Go", Status: StatusSubmitted})
+	salt, err := getVoterSalt(ctx, store, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.BlockVoter(ctx, emailVoterID(salt, "voter@example.com"))
+
+	if response := serveAs(router, voter, "PUT", fmt.Sprintf("/presentations/%v/vote", ID), nil); response.Code != http.StatusForbidden {
+		t.Errorf("A voter blocked by email shouldn't vote, got %v", response.Code)
+	}
+	if response := serveAs(router, &user.User{Email: "other@example.com", ID: "43"}, "PUT", fmt.Sprintf("/presentations/%v/vote", ID), nil); response.Code != http.StatusOK {
+		t.Errorf("Other voters should still vote, got %v", response.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package MeetupRest
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+// Metadata key holding the secret salt of the voter IDs. Created on the first vote, changing it orphans all the votes.
+const voterSaltKey = "VoterSalt"
+
+// Hash the value with the salt, so it can be compared without being kept.
+func saltedHash(salt string, value string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Votes are stored under a salted hash of the ID of the user, so they can be counted and checked
+// without keeping who voted. Unlike the email, the ID doesn't change.
+func voterID(salt string, u *user.User) string {
+	return saltedHash(salt, u.ID)
+}
+
+// Votes stored before the voter IDs hold the email itself. Only the email is known for those, so
+// they're anonymized to a salted hash of it, and moved to the voter ID when their voter votes again.
+func emailVoterID(salt string, email string) string {
+	return saltedHash(salt, strings.ToLower(email))
+}
+
+// Votes stored before the voter IDs hold the email itself.
+func isRawVoter(voter string) bool {
+	return strings.Contains(voter, "@")
+}
+
+// Check whether the vote was cast by the user, under any of the IDs it may be stored under.
+func isVoter(salt string, u *user.User, voter string) bool {
+	if isRawVoter(voter) {
+		return strings.EqualFold(voter, u.Email)
+	}
+	return voter == voterID(salt, u) || voter == emailVoterID(salt, u.Email)
+}
+
+// Anonymize the voters and move the votes of the user to their voter ID.
+func claimVotes(salt string, u *user.User, voters []string) []string {
+	claimed := make([]string, 0, len(voters))
+	for _, voter := range voters {
+		if isVoter(salt, u, voter) {
+			voter = voterID(salt, u)
+		} else if isRawVoter(voter) {
+			voter = emailVoterID(salt, voter)
+		}
+		if !contains(claimed, voter) {
+			claimed = append(claimed, voter)
+		}
+	}
+	return claimed
+}
+
+// The keys the ballots of the user cast before the voter IDs may be stored under.
+func legacyBallotVoters(salt string, u *user.User) []string {
+	return []string{emailVoterID(salt, u.Email), u.Email}
+}
+
+// Get the salt of the voter IDs, creating it if there's none yet.
+func getVoterSalt(ctx context.Context, MetadataStorage MetadataStore, TransactionStorage TransactionStore) (string, error) {
+	salt, err := MetadataStorage.GetData(ctx, voterSaltKey)
+	if err != datastore.ErrNoSuchEntity {
+		return salt, err
+	}
+
+	err = TransactionStorage.RunInTransaction(ctx, func(ctx context.Context) error {
+		salt, err = MetadataStorage.GetData(ctx, voterSaltKey)
+		if err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		random := make([]byte, 32)
+		_, err = rand.Read(random)
+		if err != nil {
+			return err
+		}
+		salt = hex.EncodeToString(random)
+		return MetadataStorage.PutData(ctx, voterSaltKey, salt)
+	})
+	return salt, err
+}
+
+// Replace the emails among the voters by their hashes. Voters who voted both before and after are kept once.
+func anonymizeVoters(salt string, voters []string) ([]string, int) {
+	anonymized := make([]string, 0, len(voters))
+	migrated := 0
+	for _, voter := range voters {
+		if isRawVoter(voter) {
+			voter = emailVoterID(salt, voter)
+			migrated++
+		}
+		if !contains(anonymized, voter) {
+			anonymized = append(anonymized, voter)
+		}
+	}
+	return anonymized, migrated
+}
+
+// Get the ID of the user as a voter, writing the error response if that fails.
+func getVoterID(ctx context.Context, w http.ResponseWriter, u *user.User, MetadataStorage MetadataStore, TransactionStorage TransactionStore) (string, string, bool) {
+	salt, err := getVoterSalt(ctx, MetadataStorage, TransactionStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get voter salt: %v", err)
+		writeInternalError(w)
+		return "", "", false
+	}
+	return salt, voterID(salt, u), true
+}
+
+// Register the route migrating the stored votes to voter IDs to the router.
+func RegisterVoterAPIRoutes(m *mux.Router, PresentationStorage PresentationStore, MeetupStorage MeetupStore, BallotStorage BallotStore, MetadataStorage MetadataStore, TransactionStorage TransactionStore, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering voter API routes")
+	}
+	h := voterHandler{PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage, BallotStorage: BallotStorage, MetadataStorage: MetadataStorage, TransactionStorage: TransactionStorage, Events: Events}
+	m.HandleFunc("/votes/anonymize", h.Anonymize).Methods("POST")
+
+	return nil
+}
+
+type voterHandler struct {
+	PresentationStorage PresentationStore
+	MeetupStorage       MeetupStore
+	BallotStorage       BallotStore
+	MetadataStorage     MetadataStore
+	TransactionStorage  TransactionStore
+	Events              *EventBus
+}
+
+// Replace the emails in the upvotes and ballots stored before the voter IDs. Safe to run again,
+// e.g. when a presentation changed during the migration or after importing an old archive.
+func (h *voterHandler) Anonymize(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return
+	}
+	if !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin.")
+		return
+	}
+
+	salt, err := getVoterSalt(ctx, h.MetadataStorage, h.TransactionStorage)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get voter salt: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	voters := 0
+	skipped := 0
+	for _, get := range []func(context.Context) ([]int64, []Presentation, error){h.PresentationStorage.GetAllPresentations, h.PresentationStorage.GetDeletedPresentations} {
+		IDs, presentations, err := get(ctx)
+		if err != nil {
+			log.Errorf(ctx, "Can't get presentations: %v", err)
+			writeInternalError(w)
+			return
+		}
+		for index, presentation := range presentations {
+			anonymized, migrated := anonymizeVoters(salt, presentation.Voters)
+			if migrated == 0 {
+				continue
+			}
+			presentation.Voters = anonymized
+			err = h.PresentationStorage.PutPresentation(ctx, IDs[index], &presentation)
+			if err == ErrVersionConflict {
+				skipped++
+				continue
+			}
+			if err != nil {
+				log.Errorf(ctx, "Couldn't put presentation %v: %v", IDs[index], err)
+				writeInternalError(w)
+				return
+			}
+			voters += migrated
+		}
+	}
+
+	ballots := 0
+	meetupIDs, _, err := h.MeetupStorage.GetAllMeetups(ctx)
+	if err == nil {
+		var deletedIDs []int64
+		deletedIDs, _, err = h.MeetupStorage.GetDeletedMeetups(ctx)
+		meetupIDs = append(meetupIDs, deletedIDs...)
+	}
+	if err != nil {
+		log.Errorf(ctx, "Can't get meetups: %v", err)
+		writeInternalError(w)
+		return
+	}
+	for _, meetupID := range meetupIDs {
+		meetupBallots, err := h.BallotStorage.GetBallots(ctx, meetupID)
+		if err != nil {
+			log.Errorf(ctx, "Can't get ballots: %v", err)
+			writeInternalError(w)
+			return
+		}
+		for _, ballot := range meetupBallots {
+			if !isRawVoter(ballot.Voter) {
+				continue
+			}
+			email := ballot.Voter
+			ballot.Voter = emailVoterID(salt, email)
+			// A ballot cast since replaces the old one.
+			_, err = h.BallotStorage.GetBallot(ctx, meetupID, ballot.Voter)
+			if err == datastore.ErrNoSuchEntity {
+				err = h.BallotStorage.PutBallot(ctx, meetupID, &ballot)
+			}
+			if err == nil {
+				err = h.BallotStorage.DeleteBallot(ctx, meetupID, email)
+			}
+			if err != nil {
+				log.Errorf(ctx, "Couldn't migrate ballot of meetup %v: %v", meetupID, err)
+				writeInternalError(w)
+				return
+			}
+			ballots++
+		}
+	}
+
+	h.Events.Publish(ctx, VotesAnonymized{Upvotes: voters, Ballots: ballots})
+
+	fmt.Fprintf(w, "Anonymized %v upvotes and %v ballots, %v presentations changed meanwhile and need another run.", voters, ballots, skipped)
+}
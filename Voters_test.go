@@ -0,0 +1,72 @@
+package MeetupRest
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+func TestAnonymizeVoters(t *testing.T) {
+	salt := "salt"
+	migrated := emailVoterID(salt, "b@example.com")
+
+	voters, count := anonymizeVoters(salt, []string{"a@example.com", migrated, "B@example.com"})
+
+	if count != 2 {
+		t.Errorf("2 emails should be migrated, got %v", count)
+	}
+	if len(voters) != 2 || voters[0] != emailVoterID(salt, "a@example.com") || voters[1] != migrated {
+		t.Errorf("Voters should be anonymized once each, got %v", voters)
+	}
+	for _, voter := range voters {
+		if isRawVoter(voter) {
+			t.Errorf("Voter %v wasn't anonymized", voter)
+		}
+	}
+	if emailVoterID("other", "a@example.com") == voters[0] {
+		t.Errorf("Voter IDs should depend on the salt")
+	}
+}
+
+func TestClaimVotes(t *testing.T) {
+	salt := "salt"
+	u := &user.User{ID: "1", Email: "A@example.com"}
+	renamed := &user.User{ID: "1", Email: "c@example.com"}
+	other := &user.User{ID: "2", Email: "b@example.com"}
+
+	if voterID(salt, u) != voterID(salt, renamed) {
+		t.Errorf("The voter ID shouldn't change with the email")
+	}
+	if voterID(salt, u) == voterID(salt, other) {
+		t.Errorf("Users should have different voter IDs")
+	}
+	for _, voter := range []string{voterID(salt, u), emailVoterID(salt, "a@example.com"), "a@EXAMPLE.com"} {
+		if !isVoter(salt, u, voter) {
+			t.Errorf("%v should be recognized as the vote of the user", voter)
+		}
+		if isVoter(salt, other, voter) {
+			t.Errorf("%v shouldn't be recognized as the vote of another user", voter)
+		}
+	}
+
+	voters := claimVotes(salt, u, []string{"a@example.com", emailVoterID(salt, "A@example.com"), "b@example.com"})
+
+	if len(voters) != 2 || voters[0] != voterID(salt, u) || voters[1] != emailVoterID(salt, "b@example.com") {
+		t.Errorf("The votes of the user should be moved to the voter ID once, got %v", voters)
+	}
+}
+
+func TestGetVoterSalt(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	salt, err := getVoterSalt(ctx, store, store)
+	if err != nil || len(salt) != 64 {
+		t.Fatalf("Expected a new salt, got %q, %v", salt, err)
+	}
+	again, err := getVoterSalt(ctx, store, store)
+	if err != nil || again != salt {
+		t.Errorf("The salt should be kept, got %q, %v", again, err)
+	}
+}
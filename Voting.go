@@ -0,0 +1,578 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+const datastoreBallotsKind = "Ballots"
+
+type VotingMode = api.VotingMode
+
+const (
+	VotingApproval = api.VotingApproval
+	VotingLimited  = api.VotingLimited
+	VotingBudget   = api.VotingBudget
+	VotingRanked   = api.VotingRanked
+)
+
+var votingModes = []VotingMode{VotingApproval, VotingLimited, VotingBudget, VotingRanked}
+
+// The votes of a voter for the presentations of a meetup, in every mode but approval.
+type Ballot struct {
+	Voter string
+	// The mode the ballot was cast in. Ballots of another mode than the one of the meetup aren't counted.
+	Mode VotingMode
+	// In order of preference for ranked choice.
+	Choices []BallotChoice
+	Cast    time.Time
+}
+
+type BallotChoice = api.BallotChoice
+
+type BallotStore interface {
+	GetBallot(ctx context.Context, meetupID int64, voter string) (Ballot, error)
+	GetBallots(ctx context.Context, meetupID int64) ([]Ballot, error)
+	PutBallot(ctx context.Context, meetupID int64, ballot *Ballot) error
+	DeleteBallot(ctx context.Context, meetupID int64, voter string) error
+}
+
+type LimitedBallotForm = api.LimitedBallotForm
+
+type BudgetBallotForm = api.BudgetBallotForm
+
+type RankedBallotForm = api.RankedBallotForm
+
+type BallotPublicView = api.BallotPublicView
+
+type VotingResults = api.VotingResults
+
+type VotingResult = api.VotingResult
+
+type VotingRound = api.VotingRound
+
+type RoundCount = api.RoundCount
+
+func isVotingMode(mode VotingMode) bool {
+	for _, known := range votingModes {
+		if mode == known {
+			return true
+		}
+	}
+	return false
+}
+
+// The voting mode of the meetup. Meetups stored before the modes existed use approval.
+func (m *Meetup) GetVotingMode() VotingMode {
+	if m.VotingMode == "" {
+		return VotingApproval
+	}
+	return m.VotingMode
+}
+
+func (m *Meetup) VotingClosed(now time.Time) bool {
+	return !m.VoteTimeEnd.IsZero() && m.VoteTimeEnd.Before(now)
+}
+
+// Check the voting mode of a meetup form.
+func validateVotingMode(mode VotingMode, votesPerVoter int) []FieldError {
+	fieldErrors := make([]FieldError, 0)
+	if mode != "" && !isVotingMode(mode) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "VotingMode", Message: fmt.Sprintf("Must be one of %v.", votingModes)})
+	}
+	switch {
+	case votesPerVoter < 0:
+		fieldErrors = append(fieldErrors, FieldError{Field: "VotesPerVoter", Message: "Must be at least 0."})
+	case votesPerVoter == 0 && (mode == VotingLimited || mode == VotingBudget):
+		fieldErrors = append(fieldErrors, FieldError{Field: "VotesPerVoter", Message: fmt.Sprintf("Has to be set for %v voting.", mode)})
+	}
+	return fieldErrors
+}
+
+// The presentations which can be voted on in the meetup: the ones attached to it and the ones it can still select.
+func ballotCandidates(meetupID int64, meetup *Meetup, IDs []int64, presentations []Presentation, meetups []Meetup) []int64 {
+	candidates := append([]int64(nil), meetup.Presentations...)
+	for _, candidate := range selectionCandidates(meetupID, meetup, IDs, presentations, meetups) {
+		candidates = append(candidates, candidate.ID)
+	}
+	return candidates
+}
+
+// Check a ballot for the meetup. Every choice has to be a candidate and appear once.
+func validateBallot(meetup *Meetup, mode VotingMode, choices []BallotChoice, candidates []int64) []FieldError {
+	fieldErrors := make([]FieldError, 0)
+	if len(choices) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Choices", Message: "Vote for at least one presentation, or delete the ballot."})
+		return fieldErrors
+	}
+
+	chosen := make([]int64, 0, len(choices))
+	points := 0
+	for _, choice := range choices {
+		if !containsID(candidates, choice.PresentationID) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Choices", Message: fmt.Sprintf("Presentation %v can't be voted on in this meetup.", choice.PresentationID)})
+		}
+		if containsID(chosen, choice.PresentationID) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Choices", Message: fmt.Sprintf("Presentation %v is on the ballot twice.", choice.PresentationID)})
+		}
+		if choice.Points < 1 {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Choices", Message: fmt.Sprintf("Points for presentation %v have to be at least 1.", choice.PresentationID)})
+		}
+		chosen = append(chosen, choice.PresentationID)
+		points += choice.Points
+	}
+
+	switch {
+	case mode == VotingLimited && len(choices) > meetup.VotesPerVoter:
+		fieldErrors = append(fieldErrors, FieldError{Field: "Choices", Message: fmt.Sprintf("Vote for at most %v presentations.", meetup.VotesPerVoter)})
+	case mode == VotingBudget && points > meetup.VotesPerVoter:
+		fieldErrors = append(fieldErrors, FieldError{Field: "Choices", Message: fmt.Sprintf("Spend at most %v points, not %v.", meetup.VotesPerVoter, points)})
+	case mode == VotingRanked && meetup.VotesPerVoter > 0 && len(choices) > meetup.VotesPerVoter:
+		fieldErrors = append(fieldErrors, FieldError{Field: "Choices", Message: fmt.Sprintf("Rank at most %v presentations.", meetup.VotesPerVoter)})
+	}
+	return fieldErrors
+}
+
+// Count the votes for the candidates of the meetup, in the mode of the meetup.
+// Approval voting counts the upvotes of the presentations, the other modes count the ballots cast in their mode.
+func tallyVotes(meetup *Meetup, candidates []int64, presentations map[int64]Presentation, ballots []Ballot) VotingResults {
+	mode := meetup.GetVotingMode()
+	results := VotingResults{Mode: mode, Results: make([]VotingResult, 0, len(candidates))}
+	counted := make([]Ballot, 0, len(ballots))
+	for _, ballot := range ballots {
+		if ballot.Mode == mode {
+			counted = append(counted, ballot)
+		}
+	}
+
+	scores := make(map[int64]int, len(candidates))
+	switch mode {
+	case VotingApproval:
+		results.Method = "Approval voting: every upvote counts once, most upvotes first."
+		voters := make([]string, 0)
+		for _, ID := range candidates {
+			scores[ID] = len(presentations[ID].Voters)
+			for _, voter := range presentations[ID].Voters {
+				if !contains(voters, voter) {
+					voters = append(voters, voter)
+				}
+			}
+		}
+		results.Ballots = len(voters)
+	case VotingLimited, VotingBudget:
+		results.Method = fmt.Sprintf("Limited voting: every voter upvotes at most %v presentations, most votes first.", meetup.VotesPerVoter)
+		if mode == VotingBudget {
+			results.Method = fmt.Sprintf("Budget voting: every voter spreads %v points over the presentations, most points first.", meetup.VotesPerVoter)
+		}
+		for _, ballot := range counted {
+			for _, choice := range ballot.Choices {
+				if containsID(candidates, choice.PresentationID) {
+					scores[choice.PresentationID] += choice.Points
+				}
+			}
+		}
+		results.Ballots = len(counted)
+	case VotingRanked:
+		results.Method = "Ranked choice with instant runoff: every round the presentation with the fewest first preferences is eliminated " +
+			"and its ballots go to their next preference, ties eliminating the higher key. Presentations are ranked by the round they were eliminated in."
+		rankings := make([][]int64, 0, len(counted))
+		for _, ballot := range counted {
+			ranking := make([]int64, 0, len(ballot.Choices))
+			for _, choice := range ballot.Choices {
+				ranking = append(ranking, choice.PresentationID)
+			}
+			rankings = append(rankings, ranking)
+		}
+		results.Rounds = instantRunoff(candidates, rankings)
+		for index, round := range results.Rounds {
+			if round.Eliminated != 0 {
+				scores[round.Eliminated] = index + 1
+			} else if len(round.Counts) > 0 {
+				scores[round.Counts[0].PresentationID] = index + 1
+			}
+		}
+		results.Ballots = len(counted)
+	}
+
+	for _, ID := range candidates {
+		results.Results = append(results.Results, VotingResult{PresentationID: ID, Title: presentations[ID].Title, Score: scores[ID]})
+	}
+	sort.SliceStable(results.Results, func(i, j int) bool {
+		a, b := results.Results[i], results.Results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.PresentationID < b.PresentationID
+	})
+	for index := range results.Results {
+		results.Results[index].Rank = index + 1
+		if index > 0 && results.Results[index].Score == results.Results[index-1].Score {
+			results.Results[index].Rank = results.Results[index-1].Rank
+		}
+	}
+	return results
+}
+
+// Eliminate the candidate with the fewest first preferences until one is left, one round per candidate.
+// Running past a majority ranks all the candidates, so the selection can pick more than the winner.
+func instantRunoff(candidates []int64, rankings [][]int64) []VotingRound {
+	remaining := sortedIDs(append([]int64(nil), candidates...))
+	rounds := make([]VotingRound, 0, len(remaining))
+	for len(remaining) > 0 {
+		votes := make(map[int64]int, len(remaining))
+		round := VotingRound{Counts: make([]RoundCount, 0, len(remaining))}
+		for _, ranking := range rankings {
+			exhausted := true
+			for _, ID := range ranking {
+				if containsID(remaining, ID) {
+					votes[ID]++
+					exhausted = false
+					break
+				}
+			}
+			if exhausted {
+				round.Exhausted++
+			}
+		}
+		for _, ID := range remaining {
+			round.Counts = append(round.Counts, RoundCount{PresentationID: ID, Votes: votes[ID]})
+		}
+		sort.SliceStable(round.Counts, func(i, j int) bool { return round.Counts[i].Votes > round.Counts[j].Votes })
+
+		if len(remaining) == 1 {
+			rounds = append(rounds, round)
+			break
+		}
+		// The sort is stable and the IDs ascending, so the last of the fewest is the one with the higher key.
+		round.Eliminated = round.Counts[len(round.Counts)-1].PresentationID
+		rounds = append(rounds, round)
+
+		left := make([]int64, 0, len(remaining)-1)
+		for _, ID := range remaining {
+			if ID != round.Eliminated {
+				left = append(left, ID)
+			}
+		}
+		remaining = left
+	}
+	return rounds
+}
+
+// Register the ballot and results routes of the meetups to the router.
+func RegisterVotingAPIRoutes(m *mux.Router, BallotStorage BallotStore, MeetupStorage MeetupStore, PresentationStorage PresentationStore, MetadataStorage MetadataStore, TransactionStorage TransactionStore, Guard *VoteGuard, Events *EventBus) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering voting API routes")
+	}
+	h := votingHandler{BallotStorage: BallotStorage, MeetupStorage: MeetupStorage, PresentationStorage: PresentationStorage, MetadataStorage: MetadataStorage, TransactionStorage: TransactionStorage, Guard: Guard, Events: Events}
+	m.HandleFunc("/meetups/{ID}/ballot", h.GetOwnBallot).Methods("GET")
+	m.HandleFunc("/meetups/{ID}/ballot", h.DeleteBallot).Methods("DELETE")
+	m.HandleFunc("/meetups/{ID}/ballot/limited", h.CastLimitedBallot).Methods("PUT")
+	m.HandleFunc("/meetups/{ID}/ballot/budget", h.CastBudgetBallot).Methods("PUT")
+	m.HandleFunc("/meetups/{ID}/ballot/ranked", h.CastRankedBallot).Methods("PUT")
+	m.HandleFunc("/meetups/{ID}/results", h.Results).Methods("GET")
+
+	return nil
+}
+
+type votingHandler struct {
+	BallotStorage       BallotStore
+	MeetupStorage       MeetupStore
+	PresentationStorage PresentationStore
+	MetadataStorage     MetadataStore
+	TransactionStorage  TransactionStore
+	Guard               *VoteGuard
+	Events              *EventBus
+}
+
+// Get the meetup of the ID route variable, writing the error response if there is none.
+func (h *votingHandler) getMeetup(ctx context.Context, w http.ResponseWriter, r *http.Request) (int64, Meetup, bool) {
+	vars := mux.Vars(r)
+	ID, err := strconv.ParseInt(vars["ID"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("ID not valid: %v", vars["ID"]))
+		return 0, Meetup{}, false
+	}
+
+	meetup, err := h.MeetupStorage.GetMeetup(ctx, ID)
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("Couldn't find meetup with id: %v", ID))
+		return 0, Meetup{}, false
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get meetup: %v", err)
+		writeInternalError(w)
+		return 0, Meetup{}, false
+	}
+	return ID, meetup, true
+}
+
+// The candidates of the meetup, with all the presentations by key.
+func (h *votingHandler) getCandidates(ctx context.Context, ID int64, meetup *Meetup) ([]int64, map[int64]Presentation, error) {
+	presentationIDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, meetups, err := h.MeetupStorage.GetAllMeetups(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := make(map[int64]Presentation, len(presentationIDs))
+	for index, presentationID := range presentationIDs {
+		byID[presentationID] = presentations[index]
+	}
+	return ballotCandidates(ID, meetup, presentationIDs, presentations, meetups), byID, nil
+}
+
+func (h *votingHandler) GetOwnBallot(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	ID, _, ok := h.getMeetup(ctx, w, r)
+	if !ok {
+		return
+	}
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/meetup/%v", ID))
+		writeLoginRequired(w, url)
+		return
+	}
+
+	salt, voter, ok := getVoterID(ctx, w, u, h.MetadataStorage, h.TransactionStorage)
+	if !ok {
+		return
+	}
+	ballot, err := h.BallotStorage.GetBallot(ctx, ID, voter)
+	// Ballots cast before the voter IDs are keyed by the email or its hash.
+	for _, legacy := range legacyBallotVoters(salt, u) {
+		if err != datastore.ErrNoSuchEntity {
+			break
+		}
+		ballot, err = h.BallotStorage.GetBallot(ctx, ID, legacy)
+	}
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("You haven't voted in meetup %v yet.", ID))
+		return
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get ballot: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, BallotPublicView{Mode: ballot.Mode, Choices: ballot.Choices, Cast: ballot.Cast})
+	if err != nil {
+		log.Errorf(ctx, "Failed to write ballot: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+func (h *votingHandler) CastLimitedBallot(w http.ResponseWriter, r *http.Request) {
+	h.castBallot(w, r, VotingLimited, func() ([]BallotChoice, error) {
+		form := LimitedBallotForm{}
+		err := json.NewDecoder(r.Body).Decode(&form)
+		choices := make([]BallotChoice, 0, len(form.Presentations))
+		for _, presentationID := range form.Presentations {
+			choices = append(choices, BallotChoice{PresentationID: presentationID, Points: 1})
+		}
+		return choices, err
+	})
+}
+
+func (h *votingHandler) CastBudgetBallot(w http.ResponseWriter, r *http.Request) {
+	h.castBallot(w, r, VotingBudget, func() ([]BallotChoice, error) {
+		form := BudgetBallotForm{}
+		err := json.NewDecoder(r.Body).Decode(&form)
+		return form.Points, err
+	})
+}
+
+func (h *votingHandler) CastRankedBallot(w http.ResponseWriter, r *http.Request) {
+	h.castBallot(w, r, VotingRanked, func() ([]BallotChoice, error) {
+		form := RankedBallotForm{}
+		err := json.NewDecoder(r.Body).Decode(&form)
+		choices := make([]BallotChoice, 0, len(form.Ranking))
+		for index, presentationID := range form.Ranking {
+			choices = append(choices, BallotChoice{PresentationID: presentationID, Points: index + 1})
+		}
+		return choices, err
+	})
+}
+
+// Replace the ballot of the user with the choices decoded from the request, if the meetup votes in the mode.
+func (h *votingHandler) castBallot(w http.ResponseWriter, r *http.Request, mode VotingMode, decode func() ([]BallotChoice, error)) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	ID, meetup, ok := h.getMeetup(ctx, w, r)
+	if !ok {
+		return
+	}
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/meetup/%v", ID))
+		writeLoginRequired(w, url)
+		return
+	}
+	if meetup.GetVotingMode() != mode {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Meetup %v uses %v voting.", ID, meetup.GetVotingMode()))
+		return
+	}
+	if meetup.VotingClosed(time.Now()) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Voting for meetup %v is closed.", ID))
+		return
+	}
+
+	choices, err := decode()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Couldn't decode JSON: %v", err))
+		return
+	}
+
+	candidates, _, err := h.getCandidates(ctx, ID, &meetup)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get candidates: %v", err)
+		writeInternalError(w)
+		return
+	}
+	fieldErrors := validateBallot(&meetup, mode, choices, candidates)
+	if len(fieldErrors) > 0 {
+		writeFieldErrors(w, fieldErrors)
+		return
+	}
+
+	salt, voter, ok := getVoterID(ctx, w, u, h.MetadataStorage, h.TransactionStorage)
+	if !ok {
+		return
+	}
+	if !h.Guard.Allow(ctx, w, r, salt, u) {
+		return
+	}
+	ballot := Ballot{Voter: voter, Mode: mode, Choices: choices, Cast: time.Now()}
+	err = h.BallotStorage.PutBallot(ctx, ID, &ballot)
+	for _, legacy := range legacyBallotVoters(salt, u) {
+		if err != nil {
+			break
+		}
+		err = h.BallotStorage.DeleteBallot(ctx, ID, legacy)
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't put ballot: %v", err)
+		writeInternalError(w)
+		return
+	}
+	h.Guard.Record(ctx, r, salt, voter, voteEventBallot, 0, ID)
+	h.Events.Publish(ctx, BallotCast{MeetupID: ID, Voter: voter, Mode: mode})
+	fmt.Fprint(w, "Voted!")
+}
+
+func (h *votingHandler) DeleteBallot(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	ID, meetup, ok := h.getMeetup(ctx, w, r)
+	if !ok {
+		return
+	}
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprintf("/public/#/meetup/%v", ID))
+		writeLoginRequired(w, url)
+		return
+	}
+	if meetup.VotingClosed(time.Now()) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("Voting for meetup %v is closed.", ID))
+		return
+	}
+
+	salt, voter, ok := getVoterID(ctx, w, u, h.MetadataStorage, h.TransactionStorage)
+	if !ok {
+		return
+	}
+	if !h.Guard.Allow(ctx, w, r, salt, u) {
+		return
+	}
+	voterKey := voter
+	_, err := h.BallotStorage.GetBallot(ctx, ID, voterKey)
+	for _, legacy := range legacyBallotVoters(salt, u) {
+		if err != datastore.ErrNoSuchEntity {
+			break
+		}
+		voterKey = legacy
+		_, err = h.BallotStorage.GetBallot(ctx, ID, voterKey)
+	}
+	if err == datastore.ErrNoSuchEntity {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("You haven't voted in meetup %v.", ID))
+		return
+	}
+	if err == nil {
+		err = h.BallotStorage.DeleteBallot(ctx, ID, voterKey)
+	}
+	if err != nil {
+		log.Errorf(ctx, "Couldn't delete ballot: %v", err)
+		writeInternalError(w)
+		return
+	}
+	h.Guard.Record(ctx, r, salt, voter, voteEventWithdraw, 0, ID)
+	h.Events.Publish(ctx, BallotCast{MeetupID: ID, Voter: voter, Mode: meetup.GetVotingMode(), Withdrawn: true})
+	fmt.Fprint(w, "Ballot withdrawn.")
+}
+
+func (h *votingHandler) Results(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	ID, meetup, ok := h.getMeetup(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	candidates, presentations, err := h.getCandidates(ctx, ID, &meetup)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get candidates: %v", err)
+		writeInternalError(w)
+		return
+	}
+	ballots, err := h.BallotStorage.GetBallots(ctx, ID)
+	if err != nil {
+		log.Errorf(ctx, "Couldn't get ballots: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	err = encoder.Encode(w, tallyVotes(&meetup, candidates, presentations, ballots))
+	if err != nil {
+		log.Errorf(ctx, "Failed to write results: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
@@ -0,0 +1,130 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+func TestInstantRunoff(t *testing.T) {
+	rankings := [][]int64{
+		{1, 2},
+		{1, 3},
+		{2, 3},
+		{3, 2},
+		{3, 2},
+		{4},
+	}
+
+	rounds := instantRunoff([]int64{4, 3, 2, 1}, rankings)
+
+	// 2 and 4 tie with the fewest first preferences and the higher key goes first. Then 2 goes and its ballot moves to 3.
+	eliminated := []int64{4, 2, 1, 0}
+	if len(rounds) != len(eliminated) {
+		t.Fatalf("Expected %v rounds, got %+v", len(eliminated), rounds)
+	}
+	for index, round := range rounds {
+		if round.Eliminated != eliminated[index] {
+			t.Errorf("Round %v should eliminate %v, got %+v", index+1, eliminated[index], round)
+		}
+	}
+	if rounds[1].Exhausted != 1 {
+		t.Errorf("The ballot only ranking 4 should be exhausted in round 2, got %+v", rounds[1])
+	}
+	if winner := rounds[3].Counts[0]; winner.PresentationID != 3 || winner.Votes != 4 {
+		t.Errorf("3 should win with 4 votes, got %+v", winner)
+	}
+}
+
+func TestTallyVotes(t *testing.T) {
+	presentations := map[int64]Presentation{1: {Title: "One"}, 2: {Title: "Two"}, 3: {Title: "Three"}}
+	ballots := []Ballot{
+		{Voter: "a", Mode: VotingBudget, Choices: []BallotChoice{{PresentationID: 1, Points: 2}, {PresentationID: 2, Points: 3}}},
+		{Voter: "b", Mode: VotingBudget, Choices: []BallotChoice{{PresentationID: 1, Points: 3}}},
+		{Voter: "c", Mode: VotingLimited, Choices: []BallotChoice{{PresentationID: 3, Points: 1}}},
+	}
+
+	results := tallyVotes(&Meetup{VotingMode: VotingBudget, VotesPerVoter: 5}, []int64{1, 2, 3}, presentations, ballots)
+
+	if results.Ballots != 2 {
+		t.Errorf("Only the 2 budget ballots should count, got %v", results.Ballots)
+	}
+	expected := []VotingResult{{PresentationID: 1, Title: "One", Score: 5, Rank: 1}, {PresentationID: 2, Title: "Two", Score: 3, Rank: 2}, {PresentationID: 3, Title: "Three", Score: 0, Rank: 3}}
+	if len(results.Results) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, results.Results)
+	}
+	for index := range expected {
+		if results.Results[index] != expected[index] {
+			t.Errorf("Result %v should be %+v, got %+v", index, expected[index], results.Results[index])
+		}
+	}
+}
+
+func TestValidateBallot(t *testing.T) {
+	candidates := []int64{1, 2, 3}
+	cases := []struct {
+		meetup  Meetup
+		choices []BallotChoice
+		valid   bool
+	}{
+		{Meetup{VotingMode: VotingLimited, VotesPerVoter: 2}, []BallotChoice{{PresentationID: 1, Points: 1}, {PresentationID: 2, Points: 1}}, true},
+		{Meetup{VotingMode: VotingLimited, VotesPerVoter: 2}, []BallotChoice{{PresentationID: 1, Points: 1}, {PresentationID: 2, Points: 1}, {PresentationID: 3, Points: 1}}, false},
+		{Meetup{VotingMode: VotingBudget, VotesPerVoter: 5}, []BallotChoice{{PresentationID: 1, Points: 4}, {PresentationID: 3, Points: 1}}, true},
+		{Meetup{VotingMode: VotingBudget, VotesPerVoter: 5}, []BallotChoice{{PresentationID: 1, Points: 4}, {PresentationID: 3, Points: 2}}, false},
+		{Meetup{VotingMode: VotingRanked}, []BallotChoice{{PresentationID: 3, Points: 1}, {PresentationID: 1, Points: 2}}, true},
+		{Meetup{VotingMode: VotingRanked}, []BallotChoice{{PresentationID: 3, Points: 1}, {PresentationID: 3, Points: 2}}, false},
+		{Meetup{VotingMode: VotingRanked}, []BallotChoice{{PresentationID: 4, Points: 1}}, false},
+		{Meetup{VotingMode: VotingRanked}, []BallotChoice{}, false},
+	}
+
+	for _, c := range cases {
+		fieldErrors := validateBallot(&c.meetup, c.meetup.VotingMode, c.choices, candidates)
+		if (len(fieldErrors) == 0) != c.valid {
+			t.Errorf("Ballot %+v for %v voting should be valid: %v, got %v", c.choices, c.meetup.VotingMode, c.valid, fieldErrors)
+		}
+	}
+}
+
+func TestUpvotesOnBallotMeetups(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+
+	ID, _ := store.AddPresentation(ctx, &Presentation{Title: "Go", Status: StatusAccepted})
+	store.AddMeetup(ctx, &Meetup{Title: "March", VotingMode: VotingBudget, VotesPerVoter: 5, Presentations: []int64{ID}})
+
+	response := serveAs(router, &user.User{ID: "1", Email: "voter@example.com"}, "PUT", fmt.Sprintf("/presentations/%v/vote", ID), nil)
+	if response.Code != http.StatusConflict {
+		t.Errorf("Presentations of a meetup voted on with ballots shouldn't be upvoted, got %v", response.Code)
+	}
+	if presentation, _ := store.GetPresentation(ctx, ID); len(presentation.Voters) != 0 {
+		t.Errorf("Expected no upvotes, got %v", presentation.Voters)
+	}
+}
+
+func TestPollVoteCounts(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	router := newPresentationTestRouter(t, store)
+	voter := &user.User{ID: "1", Email: "voter@example.com"}
+
+	ID, _ := store.AddPresentation(ctx, &Presentation{Title: "Go", Status: StatusAccepted})
+	poll := func(etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/presentations", nil)
+		r.Header.Set("If-None-Match", etag)
+		return serveRequestAs(router, voter, r)
+	}
+
+	etag := poll("").Header().Get("ETag")
+	if response := poll(etag); response.Code != http.StatusNotModified {
+		t.Errorf("Expected the list not modified, got %v", response.Code)
+	}
+	serveAs(router, voter, "PUT", fmt.Sprintf("/presentations/%v/vote", ID), nil)
+	if response := poll(etag); response.Code != http.StatusOK || response.Header().Get("ETag") == etag {
+		t.Errorf("Expected the list with a new ETag after an upvote, got %v", response.Code)
+	}
+}
@@ -0,0 +1,9 @@
+package api
+
+type ImportResult struct {
+	Created     int
+	Overwritten int
+	Skipped     int
+	// Entities which already exist, reported when the import fails because of them.
+	Conflicts []string `json:",omitempty"`
+}
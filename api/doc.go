@@ -0,0 +1,3 @@
+// Package api holds the types sent to and received from the /api/v1 routes. The server and the client
+// package share them, so that clients don't have to import the server with its App Engine dependencies.
+package api
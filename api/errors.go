@@ -0,0 +1,17 @@
+package api
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// The body of every error response.
+type ErrorResponse struct {
+	Code        string       `json:"code"`
+	Message     string       `json:"message"`
+	FieldErrors []FieldError `json:"field_errors,omitempty"`
+	RequestID   string       `json:"request_id"`
+	// Where to log in, set when the request requires a logged in user.
+	LoginURL string      `json:"login_url,omitempty"`
+	Details  interface{} `json:"details,omitempty"`
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"time"
+)
+
+type MeetupPublicView struct {
+	Key           int64
+	Title         string
+	Description   string
+	Presentations []int64
+	Date          time.Time
+	VoteTimeEnd   time.Time
+	VotingMode    VotingMode
+	VotesPerVoter int
+	CallForPapers CallForPapers
+	// Whether presentations can be submitted to the meetup right now.
+	CallForPapersOpen bool
+}
+
+type MeetupForm struct {
+	Title       string    `validate:"required,max=200"`
+	Description string    `validate:"required,max=5000"`
+	Date        time.Time `validate:"required"`
+	VoteTimeEnd time.Time `validate:"required"`
+	// Approval if empty. VotesPerVoter is mandatory for limited and budget voting, 0 ranks any number in ranked choice.
+	VotingMode    VotingMode
+	VotesPerVoter int
+	Presentations []int64
+	Latitude      float64 `validate:"min=-90,max=90"`
+	Longitude     float64 `validate:"min=-180,max=180"`
+	// Optional, leave Start empty to not take submissions.
+	CallForPapers CallForPapers
+}
+
+// Submissions of presentations to a meetup. A meetup without a call for papers has a zero Start.
+type CallForPapers struct {
+	Start time.Time
+	End   time.Time
+	// Asked to every speaker submitting, answered in the same order in Presentation.Answers.
+	Questions []string
+	// How many presentations a speaker may submit, 0 for no limit.
+	MaxPerSpeaker int
+}
+
+func (c *CallForPapers) IsOpen(now time.Time) bool {
+	return !c.Start.IsZero() && !now.Before(c.Start) && now.Before(c.End)
+}
+
+// A slot of the agenda of a meetup: a presentation, a break or networking.
+type AgendaSlot struct {
+	Start   time.Time
+	Minutes int
+	Kind    string
+	// Set for presentation slots only.
+	PresentationID int64
+	// Shown for breaks and networking, presentations show their own title.
+	Title string
+}
+
+func (s *AgendaSlot) End() time.Time {
+	return s.Start.Add(time.Duration(s.Minutes) * time.Minute)
+}
+
+type AgendaSlotPublicView struct {
+	Start          time.Time
+	End            time.Time
+	Minutes        int
+	Kind           string
+	Title          string
+	PresentationID int64
+	Speakers       []string
+}
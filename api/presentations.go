@@ -0,0 +1,51 @@
+package api
+
+import (
+	"time"
+)
+
+// Where a presentation is in the review workflow.
+type PresentationStatus string
+
+const (
+	StatusDraft       PresentationStatus = "draft"
+	StatusSubmitted   PresentationStatus = "submitted"
+	StatusUnderReview PresentationStatus = "under_review"
+	StatusAccepted    PresentationStatus = "accepted"
+	StatusRejected    PresentationStatus = "rejected"
+	StatusScheduled   PresentationStatus = "scheduled"
+	StatusDelivered   PresentationStatus = "delivered"
+	StatusWithdrawn   PresentationStatus = "withdrawn"
+)
+
+type PresentationStatusForm struct {
+	Status PresentationStatus
+}
+
+type PresentationForm struct {
+	Title       string `validate:"required,max=200"`
+	Description string `validate:"required,max=5000"`
+	// Comma separated full names of the speakers.
+	Speakers string `validate:"required"`
+	// Optional meetup to submit to, its call for papers has to be open.
+	MeetupID int64
+	Answers  []string
+}
+
+type PresentationPublicView struct {
+	Key          int64
+	Title        string
+	Description  string
+	Speakers     []SpeakerForPresentationPublicView
+	Votes        int
+	VotesFlagged bool
+	Submitted    time.Time
+	Status       PresentationStatus
+	MeetupID     int64
+	Answers      []string
+}
+
+type SpeakerForPresentationPublicView struct {
+	Name string
+	Key  int64
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"time"
+)
+
+type ReviewForm struct {
+	Relevance int    `validate:"min=1,max=5"`
+	Clarity   int    `validate:"min=1,max=5"`
+	Novelty   int    `validate:"min=1,max=5"`
+	Comment   string `validate:"max=5000"`
+}
+
+type ReviewPublicView struct {
+	Reviewer  string
+	Relevance int
+	Clarity   int
+	Novelty   int
+	Comment   string
+	Updated   time.Time
+}
+
+// Mean scores of a presentation over all its reviews.
+type ReviewScores struct {
+	PresentationID int64
+	Title          string
+	Reviews        int
+	Relevance      float64
+	Clarity        float64
+	Novelty        float64
+	// Mean of the three criteria, what the presentations are ranked by.
+	Average float64
+}
+
+type PresentationReviewsPublicView struct {
+	Scores  ReviewScores
+	Reviews []ReviewPublicView
+}
+
+// A presentation waiting for the review of the current reviewer. Speakers are empty in blind review.
+type ReviewQueueItem struct {
+	Key         int64
+	Title       string
+	Description string
+	Speakers    []string
+	Answers     []string
+	Status      PresentationStatus
+}
@@ -0,0 +1,10 @@
+package api
+
+type SearchResult struct {
+	Kind  string
+	Key   int64
+	Title string
+	Score float64
+	// Excerpts of the matching fields, HTML escaped with the matches in <b> tags.
+	Highlights map[string]string
+}
@@ -0,0 +1,18 @@
+package api
+
+type SpeakerPublicView struct {
+	Key     int64
+	Name    string
+	Surname string
+	About   string
+	Email   string
+	Company string
+}
+
+type SpeakerForm struct {
+	Name    string `validate:"required,max=100"`
+	Surname string `validate:"required,max=100"`
+	About   string `validate:"max=5000"`
+	Email   string `validate:"required,email,max=254"`
+	Company string `validate:"max=100"`
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"time"
+)
+
+// How the presentations of a meetup are voted on.
+type VotingMode string
+
+const (
+	// Upvote any number of presentations, through the vote routes of the presentations.
+	VotingApproval VotingMode = "approval"
+	// Upvote at most VotesPerVoter presentations.
+	VotingLimited VotingMode = "limited"
+	// Spread VotesPerVoter points over the presentations.
+	VotingBudget VotingMode = "budget"
+	// Rank the presentations, tallied with instant runoff.
+	VotingRanked VotingMode = "ranked"
+)
+
+type BallotChoice struct {
+	PresentationID int64
+	// Always 1 for limited voting and the rank, starting with 1, for ranked choice.
+	Points int
+}
+
+type LimitedBallotForm struct {
+	Presentations []int64
+}
+
+type BudgetBallotForm struct {
+	Points []BallotChoice
+}
+
+type RankedBallotForm struct {
+	// Most preferred first.
+	Ranking []int64
+}
+
+type BallotPublicView struct {
+	Mode    VotingMode
+	Choices []BallotChoice
+	Cast    time.Time
+}
+
+type VotingResults struct {
+	Mode VotingMode
+	// How the votes were counted.
+	Method  string
+	Ballots int
+	// Best first.
+	Results []VotingResult
+	// The rounds of the instant runoff, for ranked choice only.
+	Rounds []VotingRound
+}
+
+type VotingResult struct {
+	PresentationID int64
+	Title          string
+	// Votes, points, or for ranked choice the round the presentation was eliminated in. Higher is better.
+	Score int
+	// Presentations with the same score share the rank.
+	Rank int
+}
+
+type VotingRound struct {
+	// First preferences of the presentations still in the race.
+	Counts []RoundCount
+	// Ballots without any presentation left in the race.
+	Exhausted int
+	// 0 in the last round, which only has the winner left.
+	Eliminated int64
+}
+
+type RoundCount struct {
+	PresentationID int64
+	Votes          int
+}
+
+// A pattern of votes which looks like someone voting under several identities.
+type SuspiciousFinding struct {
+	// shared-network or burst.
+	Pattern       string
+	Detail        string
+	Voters        []string
+	Presentations []int64
+	Meetups       []int64
+}
+
+type SuspiciousVotesReport struct {
+	Since    time.Time
+	Votes    int
+	Findings []SuspiciousFinding
+}
+
+type InvalidateVotesForm struct {
+	// Voter IDs as reported, or emails, which only match the votes stored before the voter IDs.
+	Voters []string
+	// Also keep them from voting again.
+	Block bool
+}
@@ -0,0 +1,27 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/memcache"
+)
+
+// Rate limiter counting in App Engine memcache, shared by all the instances.
+// Every window has its own counter, which expires with the window.
+type MemcacheRateLimiter struct{}
+
+func (l *MemcacheRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	bucket := fmt.Sprintf("ratelimit:%v:%v", key, time.Now().UnixNano()/int64(window))
+	err := memcache.Add(ctx, &memcache.Item{Key: bucket, Value: []byte("0"), Expiration: window})
+	if err != nil && err != memcache.ErrNotStored {
+		return true, err
+	}
+	count, err := memcache.Increment(ctx, bucket, 1, 0)
+	if err != nil {
+		return true, err
+	}
+	return count <= uint64(limit), nil
+}
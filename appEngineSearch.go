@@ -0,0 +1,157 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/search"
+)
+
+const searchIndexName = "MeetupRest"
+
+// Search index backed by the App Engine Search API.
+// The API doesn't weight fields, so the ranking comes from its match scorer.
+type AppEngineSearchIndex struct{}
+
+// Document with the kind as a facet, so it can filter the results without being matched by the query.
+type appEngineSearchDocument struct {
+	Fields search.FieldList
+	Kind   string
+}
+
+func (d *appEngineSearchDocument) Load(fields []search.Field, meta *search.DocumentMetadata) error {
+	d.Fields = fields
+	for _, facet := range meta.Facets {
+		if facet.Name == "Kind" {
+			d.Kind = fmt.Sprint(facet.Value)
+		}
+	}
+	return nil
+}
+
+func (d *appEngineSearchDocument) Save() ([]search.Field, *search.DocumentMetadata, error) {
+	meta := &search.DocumentMetadata{Facets: []search.Facet{{Name: "Kind", Value: search.Atom(d.Kind)}}}
+	return d.Fields, meta, nil
+}
+
+func (i *AppEngineSearchIndex) IndexDocument(ctx context.Context, document SearchDocument) error {
+	index, err := search.Open(searchIndexName)
+	if err != nil {
+		return err
+	}
+
+	source := appEngineSearchDocument{
+		Fields: search.FieldList{{Name: "DisplayTitle", Value: document.Title}},
+		Kind:   document.Kind,
+	}
+	for _, field := range document.Fields {
+		source.Fields = append(source.Fields, search.Field{Name: field.Name, Value: field.Text})
+	}
+
+	_, err = index.Put(ctx, searchDocumentID(document.Kind, document.Key), &source)
+	return err
+}
+
+func (i *AppEngineSearchIndex) RemoveDocument(ctx context.Context, kind string, key int64) error {
+	index, err := search.Open(searchIndexName)
+	if err != nil {
+		return err
+	}
+	return index.Delete(ctx, searchDocumentID(kind, key))
+}
+
+func (i *AppEngineSearchIndex) Search(ctx context.Context, query string, kind string, limit int) ([]SearchResult, error) {
+	index, err := search.Open(searchIndexName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Quote the words, so that nothing the user types is taken for the query syntax.
+	terms := searchTerms(query)
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, strconv.Quote(term))
+	}
+	options := &search.SearchOptions{
+		Limit:       limit,
+		Sort:        &search.SortOptions{Scorer: search.MatchScorer},
+		Expressions: []search.FieldExpression{{Name: "Score", Expr: "_score"}},
+	}
+	if kind != "" {
+		options.Refinements = []search.Facet{{Name: "Kind", Value: search.Atom(kind)}}
+	}
+
+	results := make([]SearchResult, 0)
+	iterator := index.Search(ctx, strings.Join(quoted, " "), options)
+	for {
+		document := appEngineSearchDocument{}
+		ID, err := iterator.Next(&document)
+		if err == search.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := SearchResult{Kind: document.Kind}
+		result.Key, err = strconv.ParseInt(strings.TrimPrefix(ID, document.Kind+"-"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("search document ID not valid: %v", ID)
+		}
+		documentFields := make([]SearchField, 0, len(document.Fields))
+		for _, field := range document.Fields {
+			switch {
+			case field.Name == "DisplayTitle":
+				result.Title = fmt.Sprint(field.Value)
+			case field.Name == "Score" && field.Derived:
+				result.Score, _ = field.Value.(float64)
+			default:
+				documentFields = append(documentFields, SearchField{Name: field.Name, Text: fmt.Sprint(field.Value)})
+			}
+		}
+		result.Highlights = highlightFields(documentFields, terms)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (i *AppEngineSearchIndex) Clear(ctx context.Context) error {
+	index, err := search.Open(searchIndexName)
+	if err != nil {
+		return err
+	}
+
+	for {
+		IDs := make([]string, 0)
+		iterator := index.List(ctx, &search.ListOptions{IDsOnly: true})
+		for {
+			ID, err := iterator.Next(nil)
+			if err == search.Done {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			IDs = append(IDs, ID)
+		}
+		if len(IDs) == 0 {
+			return nil
+		}
+
+		// DeleteMulti takes up to 200 documents at once.
+		for len(IDs) > 0 {
+			batch := IDs
+			if len(batch) > 200 {
+				batch = batch[:200]
+			}
+			err = index.DeleteMulti(ctx, batch)
+			if err != nil {
+				return err
+			}
+			IDs = IDs[len(batch):]
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package MeetupRest
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+const oauthEmailScope = "https://www.googleapis.com/auth/userinfo.email"
+
+// Get the logged in user. Besides the App Engine login cookie, tools like the client package
+// can authenticate with an OAuth2 bearer token in the Authorization header.
+// A variable, so that the handler tests can log in without App Engine.
+var currentUser = func(ctx context.Context, r *http.Request) *user.User {
+	u := user.Current(ctx)
+	if u != nil {
+		return u
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return nil
+	}
+	u, err := user.CurrentOAuth(ctx, oauthEmailScope)
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// Browsers send the login cookie along with cross-site form posts, but can't set a JSON content type or
+// custom headers on them without a CORS preflight, which isn't answered. So requests changing anything have
+// to come with one of those, and from the same host if the browser tells the Origin.
+func rejectCrossSiteRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			originURL, err := url.Parse(origin)
+			if err != nil || originURL.Host != r.Host {
+				writeError(w, http.StatusForbidden, "Cross-site requests aren't allowed.")
+				return
+			}
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != "application/json" && mediaType != mergePatchContentType && r.Header.Get("X-Requested-With") == "" && r.Header.Get("Authorization") == "" {
+			writeError(w, http.StatusForbidden, "Send a JSON body or the X-Requested-With header.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,65 @@
+package MeetupRest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/user"
+)
+
+// Serve the request as the user, anonymously if u is nil.
+func serveAs(handler http.Handler, u *user.User, method string, url string, body io.Reader) *httptest.ResponseRecorder {
+	return serveRequestAs(handler, u, httptest.NewRequest(method, url, body))
+}
+
+func serveRequestAs(handler http.Handler, u *user.User, r *http.Request) *httptest.ResponseRecorder {
+	loggedIn := currentUser
+	currentUser = func(ctx context.Context, r *http.Request) *user.User {
+		return u
+	}
+	defer func() {
+		currentUser = loggedIn
+	}()
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder
+}
+
+func TestRejectCrossSiteRequests(t *testing.T) {
+	handler := rejectCrossSiteRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cases := []struct {
+		method  string
+		headers map[string]string
+		status  int
+	}{
+		{"GET", nil, http.StatusNoContent},
+		{"POST", nil, http.StatusForbidden},
+		{"POST", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, http.StatusForbidden},
+		{"POST", map[string]string{"Content-Type": "text/plain"}, http.StatusForbidden},
+		{"POST", map[string]string{"Content-Type": "application/json; charset=utf-8"}, http.StatusNoContent},
+		{"PATCH", map[string]string{"Content-Type": "application/merge-patch+json"}, http.StatusNoContent},
+		{"DELETE", map[string]string{"X-Requested-With": "XMLHttpRequest"}, http.StatusNoContent},
+		{"POST", map[string]string{"Authorization": "Bearer token"}, http.StatusNoContent},
+		{"POST", map[string]string{"Content-Type": "application/json", "Origin": "http://example.com"}, http.StatusNoContent},
+		{"POST", map[string]string{"Content-Type": "application/json", "Origin": "http://evil.example.org"}, http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest(c.method, "http://example.com/api/v1/sync", nil)
+		for key, value := range c.headers {
+			request.Header.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != c.status {
+			t.Errorf("%v with %v should be %v, got %v", c.method, c.headers, c.status, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,474 @@
+// Package client is a Go client for the /api/v1 REST API of api.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cube2222/MeetupRest/api"
+	"golang.org/x/net/context"
+)
+
+// Client of a MeetupRest instance. The zero HTTPClient uses http.DefaultClient.
+type Client struct {
+	// Address of the instance, like https://example.appspot.com
+	BaseURL string
+	// OAuth2 access token with the userinfo.email scope, sent as a bearer token if set.
+	Token      string
+	HTTPClient *http.Client
+}
+
+func New(baseURL string, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token}
+}
+
+// Error returned by the API. It holds the error envelope sent by the server.
+type Error struct {
+	StatusCode int
+	api.ErrorResponse
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("meetuprest: %v %v: %v", e.StatusCode, e.Code, e.Message)
+	for _, fieldError := range e.FieldErrors {
+		message += fmt.Sprintf("; %v: %v", fieldError.Field, fieldError.Message)
+	}
+	return message
+}
+
+// Check whether err is an API error with the given status code.
+func IsStatus(err error, statusCode int) bool {
+	apiError, ok := err.(*Error)
+	return ok && apiError.StatusCode == statusCode
+}
+
+func IsNotFound(err error) bool {
+	return IsStatus(err, http.StatusNotFound)
+}
+
+// Check whether err is an update or delete refused because the entity changed since its ETag was read.
+func IsPreconditionFailed(err error) bool {
+	return IsStatus(err, http.StatusPreconditionFailed)
+}
+
+type ifMatchKey struct{}
+
+// Make the updates and deletes sent with the returned context conditional on the entity still having the ETag,
+// as returned by the Get...WithETag methods. They fail with a 412 Precondition Failed otherwise.
+func IfMatch(ctx context.Context, etag string) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, etag)
+}
+
+func (c *Client) ListSpeakers(ctx context.Context) ([]api.SpeakerPublicView, error) {
+	speakers := make([]api.SpeakerPublicView, 0)
+	err := c.do(ctx, "GET", "/api/v1/speakers", nil, nil, &speakers)
+	return speakers, err
+}
+
+func (c *Client) GetSpeaker(ctx context.Context, ID int64) (api.SpeakerPublicView, error) {
+	speaker, _, err := c.GetSpeakerWithETag(ctx, ID)
+	return speaker, err
+}
+
+// Get the speaker and its ETag, to update it with IfMatch.
+func (c *Client) GetSpeakerWithETag(ctx context.Context, ID int64) (api.SpeakerPublicView, string, error) {
+	speaker := api.SpeakerPublicView{}
+	header, err := c.send(ctx, "GET", fmt.Sprintf("/api/v1/speakers/%v", ID), nil, nil, &speaker)
+	return speaker, header.Get("ETag"), err
+}
+
+// Create the speaker and return its key.
+func (c *Client) CreateSpeaker(ctx context.Context, form api.SpeakerForm) (int64, error) {
+	return c.create(ctx, "/api/v1/speakers", form)
+}
+
+// Replace all the fields of the speaker.
+func (c *Client) UpdateSpeaker(ctx context.Context, ID int64, form api.SpeakerForm) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/speakers/%v", ID), nil, form, nil)
+}
+
+// Apply a JSON merge patch to the speaker: fields missing from patch stay untouched, nil fields get cleared.
+func (c *Client) PatchSpeaker(ctx context.Context, ID int64, patch map[string]interface{}) error {
+	return c.do(ctx, "PATCH", fmt.Sprintf("/api/v1/speakers/%v", ID), nil, patch, nil)
+}
+
+// Move the speaker to the trash. Cascading removes it from its presentations as well (admin only).
+func (c *Client) DeleteSpeaker(ctx context.Context, ID int64, cascade bool) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/api/v1/speakers/%v", ID), cascadeQuery(cascade), nil, nil)
+}
+
+func (c *Client) ListPresentations(ctx context.Context) ([]api.PresentationPublicView, error) {
+	presentations := make([]api.PresentationPublicView, 0)
+	err := c.do(ctx, "GET", "/api/v1/presentations", nil, nil, &presentations)
+	return presentations, err
+}
+
+func (c *Client) GetPresentation(ctx context.Context, ID int64) (api.PresentationPublicView, error) {
+	presentation, _, err := c.GetPresentationWithETag(ctx, ID)
+	return presentation, err
+}
+
+// Get the presentation and its ETag, to update it with IfMatch.
+func (c *Client) GetPresentationWithETag(ctx context.Context, ID int64) (api.PresentationPublicView, string, error) {
+	presentation := api.PresentationPublicView{}
+	header, err := c.send(ctx, "GET", fmt.Sprintf("/api/v1/presentations/%v", ID), nil, nil, &presentation)
+	return presentation, header.Get("ETag"), err
+}
+
+// Create the presentation and return its key.
+func (c *Client) CreatePresentation(ctx context.Context, form api.PresentationForm) (int64, error) {
+	return c.create(ctx, "/api/v1/presentations", form)
+}
+
+// Replace all the fields of the presentation.
+func (c *Client) UpdatePresentation(ctx context.Context, ID int64, form api.PresentationForm) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/presentations/%v", ID), nil, form, nil)
+}
+
+// Apply a JSON merge patch to the presentation.
+func (c *Client) PatchPresentation(ctx context.Context, ID int64, patch map[string]interface{}) error {
+	return c.do(ctx, "PATCH", fmt.Sprintf("/api/v1/presentations/%v", ID), nil, patch, nil)
+}
+
+// Move the presentation to the trash. Cascading removes it from its meetups as well (admin only).
+func (c *Client) DeletePresentation(ctx context.Context, ID int64, cascade bool) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/api/v1/presentations/%v", ID), cascadeQuery(cascade), nil, nil)
+}
+
+// Move a presentation to another status of the review workflow.
+func (c *Client) SetPresentationStatus(ctx context.Context, ID int64, status api.PresentationStatus) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/presentations/%v/status", ID), nil, api.PresentationStatusForm{Status: status}, nil)
+}
+
+func (c *Client) Upvote(ctx context.Context, presentationID int64) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/presentations/%v/vote", presentationID), nil, nil, nil)
+}
+
+func (c *Client) Downvote(ctx context.Context, presentationID int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/api/v1/presentations/%v/vote", presentationID), nil, nil, nil)
+}
+
+func (c *Client) HasUpvoted(ctx context.Context, presentationID int64) (bool, error) {
+	var response string
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/presentations/%v/vote", presentationID), nil, nil, &response)
+	return strings.TrimSpace(response) == "true", err
+}
+
+// List the anonymized IDs of the users who upvoted the presentation (admin only).
+func (c *Client) ListVotes(ctx context.Context, presentationID int64) ([]string, error) {
+	voters := make([]string, 0)
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/presentations/%v/votes", presentationID), nil, nil, &voters)
+	return voters, err
+}
+
+// Score a presentation as the current reviewer, replacing an earlier review.
+func (c *Client) ReviewPresentation(ctx context.Context, presentationID int64, form api.ReviewForm) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/presentations/%v/review", presentationID), nil, form, nil)
+}
+
+// Get the reviews of a presentation with its mean scores (reviewers and admins).
+func (c *Client) ListReviews(ctx context.Context, presentationID int64) (api.PresentationReviewsPublicView, error) {
+	reviews := api.PresentationReviewsPublicView{}
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/presentations/%v/reviews", presentationID), nil, nil, &reviews)
+	return reviews, err
+}
+
+// Get the presentations the current reviewer still has to score.
+func (c *Client) ReviewQueue(ctx context.Context) ([]api.ReviewQueueItem, error) {
+	queue := make([]api.ReviewQueueItem, 0)
+	err := c.do(ctx, "GET", "/api/v1/reviews/queue", nil, nil, &queue)
+	return queue, err
+}
+
+// Get the mean scores of the reviewed presentations, best first (reviewers and admins).
+func (c *Client) ReviewScores(ctx context.Context) ([]api.ReviewScores, error) {
+	scores := make([]api.ReviewScores, 0)
+	err := c.do(ctx, "GET", "/api/v1/reviews/scores", nil, nil, &scores)
+	return scores, err
+}
+
+func (c *Client) ListMeetups(ctx context.Context) ([]api.MeetupPublicView, error) {
+	meetups := make([]api.MeetupPublicView, 0)
+	err := c.do(ctx, "GET", "/api/v1/meetups", nil, nil, &meetups)
+	return meetups, err
+}
+
+func (c *Client) GetMeetup(ctx context.Context, ID int64) (api.MeetupPublicView, error) {
+	meetup, _, err := c.GetMeetupWithETag(ctx, ID)
+	return meetup, err
+}
+
+// Get the meetup and its ETag, to update it or its agenda with IfMatch.
+func (c *Client) GetMeetupWithETag(ctx context.Context, ID int64) (api.MeetupPublicView, string, error) {
+	meetup := api.MeetupPublicView{}
+	header, err := c.send(ctx, "GET", fmt.Sprintf("/api/v1/meetups/%v", ID), nil, nil, &meetup)
+	return meetup, header.Get("ETag"), err
+}
+
+// Create the meetup and return its key.
+func (c *Client) CreateMeetup(ctx context.Context, form api.MeetupForm) (int64, error) {
+	return c.create(ctx, "/api/v1/meetups", form)
+}
+
+// Replace all the fields of the meetup.
+func (c *Client) UpdateMeetup(ctx context.Context, ID int64, form api.MeetupForm) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/meetups/%v", ID), nil, form, nil)
+}
+
+// Apply a JSON merge patch to the meetup.
+func (c *Client) PatchMeetup(ctx context.Context, ID int64, patch map[string]interface{}) error {
+	return c.do(ctx, "PATCH", fmt.Sprintf("/api/v1/meetups/%v", ID), nil, patch, nil)
+}
+
+func (c *Client) DeleteMeetup(ctx context.Context, ID int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/api/v1/meetups/%v", ID), nil, nil, nil)
+}
+
+// Get the ballot the user cast in the meetup.
+func (c *Client) GetBallot(ctx context.Context, meetupID int64) (api.BallotPublicView, error) {
+	ballot := api.BallotPublicView{}
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/meetups/%v/ballot", meetupID), nil, nil, &ballot)
+	return ballot, err
+}
+
+func (c *Client) CastLimitedBallot(ctx context.Context, meetupID int64, presentations []int64) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/meetups/%v/ballot/limited", meetupID), nil, api.LimitedBallotForm{Presentations: presentations}, nil)
+}
+
+func (c *Client) CastBudgetBallot(ctx context.Context, meetupID int64, points []api.BallotChoice) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/meetups/%v/ballot/budget", meetupID), nil, api.BudgetBallotForm{Points: points}, nil)
+}
+
+// Rank the presentations of the meetup, most preferred first.
+func (c *Client) CastRankedBallot(ctx context.Context, meetupID int64, ranking []int64) error {
+	return c.do(ctx, "PUT", fmt.Sprintf("/api/v1/meetups/%v/ballot/ranked", meetupID), nil, api.RankedBallotForm{Ranking: ranking}, nil)
+}
+
+func (c *Client) WithdrawBallot(ctx context.Context, meetupID int64) error {
+	return c.do(ctx, "DELETE", fmt.Sprintf("/api/v1/meetups/%v/ballot", meetupID), nil, nil, nil)
+}
+
+func (c *Client) VotingResults(ctx context.Context, meetupID int64) (api.VotingResults, error) {
+	results := api.VotingResults{}
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/meetups/%v/results", meetupID), nil, nil, &results)
+	return results, err
+}
+
+func (c *Client) GetAgenda(ctx context.Context, meetupID int64) ([]api.AgendaSlotPublicView, error) {
+	agenda := make([]api.AgendaSlotPublicView, 0)
+	err := c.do(ctx, "GET", fmt.Sprintf("/api/v1/meetups/%v/agenda", meetupID), nil, nil, &agenda)
+	return agenda, err
+}
+
+// Replace the agenda of the meetup and return it as scheduled.
+func (c *Client) SetAgenda(ctx context.Context, meetupID int64, slots []api.AgendaSlot) ([]api.AgendaSlotPublicView, error) {
+	agenda := make([]api.AgendaSlotPublicView, 0)
+	err := c.do(ctx, "PUT", fmt.Sprintf("/api/v1/meetups/%v/agenda", meetupID), nil, slots, &agenda)
+	return agenda, err
+}
+
+// Schedule all the presentations of the meetup, most voted first. Zero minutes means the default of the server,
+// a breakEvery of 0 means no breaks.
+func (c *Client) AutoSchedule(ctx context.Context, meetupID int64, minutes int, breakEvery int) ([]api.AgendaSlotPublicView, error) {
+	values := url.Values{}
+	if minutes > 0 {
+		values.Set("minutes", strconv.Itoa(minutes))
+	}
+	if breakEvery > 0 {
+		values.Set("breakEvery", strconv.Itoa(breakEvery))
+	}
+	agenda := make([]api.AgendaSlotPublicView, 0)
+	err := c.do(ctx, "POST", fmt.Sprintf("/api/v1/meetups/%v/agenda/auto", meetupID), values, nil, &agenda)
+	return agenda, err
+}
+
+// Push the meetups to meetup.com (admin only).
+func (c *Client) Sync(ctx context.Context) error {
+	return c.do(ctx, "POST", "/api/v1/sync", nil, nil, nil)
+}
+
+// Search the speakers, presentations and meetups. Kind is optional, a limit of 0 means the default of the server.
+func (c *Client) Search(ctx context.Context, query string, kind string, limit int) ([]api.SearchResult, error) {
+	values := url.Values{"q": {query}}
+	if kind != "" {
+		values.Set("kind", kind)
+	}
+	if limit != 0 {
+		values.Set("limit", strconv.Itoa(limit))
+	}
+	results := make([]api.SearchResult, 0)
+	err := c.do(ctx, "GET", "/api/v1/search", values, nil, &results)
+	return results, err
+}
+
+// Replace the voter emails stored before the votes were anonymized (admin only). Responds with what was migrated.
+func (c *Client) AnonymizeVotes(ctx context.Context) (string, error) {
+	var response string
+	err := c.do(ctx, "POST", "/api/v1/votes/anonymize", nil, nil, &response)
+	return response, err
+}
+
+// Report the suspicious votes of the last hours, 24 if 0 (admin only).
+func (c *Client) SuspiciousVotes(ctx context.Context, hours int) (api.SuspiciousVotesReport, error) {
+	values := url.Values{}
+	if hours != 0 {
+		values.Set("hours", strconv.Itoa(hours))
+	}
+	report := api.SuspiciousVotesReport{}
+	err := c.do(ctx, "GET", "/api/v1/votes/suspicious", values, nil, &report)
+	return report, err
+}
+
+// Remove the votes of the voters, given by ID or email, and block them if asked to (admin only). Responds with what was removed.
+func (c *Client) InvalidateVotes(ctx context.Context, form api.InvalidateVotesForm) (string, error) {
+	var response string
+	err := c.do(ctx, "POST", "/api/v1/votes/invalidate", nil, form, &response)
+	return response, err
+}
+
+// List the IDs of the blocked voters (admin only).
+func (c *Client) BlockedVoters(ctx context.Context) ([]string, error) {
+	voters := make([]string, 0)
+	err := c.do(ctx, "GET", "/api/v1/votes/blocked", nil, nil, &voters)
+	return voters, err
+}
+
+// Let a blocked voter vote again (admin only).
+func (c *Client) UnblockVoter(ctx context.Context, voter string) error {
+	return c.do(ctx, "DELETE", "/api/v1/votes/blocked/"+url.PathEscape(voter), nil, nil, nil)
+}
+
+// Rebuild the search index from the stored entities (admin only).
+func (c *Client) Reindex(ctx context.Context) error {
+	return c.do(ctx, "POST", "/api/v1/search/reindex", nil, nil, nil)
+}
+
+// Export all the data, keeping the keys (admin only). The archive is returned as sent, so it can be saved
+// and imported again without depending on its format.
+func (c *Client) Export(ctx context.Context) (json.RawMessage, error) {
+	archive := json.RawMessage{}
+	err := c.do(ctx, "GET", "/api/v1/export", nil, nil, &archive)
+	return archive, err
+}
+
+// Import an archive (admin only). Conflict is fail, skip or overwrite and decides what happens to entities
+// which already exist. On conflicts with the fail mode, the Details of the returned *Error list them.
+func (c *Client) Import(ctx context.Context, archive json.RawMessage, conflict string) (api.ImportResult, error) {
+	result := api.ImportResult{}
+	err := c.do(ctx, "POST", "/api/v1/import", url.Values{"conflict": {conflict}}, archive, &result)
+	return result, err
+}
+
+// Get a metadata value (admin only).
+func (c *Client) GetMetadata(ctx context.Context, key string) (string, error) {
+	var value string
+	err := c.do(ctx, "GET", "/api/v1/metadata/"+url.PathEscape(key), nil, nil, &value)
+	return value, err
+}
+
+// Set a metadata value, like the APIKEY and GroupName used to sync with meetup.com (admin only).
+func (c *Client) SetMetadata(ctx context.Context, key string, value string) error {
+	return c.do(ctx, "PUT", "/api/v1/metadata/"+url.PathEscape(key), url.Values{"data": {value}}, nil, nil)
+}
+
+func cascadeQuery(cascade bool) url.Values {
+	if !cascade {
+		return nil
+	}
+	return url.Values{"cascade": {"true"}}
+}
+
+// Post the form and parse the key of the created entity from the response.
+func (c *Client) create(ctx context.Context, path string, form interface{}) (int64, error) {
+	var response string
+	err := c.do(ctx, "POST", path, nil, form, &response)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(response), 10, 64)
+}
+
+// Send the request with body encoded as JSON. Responses are decoded into result,
+// which may be a *string for plain text responses or nil to ignore the response.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, result interface{}) error {
+	_, err := c.send(ctx, method, path, query, body, result)
+	return err
+}
+
+// Like do, also returning the headers of the response. They're empty if the request couldn't be sent.
+func (c *Client) send(ctx context.Context, method string, path string, query url.Values, body interface{}, result interface{}) (http.Header, error) {
+	address := c.BaseURL + path
+	if len(query) > 0 {
+		address += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return http.Header{}, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, address, reader)
+	if err != nil {
+		return http.Header{}, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+		if method == "PATCH" {
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Requested-With", "MeetupRest")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if etag, ok := ctx.Value(ifMatchKey{}).(string); ok && method != "GET" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return http.Header{}, err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return res.Header, err
+	}
+
+	if res.StatusCode >= 400 {
+		apiError := &Error{StatusCode: res.StatusCode}
+		err = json.Unmarshal(data, &apiError.ErrorResponse)
+		if err != nil {
+			apiError.Message = strings.TrimSpace(string(data))
+		}
+		return res.Header, apiError
+	}
+
+	switch result := result.(type) {
+	case nil:
+		return res.Header, nil
+	case *string:
+		*result = string(data)
+		return res.Header, nil
+	default:
+		return res.Header, json.Unmarshal(data, result)
+	}
+}
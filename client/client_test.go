@@ -0,0 +1,178 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cube2222/MeetupRest"
+	"github.com/cube2222/MeetupRest/api"
+	"golang.org/x/net/context"
+)
+
+func newTestServer(t *testing.T) (*MeetupRest.MemoryStore, *httptest.Server) {
+	store := MeetupRest.NewMemoryStore()
+	handler, err := MeetupRest.NewHandler(store, MeetupRest.NewMemorySearchIndex(), MeetupRest.NewMemoryRateLimiter(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, httptest.NewServer(handler)
+}
+
+func TestGetSpeakersAndPresentations(t *testing.T) {
+	store, server := newTestServer(t)
+	defer server.Close()
+	ctx := context.Background()
+
+	speakerID, _ := store.AddSpeaker(ctx, &MeetupRest.Speaker{Name: "Jan", Surname: "Kowalski", Email: "jan@example.com"})
+	presentationID, _ := store.AddPresentation(ctx, &MeetupRest.Presentation{Title: "Go", Speakers: []string{"Jan Kowalski"}, Voters: []string{"a@example.com"}})
+
+	c := New(server.URL, "")
+
+	speakers, err := c.ListSpeakers(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(speakers) != 1 || speakers[0].Key != speakerID || speakers[0].Surname != "Kowalski" {
+		t.Errorf("Expected the stored speaker. Received: %+v", speakers)
+	}
+
+	presentation, err := c.GetPresentation(ctx, presentationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presentation.Title != "Go" || presentation.Votes != 1 || len(presentation.Speakers) != 1 || presentation.Speakers[0].Key != speakerID {
+		t.Errorf("Expected the stored presentation. Received: %+v", presentation)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, server := newTestServer(t)
+	defer server.Close()
+	ctx := context.Background()
+	c := New(server.URL, "")
+
+	_, err := c.GetMeetup(ctx, 42)
+	if !IsNotFound(err) {
+		t.Errorf("Expected a not found error. Received: %v", err)
+	}
+	if apiError, ok := err.(*Error); !ok || apiError.Code != "not_found" || apiError.RequestID == "" {
+		t.Errorf("Expected the error envelope. Received: %#v", err)
+	}
+
+	_, err = c.CreateSpeaker(ctx, MeetupRest.SpeakerForm{Name: "Jan"})
+	if !IsStatus(err, http.StatusUnauthorized) {
+		t.Errorf("Anonymous users shouldn't be able to create speakers. Received: %v", err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL, "secret").ListMeetups(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer secret" {
+		t.Errorf("Expected the token in the Authorization header. Received: %v", authorization)
+	}
+}
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   string
+}
+
+// Server answering every request with the response, recording the requests.
+func newRecordingServer(response string, requests *[]recordedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*requests = append(*requests, recordedRequest{Method: r.Method, Path: r.URL.Path, Header: r.Header, Body: string(body)})
+		w.Write([]byte(response))
+	}))
+}
+
+func TestUpvote(t *testing.T) {
+	requests := make([]recordedRequest, 0)
+	server := newRecordingServer("", &requests)
+	defer server.Close()
+	c := New(server.URL, "secret")
+
+	err := c.Upvote(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Downvote(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[0].Method != "PUT" || requests[1].Method != "DELETE" || requests[0].Path != "/api/v1/presentations/7/vote" {
+		t.Errorf("Expected the vote to be put and deleted. Received: %+v", requests)
+	}
+	if requests[0].Header.Get("X-Requested-With") == "" {
+		t.Error("Votes should be sent with the X-Requested-With header.")
+	}
+}
+
+func TestCreateMeetup(t *testing.T) {
+	requests := make([]recordedRequest, 0)
+	server := newRecordingServer("42\n", &requests)
+	defer server.Close()
+	c := New(server.URL, "secret")
+
+	ID, err := c.CreateMeetup(context.Background(), api.MeetupForm{Title: "Go", VotingMode: api.VotingRanked})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ID != 42 {
+		t.Errorf("Expected the key of the created meetup. Received: %v", ID)
+	}
+	if len(requests) != 1 || requests[0].Method != "POST" || requests[0].Path != "/api/v1/meetups" || requests[0].Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected the form posted as JSON. Received: %+v", requests)
+	}
+	form := api.MeetupForm{}
+	err = json.Unmarshal([]byte(requests[0].Body), &form)
+	if err != nil || form.Title != "Go" || form.VotingMode != api.VotingRanked {
+		t.Errorf("Expected the form in the body. Received: %v", requests[0].Body)
+	}
+}
+
+func TestETags(t *testing.T) {
+	store, server := newTestServer(t)
+	defer server.Close()
+	ctx := context.Background()
+	ID, _ := store.AddSpeaker(ctx, &MeetupRest.Speaker{Name: "Jan", Surname: "Kowalski"})
+
+	_, etag, err := New(server.URL, "").GetSpeakerWithETag(ctx, ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etag == "" {
+		t.Error("Expected the ETag of the speaker.")
+	}
+
+	requests := make([]recordedRequest, 0)
+	recorder := newRecordingServer("", &requests)
+	defer recorder.Close()
+	c := New(recorder.URL, "secret")
+	err = c.UpdateSpeaker(IfMatch(ctx, etag), ID, api.SpeakerForm{Name: "Jan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.UpdateSpeaker(ctx, ID, api.SpeakerForm{Name: "Jan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests[0].Header.Get("If-Match") != etag || requests[1].Header.Get("If-Match") != "" {
+		t.Errorf("Expected If-Match only on the first update. Received: %+v", requests)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cube2222/MeetupRest/client"
+	"golang.org/x/net/context"
+)
+
+func runExport(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write to, standard output by default")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	archive, err := c.Export(ctx)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(archive)
+}
+
+func runImport(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	conflict := fs.String("conflict", "fail", "what to do with entities which already exist: fail, skip or overwrite")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: meetupctl import [-conflict fail|skip|overwrite] <file>")
+	}
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := json.RawMessage{}
+	err = json.NewDecoder(file).Decode(&archive)
+	if err != nil {
+		return err
+	}
+
+	result, err := c.Import(ctx, archive, *conflict)
+	if apiError, ok := err.(*client.Error); ok && apiError.Details != nil {
+		printJSON(apiError.Details)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %v, overwrote %v and skipped %v entities.\n", result.Created, result.Overwritten, result.Skipped)
+	return nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/cube2222/MeetupRest/client"
+	"golang.org/x/net/context"
+)
+
+const (
+	textField = iota
+	dateField
+	idsField
+	numberField
+)
+
+// A form field settable with a flag when creating or updating an entity.
+type field struct {
+	Flag  string
+	Name  string
+	Kind  int
+	Usage string
+}
+
+// The operations of an entity kind, forms are passed around as JSON merge patch documents.
+type entity struct {
+	Name   string
+	Fields []field
+	List   func(ctx context.Context) (interface{}, error)
+	Get    func(ctx context.Context, ID int64) (interface{}, error)
+	Create func(ctx context.Context, form map[string]interface{}) (int64, error)
+	Patch  func(ctx context.Context, ID int64, patch map[string]interface{}) error
+	Delete func(ctx context.Context, ID int64, cascade bool) error
+	// Extra subcommands, like listing the votes of a presentation.
+	Extra map[string]func(ctx context.Context, ID int64) (interface{}, error)
+}
+
+func speakers(c *client.Client) entity {
+	return entity{
+		Name: "speakers",
+		Fields: []field{
+			{"name", "Name", textField, "first name"},
+			{"surname", "Surname", textField, "last name"},
+			{"email", "Email", textField, "email address"},
+			{"company", "Company", textField, "company"},
+			{"about", "About", textField, "description of the speaker"},
+		},
+		List: func(ctx context.Context) (interface{}, error) { return c.ListSpeakers(ctx) },
+		Get:  func(ctx context.Context, ID int64) (interface{}, error) { return c.GetSpeaker(ctx, ID) },
+		Create: func(ctx context.Context, form map[string]interface{}) (int64, error) {
+			speaker := api.SpeakerForm{}
+			err := convert(form, &speaker)
+			if err != nil {
+				return 0, err
+			}
+			return c.CreateSpeaker(ctx, speaker)
+		},
+		Patch:  c.PatchSpeaker,
+		Delete: c.DeleteSpeaker,
+	}
+}
+
+func presentations(c *client.Client) entity {
+	return entity{
+		Name: "presentations",
+		Fields: []field{
+			{"title", "Title", textField, "title"},
+			{"description", "Description", textField, "description"},
+			{"speakers", "Speakers", textField, "comma separated full names of the speakers"},
+			{"meetup", "MeetupID", numberField, "key of the meetup to submit to, its call for papers has to be open"},
+		},
+		List: func(ctx context.Context) (interface{}, error) { return c.ListPresentations(ctx) },
+		Get:  func(ctx context.Context, ID int64) (interface{}, error) { return c.GetPresentation(ctx, ID) },
+		Create: func(ctx context.Context, form map[string]interface{}) (int64, error) {
+			presentation := api.PresentationForm{}
+			err := convert(form, &presentation)
+			if err != nil {
+				return 0, err
+			}
+			return c.CreatePresentation(ctx, presentation)
+		},
+		Patch:  c.PatchPresentation,
+		Delete: c.DeletePresentation,
+		Extra: map[string]func(ctx context.Context, ID int64) (interface{}, error){
+			"votes": func(ctx context.Context, ID int64) (interface{}, error) { return c.ListVotes(ctx, ID) },
+		},
+	}
+}
+
+func meetups(c *client.Client) entity {
+	return entity{
+		Name: "meetups",
+		Fields: []field{
+			{"title", "Title", textField, "title"},
+			{"description", "Description", textField, "description"},
+			{"date", "Date", dateField, "date of the meetup, in RFC 3339 format"},
+			{"vote-end", "VoteTimeEnd", dateField, "end of the voting, in RFC 3339 format"},
+			{"presentations", "Presentations", idsField, "comma separated keys of the presentations"},
+			{"lat", "Latitude", numberField, "latitude of the venue"},
+			{"lon", "Longitude", numberField, "longitude of the venue"},
+		},
+		List: func(ctx context.Context) (interface{}, error) { return c.ListMeetups(ctx) },
+		Get:  func(ctx context.Context, ID int64) (interface{}, error) { return c.GetMeetup(ctx, ID) },
+		Create: func(ctx context.Context, form map[string]interface{}) (int64, error) {
+			meetup := api.MeetupForm{}
+			err := convert(form, &meetup)
+			if err != nil {
+				return 0, err
+			}
+			return c.CreateMeetup(ctx, meetup)
+		},
+		Patch: c.PatchMeetup,
+		Delete: func(ctx context.Context, ID int64, cascade bool) error {
+			return c.DeleteMeetup(ctx, ID)
+		},
+	}
+}
+
+func runEntity(ctx context.Context, e entity, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: meetupctl %v list|get|create|update|delete", e.Name)
+	}
+	subcommand, args := args[0], args[1:]
+
+	switch subcommand {
+	case "list":
+		items, err := e.List(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(items)
+
+	case "get":
+		ID, err := parseID(args)
+		if err != nil {
+			return err
+		}
+		item, err := e.Get(ctx, ID)
+		if err != nil {
+			return err
+		}
+		return printJSON(item)
+
+	case "create":
+		form, err := parseFields(e, "create", args)
+		if err != nil {
+			return err
+		}
+		ID, err := e.Create(ctx, form)
+		if err != nil {
+			return err
+		}
+		fmt.Println(ID)
+		return nil
+
+	case "update":
+		ID, err := parseID(args)
+		if err != nil {
+			return err
+		}
+		patch, err := parseFields(e, "update", args[1:])
+		if err != nil {
+			return err
+		}
+		if len(patch) == 0 {
+			return fmt.Errorf("nothing to update, set at least one flag")
+		}
+		return e.Patch(ctx, ID, patch)
+
+	case "delete":
+		fs := flag.NewFlagSet(e.Name+" delete", flag.ContinueOnError)
+		cascade := fs.Bool("cascade", false, "also remove the references to the entity (admin only)")
+		ID, err := parseID(args)
+		if err != nil {
+			return err
+		}
+		err = fs.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+		return e.Delete(ctx, ID, *cascade)
+	}
+
+	if extra, ok := e.Extra[subcommand]; ok {
+		ID, err := parseID(args)
+		if err != nil {
+			return err
+		}
+		result, err := extra(ctx, ID)
+		if err != nil {
+			return err
+		}
+		return printJSON(result)
+	}
+
+	return fmt.Errorf("unknown %v subcommand: %v", e.Name, subcommand)
+}
+
+// Parse the field flags into a form. Only the flags which were set end up in it.
+func parseFields(e entity, subcommand string, args []string) (map[string]interface{}, error) {
+	fs := flag.NewFlagSet(e.Name+" "+subcommand, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	values := make(map[string]*string)
+	for _, f := range e.Fields {
+		values[f.Flag] = fs.String(f.Flag, "", f.Usage)
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	form := make(map[string]interface{})
+	var parseErr error
+	fs.Visit(func(set *flag.Flag) {
+		for _, f := range e.Fields {
+			if f.Flag != set.Name || parseErr != nil {
+				continue
+			}
+			value := *values[f.Flag]
+			switch f.Kind {
+			case textField:
+				form[f.Name] = value
+			case dateField:
+				date, err := time.Parse(time.RFC3339, value)
+				parseErr = err
+				form[f.Name] = date
+			case idsField:
+				IDs, err := parseIDList(value)
+				parseErr = err
+				form[f.Name] = IDs
+			case numberField:
+				number, err := strconv.ParseFloat(value, 64)
+				parseErr = err
+				form[f.Name] = number
+			}
+		}
+	})
+	return form, parseErr
+}
+
+// Convert the form into one of the form types of the API.
+func convert(form map[string]interface{}, target interface{}) error {
+	data, err := json.Marshal(form)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
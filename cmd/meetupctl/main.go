@@ -0,0 +1,248 @@
+// Command meetupctl manages a MeetupRest instance through its REST API.
+//
+// Usage:
+//
+//	meetupctl [-url address] [-token token] <command> [arguments]
+//
+// The address and the OAuth2 access token default to the MEETUPCTL_URL and MEETUPCTL_TOKEN environment variables.
+// Run meetupctl without a command to list the commands.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/cube2222/MeetupRest/client"
+	"golang.org/x/net/context"
+)
+
+const usage = `Usage: meetupctl [-url address] [-token token] <command> [arguments]
+
+Commands:
+  speakers list|get|create|update|delete
+  presentations list|get|create|update|delete|votes
+  meetups list|get|create|update|delete
+  agenda get <meetup>
+  agenda auto [-minutes n] [-break-every n] <meetup>
+  metadata get <key>
+  metadata set <key> <value>
+  sync
+  search [-kind kind] [-limit n] <words>
+  reindex
+  anonymize-votes
+  suspicious-votes [-hours n]
+  invalidate-votes [-block] <voter>...
+  blocked-voters
+  unblock-voter <voter>
+  export [-o file]
+  import [-conflict fail|skip|overwrite] <file>
+
+Run a command with -h to see its flags.
+`
+
+func main() {
+	address := flag.String("url", os.Getenv("MEETUPCTL_URL"), "address of the MeetupRest instance")
+	token := flag.String("token", os.Getenv("MEETUPCTL_TOKEN"), "OAuth2 access token with the userinfo.email scope")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 || *address == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := client.New(*address, *token)
+	err := run(context.Background(), c, flag.Arg(0), flag.Args()[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, c *client.Client, command string, args []string) error {
+	switch command {
+	case "speakers":
+		return runEntity(ctx, speakers(c), args)
+	case "presentations":
+		return runEntity(ctx, presentations(c), args)
+	case "meetups":
+		return runEntity(ctx, meetups(c), args)
+	case "agenda":
+		return runAgenda(ctx, c, args)
+	case "metadata":
+		return runMetadata(ctx, c, args)
+	case "sync":
+		return c.Sync(ctx)
+	case "search":
+		return runSearch(ctx, c, args)
+	case "reindex":
+		return c.Reindex(ctx)
+	case "anonymize-votes":
+		response, err := c.AnonymizeVotes(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(response)
+		return nil
+	case "suspicious-votes":
+		return runSuspiciousVotes(ctx, c, args)
+	case "invalidate-votes":
+		return runInvalidateVotes(ctx, c, args)
+	case "blocked-voters":
+		voters, err := c.BlockedVoters(ctx)
+		if err != nil {
+			return err
+		}
+		return printJSON(voters)
+	case "unblock-voter":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: meetupctl unblock-voter <voter>")
+		}
+		return c.UnblockVoter(ctx, args[0])
+	case "export":
+		return runExport(ctx, c, args)
+	case "import":
+		return runImport(ctx, c, args)
+	}
+	return fmt.Errorf("unknown command: %v", command)
+}
+
+func runMetadata(ctx context.Context, c *client.Client, args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "get":
+		value, err := c.GetMetadata(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	case len(args) == 3 && args[0] == "set":
+		return c.SetMetadata(ctx, args[1], args[2])
+	}
+	return fmt.Errorf("usage: meetupctl metadata get <key> | metadata set <key> <value>")
+}
+
+func runAgenda(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) == 0 || (args[0] != "get" && args[0] != "auto") {
+		return fmt.Errorf("usage: meetupctl agenda get <meetup> | agenda auto [-minutes n] [-break-every n] <meetup>")
+	}
+
+	fs := flag.NewFlagSet("agenda "+args[0], flag.ContinueOnError)
+	minutes := fs.Int("minutes", 0, "length of every presentation slot")
+	breakEvery := fs.Int("break-every", 0, "add a break after this many presentations")
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	ID, err := parseID(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "auto" {
+		agenda, err := c.AutoSchedule(ctx, ID, *minutes, *breakEvery)
+		if err != nil {
+			return err
+		}
+		return printJSON(agenda)
+	}
+	agenda, err := c.GetAgenda(ctx, ID)
+	if err != nil {
+		return err
+	}
+	return printJSON(agenda)
+}
+
+func runSearch(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	kind := fs.String("kind", "", "only return results of this kind: speaker, presentation or meetup")
+	limit := fs.Int("limit", 0, "maximum number of results")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: meetupctl search [-kind kind] [-limit n] <words>")
+	}
+
+	results, err := c.Search(ctx, strings.Join(fs.Args(), " "), *kind, *limit)
+	if err != nil {
+		return err
+	}
+	return printJSON(results)
+}
+
+func runSuspiciousVotes(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("suspicious-votes", flag.ContinueOnError)
+	hours := fs.Int("hours", 0, "report the votes of this many last hours")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	report, err := c.SuspiciousVotes(ctx, *hours)
+	if err != nil {
+		return err
+	}
+	return printJSON(report)
+}
+
+func runInvalidateVotes(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("invalidate-votes", flag.ContinueOnError)
+	block := fs.Bool("block", false, "also keep the voters from voting again")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: meetupctl invalidate-votes [-block] <voter>...")
+	}
+
+	response, err := c.InvalidateVotes(ctx, api.InvalidateVotesForm{Voters: fs.Args(), Block: *block})
+	if err != nil {
+		return err
+	}
+	fmt.Println(response)
+	return nil
+}
+
+func parseID(args []string) (int64, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("missing ID")
+	}
+	ID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("ID not valid: %v", args[0])
+	}
+	return ID, nil
+}
+
+func printJSON(value interface{}) error {
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(value)
+}
+
+func parseIDList(list string) ([]int64, error) {
+	IDs := make([]int64, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		ID, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ID not valid: %v", item)
+		}
+		IDs = append(IDs, ID)
+	}
+	return IDs, nil
+}
@@ -5,6 +5,7 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/appengine/datastore"
 	"strings"
+	"time"
 )
 
 type GoogleDatastoreStore struct {
@@ -14,31 +15,70 @@ type data struct {
 	Content string
 }
 
+type transactionContextKey struct{}
+
+// Run f in a cross-group transaction. If ctx already belongs to a transaction, f just joins it,
+// as the datastore doesn't support nested transactions.
+func (ds *GoogleDatastoreStore) RunInTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if ctx.Value(transactionContextKey{}) != nil {
+		return f(ctx)
+	}
+	return datastore.RunInTransaction(ctx, func(ctx context.Context) error {
+		return f(context.WithValue(ctx, transactionContextKey{}, true))
+	}, &datastore.TransactionOptions{XG: true})
+}
+
 func (ds *GoogleDatastoreStore) GetSpeaker(ctx context.Context, ID int64) (Speaker, error) {
 	speaker := Speaker{}
 	key := datastore.NewKey(ctx, datastoreSpeakersKind, "", ID, nil)
 	err := datastore.Get(ctx, key, &speaker)
+	if err == nil && speaker.Deleted {
+		return Speaker{}, datastore.ErrNoSuchEntity
+	}
 	return speaker, err
 }
 
 func (ds *GoogleDatastoreStore) GetSpeakerIdByName(ctx context.Context, name string) (int64, error) {
-	speaker := Speaker{}
 	nameParts := strings.Split(name, " ")
 	if len(nameParts) < 2 {
 		return 0, errors.New("Wrong name provided.")
 	}
-	it := datastore.NewQuery(datastoreSpeakersKind).Filter("Name=", nameParts[0]).Filter("Surname=", nameParts[1]).Limit(1).Run(ctx)
-	key, err := it.Next(&speaker)
-	if err != nil {
-		return 0, err
+	it := datastore.NewQuery(datastoreSpeakersKind).Filter("Name=", nameParts[0]).Filter("Surname=", nameParts[1]).Run(ctx)
+	for {
+		speaker := Speaker{}
+		key, err := it.Next(&speaker)
+		if err == datastore.Done {
+			return 0, datastore.ErrNoSuchEntity
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !speaker.Deleted {
+			return key.IntID(), nil
+		}
 	}
-
-	return key.IntID(), nil
 }
 
 func (ds *GoogleDatastoreStore) GetAllSpeakers(ctx context.Context) ([]int64, []Speaker, error) {
+	all := make([]Speaker, 0, 10)
+	keys, err := datastore.NewQuery(datastoreSpeakersKind).GetAll(ctx, &all)
+
+	IDs := make([]int64, 0, len(all))
+	speakers := make([]Speaker, 0, len(all))
+	for index, key := range keys {
+		if all[index].Deleted {
+			continue
+		}
+		IDs = append(IDs, key.IntID())
+		speakers = append(speakers, all[index])
+	}
+
+	return IDs, speakers, err
+}
+
+func (ds *GoogleDatastoreStore) GetDeletedSpeakers(ctx context.Context) ([]int64, []Speaker, error) {
 	speakers := make([]Speaker, 0, 10)
-	keys, err := datastore.NewQuery(datastoreSpeakersKind).GetAll(ctx, &speakers)
+	keys, err := datastore.NewQuery(datastoreSpeakersKind).Filter("Deleted=", true).GetAll(ctx, &speakers)
 
 	IDs := make([]int64, 0, len(speakers))
 	for _, key := range keys {
@@ -48,19 +88,67 @@ func (ds *GoogleDatastoreStore) GetAllSpeakers(ctx context.Context) ([]int64, []
 	return IDs, speakers, err
 }
 
+// Put the speaker, failing with ErrVersionConflict if it was modified since it has been read.
 func (ds *GoogleDatastoreStore) PutSpeaker(ctx context.Context, ID int64, speaker *Speaker) error {
 	key := datastore.NewKey(ctx, datastoreSpeakersKind, "", ID, nil)
-	_, err := datastore.Put(ctx, key, speaker)
-	return err
+	version := speaker.Version
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		stored := Speaker{}
+		err := datastore.Get(ctx, key, &stored)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if err == nil && stored.Version != version {
+			return ErrVersionConflict
+		}
+
+		speaker.Version = version + 1
+		_, err = datastore.Put(ctx, key, speaker)
+		return err
+	})
 }
 
 func (ds *GoogleDatastoreStore) AddSpeaker(ctx context.Context, speaker *Speaker) (int64, error) {
 	key := datastore.NewKey(ctx, datastoreSpeakersKind, "", 0, nil)
+	speaker.Version = 1
 	ID, err := datastore.Put(ctx, key, speaker)
 	return ID.IntID(), err
 }
 
+// Move the speaker to the trash. It stays in the datastore until it's purged.
 func (ds *GoogleDatastoreStore) DeleteSpeaker(ctx context.Context, ID int64) error {
+	return ds.setSpeakerDeleted(ctx, ID, true)
+}
+
+func (ds *GoogleDatastoreStore) UndeleteSpeaker(ctx context.Context, ID int64) error {
+	return ds.setSpeakerDeleted(ctx, ID, false)
+}
+
+func (ds *GoogleDatastoreStore) setSpeakerDeleted(ctx context.Context, ID int64, deleted bool) error {
+	key := datastore.NewKey(ctx, datastoreSpeakersKind, "", ID, nil)
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		speaker := Speaker{}
+		err := datastore.Get(ctx, key, &speaker)
+		if err != nil {
+			return err
+		}
+		if speaker.Deleted == deleted {
+			return datastore.ErrNoSuchEntity
+		}
+
+		speaker.Deleted = deleted
+		speaker.Version++
+		speaker.DeletedAt = time.Time{}
+		if deleted {
+			speaker.DeletedAt = time.Now()
+		}
+		_, err = datastore.Put(ctx, key, &speaker)
+		return err
+	})
+}
+
+// Remove the speaker from the datastore for good.
+func (ds *GoogleDatastoreStore) PurgeSpeaker(ctx context.Context, ID int64) error {
 	key := datastore.NewKey(ctx, datastoreSpeakersKind, "", ID, nil)
 	return datastore.Delete(ctx, key)
 }
@@ -69,12 +157,32 @@ func (ds *GoogleDatastoreStore) GetPresentation(ctx context.Context, ID int64) (
 	presentation := Presentation{}
 	key := datastore.NewKey(ctx, datastorePresentationsKind, "", ID, nil)
 	err := datastore.Get(ctx, key, &presentation)
+	if err == nil && presentation.Deleted {
+		return Presentation{}, datastore.ErrNoSuchEntity
+	}
 	return presentation, err
 }
 
 func (ds *GoogleDatastoreStore) GetAllPresentations(ctx context.Context) ([]int64, []Presentation, error) {
+	all := make([]Presentation, 0, 10)
+	keys, err := datastore.NewQuery(datastorePresentationsKind).GetAll(ctx, &all)
+
+	IDs := make([]int64, 0, len(all))
+	presentations := make([]Presentation, 0, len(all))
+	for index, key := range keys {
+		if all[index].Deleted {
+			continue
+		}
+		IDs = append(IDs, key.IntID())
+		presentations = append(presentations, all[index])
+	}
+
+	return IDs, presentations, err
+}
+
+func (ds *GoogleDatastoreStore) GetDeletedPresentations(ctx context.Context) ([]int64, []Presentation, error) {
 	presentations := make([]Presentation, 0, 10)
-	keys, err := datastore.NewQuery(datastorePresentationsKind).GetAll(ctx, &presentations)
+	keys, err := datastore.NewQuery(datastorePresentationsKind).Filter("Deleted=", true).GetAll(ctx, &presentations)
 
 	IDs := make([]int64, 0, len(presentations))
 	for _, key := range keys {
@@ -84,33 +192,216 @@ func (ds *GoogleDatastoreStore) GetAllPresentations(ctx context.Context) ([]int6
 	return IDs, presentations, err
 }
 
+// Put the presentation, keeping the previous content as a revision if it changed.
+// Fails with ErrVersionConflict if the presentation was modified since it has been read.
 func (ds *GoogleDatastoreStore) PutPresentation(ctx context.Context, ID int64, presentation *Presentation) error {
 	key := datastore.NewKey(ctx, datastorePresentationsKind, "", ID, nil)
-	_, err := datastore.Put(ctx, key, presentation)
-	return err
+	version := presentation.Version
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		previous := Presentation{}
+		err := datastore.Get(ctx, key, &previous)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if err == nil && previous.Version != version {
+			return ErrVersionConflict
+		}
+
+		if err == nil {
+			revision := previous.NewRevision(time.Now())
+			if revision.Differs(presentation) {
+				revisionKey := datastore.NewIncompleteKey(ctx, datastorePresentationRevisionsKind, key)
+				_, err = datastore.Put(ctx, revisionKey, &revision)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		presentation.Version = version + 1
+		_, err = datastore.Put(ctx, key, presentation)
+		return err
+	})
 }
 
 func (ds *GoogleDatastoreStore) AddPresentation(ctx context.Context, presentation *Presentation) (int64, error) {
 	key := datastore.NewKey(ctx, datastorePresentationsKind, "", 0, nil)
+	presentation.Version = 1
 	ID, err := datastore.Put(ctx, key, presentation)
 	return ID.IntID(), err
 }
 
+// Move the presentation to the trash. It stays in the datastore until it's purged.
 func (ds *GoogleDatastoreStore) DeletePresentation(ctx context.Context, ID int64) error {
+	return ds.setPresentationDeleted(ctx, ID, true)
+}
+
+func (ds *GoogleDatastoreStore) UndeletePresentation(ctx context.Context, ID int64) error {
+	return ds.setPresentationDeleted(ctx, ID, false)
+}
+
+func (ds *GoogleDatastoreStore) setPresentationDeleted(ctx context.Context, ID int64, deleted bool) error {
 	key := datastore.NewKey(ctx, datastorePresentationsKind, "", ID, nil)
-	return datastore.Delete(ctx, key)
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		presentation := Presentation{}
+		err := datastore.Get(ctx, key, &presentation)
+		if err != nil {
+			return err
+		}
+		if presentation.Deleted == deleted {
+			return datastore.ErrNoSuchEntity
+		}
+
+		presentation.Deleted = deleted
+		presentation.Version++
+		presentation.DeletedAt = time.Time{}
+		if deleted {
+			presentation.DeletedAt = time.Now()
+		}
+		_, err = datastore.Put(ctx, key, &presentation)
+		return err
+	})
+}
+
+// Remove the presentation from the datastore for good.
+func (ds *GoogleDatastoreStore) PurgePresentation(ctx context.Context, ID int64) error {
+	key := datastore.NewKey(ctx, datastorePresentationsKind, "", ID, nil)
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		revisionKeys, err := datastore.NewQuery(datastorePresentationRevisionsKind).Ancestor(key).KeysOnly().GetAll(ctx, nil)
+		if err != nil {
+			return err
+		}
+		reviewKeys, err := datastore.NewQuery(datastoreReviewsKind).Ancestor(key).KeysOnly().GetAll(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return datastore.DeleteMulti(ctx, append(append(revisionKeys, reviewKeys...), key))
+	})
+}
+
+func (ds *GoogleDatastoreStore) GetPresentationRevisions(ctx context.Context, presentationID int64) ([]int64, []PresentationRevision, error) {
+	revisions := make([]PresentationRevision, 0, 10)
+	presentationKey := datastore.NewKey(ctx, datastorePresentationsKind, "", presentationID, nil)
+	keys, err := datastore.NewQuery(datastorePresentationRevisionsKind).Ancestor(presentationKey).GetAll(ctx, &revisions)
+
+	IDs := make([]int64, 0, len(revisions))
+	for _, key := range keys {
+		IDs = append(IDs, key.IntID())
+	}
+
+	return IDs, revisions, err
+}
+
+func (ds *GoogleDatastoreStore) GetPresentationRevision(ctx context.Context, presentationID int64, ID int64) (PresentationRevision, error) {
+	revision := PresentationRevision{}
+	presentationKey := datastore.NewKey(ctx, datastorePresentationsKind, "", presentationID, nil)
+	key := datastore.NewKey(ctx, datastorePresentationRevisionsKind, "", ID, presentationKey)
+	err := datastore.Get(ctx, key, &revision)
+	return revision, err
+}
+
+// Revisions keep their ID when imported, so it's reserved first, like the IDs of the presentations.
+func (ds *GoogleDatastoreStore) PutPresentationRevision(ctx context.Context, presentationID int64, ID int64, revision *PresentationRevision) error {
+	presentationKey := datastore.NewKey(ctx, datastorePresentationsKind, "", presentationID, nil)
+	err := allocateUpTo(ctx, datastorePresentationRevisionsKind, presentationKey, ID)
+	if err != nil {
+		return err
+	}
+	key := datastore.NewKey(ctx, datastorePresentationRevisionsKind, "", ID, presentationKey)
+	_, err = datastore.Put(ctx, key, revision)
+	return err
+}
+
+// Reserve the IDs so the automatic allocation never hands them out again.
+func (ds *GoogleDatastoreStore) ReserveIDs(ctx context.Context, kind string, IDs []int64) error {
+	max := int64(0)
+	for _, ID := range IDs {
+		if ID > max {
+			max = ID
+		}
+	}
+	if max == 0 {
+		return nil
+	}
+	return allocateUpTo(ctx, kind, nil, max)
+}
+
+// The allocation request only takes the end of the range, so this reserves every ID up to max. The
+// entity usually exists already or the range was reserved before, which is fine here.
+func allocateUpTo(ctx context.Context, kind string, parent *datastore.Key, max int64) error {
+	err := datastore.AllocateIDRange(ctx, kind, parent, max, max)
+	switch err.(type) {
+	case *datastore.KeyRangeCollisionError, *datastore.KeyRangeContentionError:
+		return nil
+	}
+	return err
+}
+
+// Reviews are children of the presentation, keyed by the email of the reviewer.
+func (ds *GoogleDatastoreStore) GetReview(ctx context.Context, presentationID int64, reviewer string) (Review, error) {
+	review := Review{}
+	presentationKey := datastore.NewKey(ctx, datastorePresentationsKind, "", presentationID, nil)
+	key := datastore.NewKey(ctx, datastoreReviewsKind, reviewer, 0, presentationKey)
+	err := datastore.Get(ctx, key, &review)
+	return review, err
+}
+
+func (ds *GoogleDatastoreStore) GetReviews(ctx context.Context, presentationID int64) ([]Review, error) {
+	reviews := make([]Review, 0, 10)
+	presentationKey := datastore.NewKey(ctx, datastorePresentationsKind, "", presentationID, nil)
+	_, err := datastore.NewQuery(datastoreReviewsKind).Ancestor(presentationKey).GetAll(ctx, &reviews)
+	return reviews, err
+}
+
+func (ds *GoogleDatastoreStore) GetAllReviews(ctx context.Context) ([]int64, []Review, error) {
+	reviews := make([]Review, 0, 10)
+	keys, err := datastore.NewQuery(datastoreReviewsKind).GetAll(ctx, &reviews)
+
+	presentationIDs := make([]int64, 0, len(reviews))
+	for _, key := range keys {
+		presentationIDs = append(presentationIDs, key.Parent().IntID())
+	}
+
+	return presentationIDs, reviews, err
+}
+
+func (ds *GoogleDatastoreStore) PutReview(ctx context.Context, presentationID int64, review *Review) error {
+	presentationKey := datastore.NewKey(ctx, datastorePresentationsKind, "", presentationID, nil)
+	key := datastore.NewKey(ctx, datastoreReviewsKind, review.Reviewer, 0, presentationKey)
+	_, err := datastore.Put(ctx, key, review)
+	return err
 }
 
 func (ds *GoogleDatastoreStore) GetMeetup(ctx context.Context, ID int64) (Meetup, error) {
 	meetup := Meetup{}
 	key := datastore.NewKey(ctx, datastoreMeetupsKind, "", ID, nil)
 	err := datastore.Get(ctx, key, &meetup)
+	if err == nil && meetup.Deleted {
+		return Meetup{}, datastore.ErrNoSuchEntity
+	}
 	return meetup, err
 }
 
 func (ds *GoogleDatastoreStore) GetAllMeetups(ctx context.Context) ([]int64, []Meetup, error) {
+	all := make([]Meetup, 0, 10)
+	keys, err := datastore.NewQuery(datastoreMeetupsKind).GetAll(ctx, &all)
+
+	IDs := make([]int64, 0, len(all))
+	meetups := make([]Meetup, 0, len(all))
+	for index, key := range keys {
+		if all[index].Deleted {
+			continue
+		}
+		IDs = append(IDs, key.IntID())
+		meetups = append(meetups, all[index])
+	}
+
+	return IDs, meetups, err
+}
+
+func (ds *GoogleDatastoreStore) GetDeletedMeetups(ctx context.Context) ([]int64, []Meetup, error) {
 	meetups := make([]Meetup, 0, 10)
-	keys, err := datastore.NewQuery(datastoreMeetupsKind).GetAll(ctx, &meetups)
+	keys, err := datastore.NewQuery(datastoreMeetupsKind).Filter("Deleted=", true).GetAll(ctx, &meetups)
 
 	IDs := make([]int64, 0, len(meetups))
 	for _, key := range keys {
@@ -120,23 +411,175 @@ func (ds *GoogleDatastoreStore) GetAllMeetups(ctx context.Context) ([]int64, []M
 	return IDs, meetups, err
 }
 
+// Put the meetup, failing with ErrVersionConflict if it was modified since it has been read.
 func (ds *GoogleDatastoreStore) PutMeetup(ctx context.Context, ID int64, meetup *Meetup) error {
 	key := datastore.NewKey(ctx, datastoreMeetupsKind, "", ID, nil)
-	_, err := datastore.Put(ctx, key, meetup)
-	return err
+	version := meetup.Version
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		stored := Meetup{}
+		err := datastore.Get(ctx, key, &stored)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if err == nil && stored.Version != version {
+			return ErrVersionConflict
+		}
+
+		meetup.Version = version + 1
+		_, err = datastore.Put(ctx, key, meetup)
+		return err
+	})
 }
 
 func (ds *GoogleDatastoreStore) AddMeetup(ctx context.Context, meetup *Meetup) (int64, error) {
 	key := datastore.NewKey(ctx, datastoreMeetupsKind, "", 0, nil)
+	meetup.Version = 1
 	ID, err := datastore.Put(ctx, key, meetup)
 	return ID.IntID(), err
 }
 
+// Move the meetup to the trash. It stays in the datastore until it's purged.
 func (ds *GoogleDatastoreStore) DeleteMeetup(ctx context.Context, ID int64) error {
+	return ds.setMeetupDeleted(ctx, ID, true)
+}
+
+func (ds *GoogleDatastoreStore) UndeleteMeetup(ctx context.Context, ID int64) error {
+	return ds.setMeetupDeleted(ctx, ID, false)
+}
+
+func (ds *GoogleDatastoreStore) setMeetupDeleted(ctx context.Context, ID int64, deleted bool) error {
 	key := datastore.NewKey(ctx, datastoreMeetupsKind, "", ID, nil)
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		meetup := Meetup{}
+		err := datastore.Get(ctx, key, &meetup)
+		if err != nil {
+			return err
+		}
+		if meetup.Deleted == deleted {
+			return datastore.ErrNoSuchEntity
+		}
+
+		meetup.Deleted = deleted
+		meetup.Version++
+		meetup.DeletedAt = time.Time{}
+		if deleted {
+			meetup.DeletedAt = time.Now()
+		}
+		_, err = datastore.Put(ctx, key, &meetup)
+		return err
+	})
+}
+
+// Remove the meetup from the datastore for good.
+func (ds *GoogleDatastoreStore) PurgeMeetup(ctx context.Context, ID int64) error {
+	key := datastore.NewKey(ctx, datastoreMeetupsKind, "", ID, nil)
+	return ds.RunInTransaction(ctx, func(ctx context.Context) error {
+		ballotKeys, err := datastore.NewQuery(datastoreBallotsKind).Ancestor(key).KeysOnly().GetAll(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return datastore.DeleteMulti(ctx, append(ballotKeys, key))
+	})
+}
+
+// Ballots are children of the meetup, keyed by the ID of the voter, see voterID.
+func (ds *GoogleDatastoreStore) GetBallot(ctx context.Context, meetupID int64, voter string) (Ballot, error) {
+	ballot := Ballot{}
+	meetupKey := datastore.NewKey(ctx, datastoreMeetupsKind, "", meetupID, nil)
+	key := datastore.NewKey(ctx, datastoreBallotsKind, voter, 0, meetupKey)
+	err := datastore.Get(ctx, key, &ballot)
+	return ballot, err
+}
+
+func (ds *GoogleDatastoreStore) GetBallots(ctx context.Context, meetupID int64) ([]Ballot, error) {
+	ballots := make([]Ballot, 0, 10)
+	meetupKey := datastore.NewKey(ctx, datastoreMeetupsKind, "", meetupID, nil)
+	_, err := datastore.NewQuery(datastoreBallotsKind).Ancestor(meetupKey).GetAll(ctx, &ballots)
+	return ballots, err
+}
+
+func (ds *GoogleDatastoreStore) PutBallot(ctx context.Context, meetupID int64, ballot *Ballot) error {
+	meetupKey := datastore.NewKey(ctx, datastoreMeetupsKind, "", meetupID, nil)
+	key := datastore.NewKey(ctx, datastoreBallotsKind, ballot.Voter, 0, meetupKey)
+	_, err := datastore.Put(ctx, key, ballot)
+	return err
+}
+
+func (ds *GoogleDatastoreStore) DeleteBallot(ctx context.Context, meetupID int64, voter string) error {
+	meetupKey := datastore.NewKey(ctx, datastoreMeetupsKind, "", meetupID, nil)
+	key := datastore.NewKey(ctx, datastoreBallotsKind, voter, 0, meetupKey)
+	return datastore.Delete(ctx, key)
+}
+
+func (ds *GoogleDatastoreStore) AddVoteEvent(ctx context.Context, event *VoteEvent) error {
+	key := datastore.NewIncompleteKey(ctx, datastoreVoteEventsKind, nil)
+	_, err := datastore.Put(ctx, key, event)
+	return err
+}
+
+func (ds *GoogleDatastoreStore) GetVoteEvents(ctx context.Context, since time.Time) ([]VoteEvent, error) {
+	events := make([]VoteEvent, 0, 100)
+	_, err := datastore.NewQuery(datastoreVoteEventsKind).Filter("Time >=", since).Order("Time").GetAll(ctx, &events)
+	return events, err
+}
+
+// Deletes in batches, as many as a single DeleteMulti takes.
+func (ds *GoogleDatastoreStore) DeleteVoteEvents(ctx context.Context, before time.Time) (int, error) {
+	deleted := 0
+	for {
+		keys, err := datastore.NewQuery(datastoreVoteEventsKind).Filter("Time <", before).KeysOnly().Limit(500).GetAll(ctx, nil)
+		if err != nil || len(keys) == 0 {
+			return deleted, err
+		}
+		err = datastore.DeleteMulti(ctx, keys)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += len(keys)
+	}
+}
+
+// Blocked voters are keyed by their ID, see voterID.
+type blockedVoter struct {
+	Blocked time.Time
+}
+
+func (ds *GoogleDatastoreStore) BlockVoter(ctx context.Context, voter string) error {
+	key := datastore.NewKey(ctx, datastoreBlockedVotersKind, voter, 0, nil)
+	_, err := datastore.Put(ctx, key, &blockedVoter{Blocked: time.Now()})
+	return err
+}
+
+func (ds *GoogleDatastoreStore) UnblockVoter(ctx context.Context, voter string) error {
+	key := datastore.NewKey(ctx, datastoreBlockedVotersKind, voter, 0, nil)
+	err := datastore.Get(ctx, key, &blockedVoter{})
+	if err != nil {
+		return err
+	}
 	return datastore.Delete(ctx, key)
 }
 
+func (ds *GoogleDatastoreStore) IsVoterBlocked(ctx context.Context, voter string) (bool, error) {
+	key := datastore.NewKey(ctx, datastoreBlockedVotersKind, voter, 0, nil)
+	err := datastore.Get(ctx, key, &blockedVoter{})
+	if err == datastore.ErrNoSuchEntity {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (ds *GoogleDatastoreStore) GetBlockedVoters(ctx context.Context) ([]string, error) {
+	keys, err := datastore.NewQuery(datastoreBlockedVotersKind).KeysOnly().GetAll(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	voters := make([]string, 0, len(keys))
+	for _, key := range keys {
+		voters = append(voters, key.StringID())
+	}
+	return voters, nil
+}
+
 func (ds *GoogleDatastoreStore) GetData(ctx context.Context, key string) (string, error) {
 	data := data{}
 	keyInternal := datastore.NewKey(ctx, datastoreMetadataKind, key, 0, nil)
@@ -156,3 +599,17 @@ func (ds *GoogleDatastoreStore) DeleteData(ctx context.Context, key string) erro
 	err := datastore.Delete(ctx, keyInternal)
 	return err
 }
+
+func (ds *GoogleDatastoreStore) GetAllData(ctx context.Context) ([]string, []string, error) {
+	all := make([]data, 0, 10)
+	keys, err := datastore.NewQuery(datastoreMetadataKind).GetAll(ctx, &all)
+
+	dataKeys := make([]string, 0, len(all))
+	values := make([]string, 0, len(all))
+	for index, key := range keys {
+		dataKeys = append(dataKeys, key.StringID())
+		values = append(values, all[index].Content)
+	}
+
+	return dataKeys, values, err
+}
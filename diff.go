@@ -0,0 +1,78 @@
+package MeetupRest
+
+import (
+	"strings"
+)
+
+const (
+	diffEqual  = "="
+	diffInsert = "+"
+	diffDelete = "-"
+)
+
+type DiffLine struct {
+	Op   string
+	Text string
+}
+
+// Compute a line diff between a and b using the longest common subsequence.
+func diffLines(a, b []string) []DiffLine {
+	lcs := lcsTable(a, b)
+
+	diff := make([]DiffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, DiffLine{Op: diffEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: diffDelete, Text: a[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: diffInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, DiffLine{Op: diffDelete, Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, DiffLine{Op: diffInsert, Text: b[j]})
+	}
+
+	return diff
+}
+
+// Similarity of two texts measured on words, from 0 (nothing in common) to 1 (identical).
+func textSimilarity(a, b string) float64 {
+	wordsA := strings.Fields(strings.ToLower(a))
+	wordsB := strings.Fields(strings.ToLower(b))
+	if len(wordsA)+len(wordsB) == 0 {
+		return 1
+	}
+
+	common := lcsTable(wordsA, wordsB)[0][0]
+	return float64(2*common) / float64(len(wordsA)+len(wordsB))
+}
+
+// lcs[i][j] holds the length of the longest common subsequence of a[i:] and b[j:].
+func lcsTable(a, b []string) [][]int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs
+}
@@ -0,0 +1,377 @@
+package MeetupRest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encodes the public views into one representation.
+type Encoder interface {
+	// Media types the encoder produces. The first one is sent as the Content-Type.
+	MediaTypes() []string
+	Encode(w io.Writer, v interface{}) error
+}
+
+// Encoders handlers can respond with, in order of preference. JSON comes first, it's the default.
+var encoders = []Encoder{jsonEncoder{}, csvEncoder{}, yamlEncoder{}, htmlEncoder{}}
+
+// Make another representation available to the handlers.
+func RegisterEncoder(encoder Encoder) {
+	encoders = append(encoders, encoder)
+}
+
+// Pick the encoder for the Accept header of the request and set the Content-Type of the response.
+// Writes a 406 response and returns false if none of the encoders is acceptable.
+func negotiateEncoder(w http.ResponseWriter, r *http.Request) (Encoder, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	encoder := selectEncoder(r.Header.Get("Accept"))
+	if encoder == nil {
+		mediaTypes := make([]string, 0, len(encoders))
+		for _, encoder := range encoders {
+			mediaTypes = append(mediaTypes, encoder.MediaTypes()[0])
+		}
+		writeErrorResponse(w, http.StatusNotAcceptable, ErrorResponse{
+			Message: fmt.Sprintf("None of the accepted media types is available. Available: %v", strings.Join(mediaTypes, ", ")),
+			Details: mediaTypes,
+		})
+		return nil, false
+	}
+
+	contentType := encoder.MediaTypes()[0]
+	if strings.HasPrefix(contentType, "text/") {
+		contentType += "; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	return encoder, true
+}
+
+type acceptedRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Pick the encoder for the most preferred media range of the Accept header. Without the header, it's JSON.
+func selectEncoder(accept string) Encoder {
+	if strings.TrimSpace(accept) == "" {
+		return encoders[0]
+	}
+
+	ranges := make([]acceptedRange, 0)
+	for _, item := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, acceptedRange{mediaType: mediaType, quality: quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+
+	for _, accepted := range ranges {
+		for _, encoder := range encoders {
+			for _, mediaType := range encoder.MediaTypes() {
+				if matchesMediaRange(accepted.mediaType, mediaType) {
+					return encoder
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesMediaRange(mediaRange string, mediaType string) bool {
+	if mediaRange == "*/*" || mediaRange == mediaType {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) MediaTypes() []string {
+	return []string{"application/json"}
+}
+
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// Writes a row per element of a slice, or a single row for a struct. The header holds the field names.
+type csvEncoder struct{}
+
+func (csvEncoder) MediaTypes() []string {
+	return []string{"text/csv"}
+}
+
+func (csvEncoder) Encode(w io.Writer, v interface{}) error {
+	header, rows := tabulate(v)
+	for _, row := range rows {
+		for i := range row {
+			row[i] = escapeCSVFormula(row[i])
+		}
+	}
+	writer := csv.NewWriter(w)
+	err := writer.Write(header)
+	if err != nil {
+		return err
+	}
+	err = writer.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// Spreadsheets run cells starting with these as formulas, so the cells are prefixed with a quote.
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+		return "'" + value
+	}
+	return value
+}
+
+// Renders a table, for looking at the API in a browser.
+type htmlEncoder struct{}
+
+func (htmlEncoder) MediaTypes() []string {
+	return []string{"text/html"}
+}
+
+func (htmlEncoder) Encode(w io.Writer, v interface{}) error {
+	header, rows := tabulate(v)
+
+	page := strings.Builder{}
+	page.WriteString("<!DOCTYPE html>\n<html><body><table border=\"1\">\n<tr>")
+	for _, name := range header {
+		page.WriteString("<th>" + html.EscapeString(name) + "</th>")
+	}
+	page.WriteString("</tr>\n")
+	for _, row := range rows {
+		page.WriteString("<tr>")
+		for _, cell := range row {
+			page.WriteString("<td>" + html.EscapeString(cell) + "</td>")
+		}
+		page.WriteString("</tr>\n")
+	}
+	page.WriteString("</table></body></html>\n")
+
+	_, err := io.WriteString(w, page.String())
+	return err
+}
+
+type yamlEncoder struct{}
+
+func (yamlEncoder) MediaTypes() []string {
+	return []string{"application/yaml", "application/x-yaml", "text/yaml"}
+}
+
+func (yamlEncoder) Encode(w io.Writer, v interface{}) error {
+	document := strings.Builder{}
+	writeYAML(&document, reflect.ValueOf(v), 0)
+	_, err := io.WriteString(w, document.String())
+	return err
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Write the value as block style YAML. Strings are double quoted, which makes them valid YAML whatever they contain.
+func writeYAML(document *strings.Builder, value reflect.Value, indent int) {
+	prefix := strings.Repeat("  ", indent)
+	value = reflect.Indirect(value)
+
+	switch {
+	case value.Kind() == reflect.Struct && value.Type() != timeType:
+		fields := jsonFields(value)
+		if len(fields) == 0 {
+			document.WriteString(prefix + "{}\n")
+		}
+		for _, field := range fields {
+			document.WriteString(prefix + field.name + ":")
+			writeYAMLChild(document, field.value, indent)
+		}
+	case value.Kind() == reflect.Slice || value.Kind() == reflect.Array:
+		if value.Len() == 0 {
+			document.WriteString(prefix + "[]\n")
+		}
+		for i := 0; i < value.Len(); i++ {
+			document.WriteString(prefix + "-")
+			writeYAMLChild(document, value.Index(i), indent)
+		}
+	case value.Kind() == reflect.Map:
+		keys := value.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		if len(keys) == 0 {
+			document.WriteString(prefix + "{}\n")
+		}
+		for _, key := range keys {
+			document.WriteString(prefix + strconv.Quote(fmt.Sprint(key.Interface())) + ":")
+			writeYAMLChild(document, value.MapIndex(key), indent)
+		}
+	default:
+		document.WriteString(prefix + yamlScalar(value) + "\n")
+	}
+}
+
+// Write a value after a key or a dash, inline if it's a scalar or on the next lines if not.
+func writeYAMLChild(document *strings.Builder, value reflect.Value, indent int) {
+	value = reflect.Indirect(value)
+	if value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	isCollection := (value.Kind() == reflect.Struct && value.Type() != timeType) || value.Kind() == reflect.Map ||
+		((value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Len() > 0)
+	if !isCollection {
+		document.WriteString(" " + strings.TrimSpace(yamlScalarOrEmpty(value)) + "\n")
+		return
+	}
+	document.WriteString("\n")
+	writeYAML(document, value, indent+1)
+}
+
+func yamlScalarOrEmpty(value reflect.Value) string {
+	if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Len() == 0 {
+		return "[]"
+	}
+	return yamlScalar(value)
+}
+
+func yamlScalar(value reflect.Value) string {
+	if !value.IsValid() {
+		return "null"
+	}
+	if value.Type() == timeType {
+		return strconv.Quote(value.Interface().(time.Time).Format(time.RFC3339Nano))
+	}
+	switch value.Kind() {
+	case reflect.String:
+		return strconv.Quote(value.String())
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(value.Interface())
+	}
+	data, _ := json.Marshal(value.Interface())
+	return string(data)
+}
+
+type namedValue struct {
+	name  string
+	value reflect.Value
+}
+
+// The fields of a struct under their JSON names, with embedded structs flattened.
+func jsonFields(value reflect.Value) []namedValue {
+	fields := make([]namedValue, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			fields = append(fields, jsonFields(value.Field(i))...)
+			continue
+		}
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name := field.Name
+		if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+			name = tagName
+		}
+		fields = append(fields, namedValue{name: name, value: value.Field(i)})
+	}
+	return fields
+}
+
+// Turn a struct, or a slice of structs or scalars, into a header and rows of cells.
+func tabulate(v interface{}) ([]string, [][]string) {
+	value := reflect.Indirect(reflect.ValueOf(v))
+
+	items := []reflect.Value{value}
+	elementType := value.Type()
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		items = make([]reflect.Value, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, reflect.Indirect(value.Index(i)))
+		}
+		elementType = value.Type().Elem()
+	}
+	for elementType.Kind() == reflect.Ptr {
+		elementType = elementType.Elem()
+	}
+
+	if elementType.Kind() != reflect.Struct || elementType == timeType {
+		rows := make([][]string, 0, len(items))
+		for _, item := range items {
+			rows = append(rows, []string{cell(item)})
+		}
+		return []string{"Value"}, rows
+	}
+
+	header := make([]string, 0)
+	for _, field := range jsonFields(reflect.New(elementType).Elem()) {
+		header = append(header, field.name)
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		row := make([]string, 0, len(header))
+		for _, field := range jsonFields(item) {
+			row = append(row, cell(field.value))
+		}
+		rows = append(rows, row)
+	}
+	return header, rows
+}
+
+// Format a value for a table cell. Lists of scalars are joined, anything more complex is written as JSON.
+func cell(value reflect.Value) string {
+	value = reflect.Indirect(value)
+	if !value.IsValid() {
+		return ""
+	}
+	if value.Type() == timeType {
+		t := value.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Slice, reflect.Array:
+		elementKind := value.Type().Elem().Kind()
+		if elementKind != reflect.Struct && elementKind != reflect.Slice && elementKind != reflect.Map && elementKind != reflect.Interface {
+			parts := make([]string, 0, value.Len())
+			for i := 0; i < value.Len(); i++ {
+				parts = append(parts, cell(value.Index(i)))
+			}
+			return strings.Join(parts, ", ")
+		}
+	case reflect.Struct, reflect.Map, reflect.Interface:
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+	data, _ := json.Marshal(value.Interface())
+	return string(data)
+}
@@ -0,0 +1,90 @@
+package MeetupRest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateEncoder(t *testing.T) {
+	cases := []struct {
+		accept      string
+		contentType string
+		status      int
+	}{
+		{"", "application/json", http.StatusOK},
+		{"*/*", "application/json", http.StatusOK},
+		{"text/csv", "text/csv; charset=utf-8", http.StatusOK},
+		{"application/json;q=0.5, application/x-yaml", "application/yaml", http.StatusOK},
+		{"text/*", "text/csv; charset=utf-8", http.StatusOK},
+		{"text/html, */*;q=0.1", "text/html; charset=utf-8", http.StatusOK},
+		{"application/xml", "", http.StatusNotAcceptable},
+		{"application/json;q=0", "", http.StatusNotAcceptable},
+	}
+
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+
+		_, ok := negotiateEncoder(w, r)
+		if ok != (c.status == http.StatusOK) || w.Code != c.status {
+			t.Errorf("Accept %v should respond with %v, got %v.", c.accept, c.status, w.Code)
+			continue
+		}
+		if ok && w.Header().Get("Content-Type") != c.contentType {
+			t.Errorf("Accept %v should respond with %v, got %v.", c.accept, c.contentType, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	speakers := []SpeakerPublicView{{Key: 1, Name: "Jan", Surname: "Kowalski", About: "Go, \"fast\"", Company: "<Company>"}}
+
+	cases := []struct {
+		encoder  Encoder
+		expected string
+	}{
+		{csvEncoder{}, "Key,Name,Surname,About,Email,Company\n" +
+			"1,Jan,Kowalski,\"Go, \"\"fast\"\"\",,<Company>\n"},
+		{yamlEncoder{}, "-\n" +
+			"  Key: 1\n" +
+			"  Name: \"Jan\"\n" +
+			"  Surname: \"Kowalski\"\n" +
+			"  About: \"Go, \\\"fast\\\"\"\n" +
+			"  Email: \"\"\n" +
+			"  Company: \"<Company>\"\n"},
+		{htmlEncoder{}, "<!DOCTYPE html>\n<html><body><table border=\"1\">\n" +
+			"<tr><th>Key</th><th>Name</th><th>Surname</th><th>About</th><th>Email</th><th>Company</th></tr>\n" +
+			"<tr><td>1</td><td>Jan</td><td>Kowalski</td><td>Go, &#34;fast&#34;</td><td></td><td>&lt;Company&gt;</td></tr>\n" +
+			"</table></body></html>\n"},
+	}
+
+	for _, c := range cases {
+		buffer := bytes.Buffer{}
+		err := c.encoder.Encode(&buffer, speakers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buffer.String() != c.expected {
+			t.Errorf("%v should be encoded as:\n%v\ngot:\n%v", c.encoder.MediaTypes()[0], c.expected, buffer.String())
+		}
+	}
+}
+
+func TestCSVEncoderEscapesFormulas(t *testing.T) {
+	speakers := []SpeakerPublicView{{Key: 2, Name: "=1+1", Surname: "\tNowak", About: "\r@admin", Company: "-Company"}}
+	buffer := bytes.Buffer{}
+	err := csvEncoder{}.Encode(&buffer, speakers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Key,Name,Surname,About,Email,Company\n" +
+		"2,'=1+1,'\tNowak,\"'\r@admin\",,'-Company\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, buffer.String())
+	}
+}
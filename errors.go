@@ -0,0 +1,113 @@
+package MeetupRest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cube2222/MeetupRest/api"
+)
+
+type FieldError = api.FieldError
+
+type ErrorResponse = api.ErrorResponse
+
+var errorCodes = map[int]string{
+	http.StatusBadRequest:           "bad_request",
+	http.StatusUnauthorized:         "unauthenticated",
+	http.StatusForbidden:            "forbidden",
+	http.StatusNotFound:             "not_found",
+	http.StatusNotAcceptable:        "not_acceptable",
+	http.StatusConflict:             "conflict",
+	http.StatusPreconditionFailed:   "precondition_failed",
+	http.StatusUnprocessableEntity:  "validation_failed",
+	http.StatusTooManyRequests:      "rate_limited",
+	http.StatusInternalServerError:  "internal_error",
+	http.StatusUnsupportedMediaType: "unsupported_media_type",
+}
+
+// Response writer which only lets the status be written once. Handlers get it from guardResponses.
+type guardedResponseWriter struct {
+	http.ResponseWriter
+	requestID string
+	status    int
+}
+
+func (w *guardedResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *guardedResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *guardedResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Middleware wrapping the response writer, so that the helpers below can tell whether a response was already started.
+func guardResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(*guardedResponseWriter); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestID := r.Header.Get("X-Appengine-Request-Log-Id")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		w.Header().Set("X-Request-Id", requestID)
+
+		next.ServeHTTP(&guardedResponseWriter{ResponseWriter: w, requestID: requestID}, r)
+	})
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// Write an error response with the given status. Does nothing if the response was already started.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeErrorResponse(w, status, ErrorResponse{Message: message})
+}
+
+func writeFieldErrors(w http.ResponseWriter, fieldErrors []FieldError) {
+	writeErrorResponse(w, http.StatusUnprocessableEntity, ErrorResponse{Message: "Validation failed.", FieldErrors: fieldErrors})
+}
+
+func writeLoginRequired(w http.ResponseWriter, loginURL string) {
+	writeErrorResponse(w, http.StatusUnauthorized, ErrorResponse{Message: "You have to be logged in.", LoginURL: loginURL})
+}
+
+func writeInternalError(w http.ResponseWriter) {
+	writeError(w, http.StatusInternalServerError, "Internal server error.")
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, response ErrorResponse) {
+	if gw, ok := w.(*guardedResponseWriter); ok {
+		if gw.status != 0 {
+			return
+		}
+		response.RequestID = gw.requestID
+	}
+	if response.Code == "" {
+		response.Code = errorCodes[status]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,98 @@
+package MeetupRest
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Returned by the stores when putting an entity whose version doesn't match the stored one anymore.
+var ErrVersionConflict = errors.New("the entity was modified in the meantime")
+
+func etag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ETag of a meetup. Its view tells whether the call for papers is open, which changes with the time
+// rather than with the version.
+func meetupETag(meetup *Meetup, now time.Time) string {
+	if meetup.CallForPapers.IsOpen(now) {
+		return fmt.Sprintf(`"%d-cfp"`, meetup.Version)
+	}
+	return etag(meetup.Version)
+}
+
+// ETag of a presentation. Blind review hides its speakers, which changes the view without changing the version.
+func presentationETag(presentation *Presentation, hidden bool) string {
+	if hidden {
+		return fmt.Sprintf(`"%d-blind"`, presentation.Version)
+	}
+	return etag(presentation.Version)
+}
+
+// ETag of a list in the representation of the encoder, changing whenever an entity is added, removed or modified.
+func listETag(encoder Encoder, IDs []int64, etags []string) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s;", encoder.MediaTypes()[0])
+	for i := range IDs {
+		fmt.Fprintf(hash, "%d:%s;", IDs[i], etags[i])
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum64())
+}
+
+// ETag of the agenda of a meetup in the representation of the encoder. The slots show the titles and speakers
+// of the presentations, so it changes with the presentations as well as with the meetup.
+func agendaETag(encoder Encoder, meetup *Meetup, presentations map[int64]Presentation) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%s;%d;", encoder.MediaTypes()[0], meetup.Version)
+	for _, slot := range meetup.Agenda {
+		if presentation, ok := presentations[slot.PresentationID]; ok && slot.Kind == agendaSlotPresentation {
+			fmt.Fprintf(hash, "%d:%d;", slot.PresentationID, presentation.Version)
+		}
+	}
+	return fmt.Sprintf(`"%x"`, hash.Sum64())
+}
+
+// Check whether the ETag is in the comma separated list of ETags of an If-Match or If-None-Match header.
+// If-None-Match uses the weak comparison, which ignores the W/ prefix. If-Match uses the strong one,
+// so a weak ETag never matches there.
+func matchesETag(header string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Make sure the client modifies the version it has seen. Writes a 412 response and returns false if it doesn't.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchesETag(header, etag, false) {
+		return true
+	}
+	writeError(w, http.StatusPreconditionFailed, "The entity was modified in the meantime. Fetch it again and retry.")
+	return false
+}
+
+// Set the ETag of the response. Writes a 304 response and returns true if the client already has this version.
+func checkIfNoneMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !matchesETag(header, etag, true) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+func writeVersionConflict(w http.ResponseWriter) {
+	writeError(w, http.StatusPreconditionFailed, "The entity was modified in the meantime. Fetch it again and retry.")
+}
@@ -0,0 +1,66 @@
+package MeetupRest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchesETag(t *testing.T) {
+	cases := []struct {
+		header  string
+		etag    string
+		weak    bool
+		matches bool
+	}{
+		{`"3"`, `"3"`, false, true},
+		{`"2", W/"3"`, `"3"`, true, true},
+		{`"2", W/"3"`, `"3"`, false, false},
+		{`*`, `"3"`, false, true},
+		{`"2"`, `"3"`, true, false},
+		{`"31"`, `"3"`, false, false},
+	}
+
+	for _, c := range cases {
+		if matchesETag(c.header, c.etag, c.weak) != c.matches {
+			t.Errorf("Header %v matching ETag %v with weak comparison %v should be %v.", c.header, c.etag, c.weak, c.matches)
+		}
+	}
+}
+
+func TestListETag(t *testing.T) {
+	IDs := []int64{1, 2}
+	etags := []string{etag(3), etag(1)}
+	if listETag(jsonEncoder{}, IDs, etags) == listETag(csvEncoder{}, IDs, etags) {
+		t.Error("Representations of the same list should have different ETags.")
+	}
+	if listETag(jsonEncoder{}, IDs, etags) != listETag(jsonEncoder{}, IDs, []string{etag(3), etag(1)}) {
+		t.Error("The same list should have the same ETag.")
+	}
+}
+
+func TestMeetupETag(t *testing.T) {
+	now := time.Now()
+	meetup := &Meetup{Version: 3, CallForPapers: CallForPapers{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}}
+	if meetupETag(meetup, now) == meetupETag(meetup, now.Add(2*time.Hour)) {
+		t.Error("The ETag should change when the call for papers closes.")
+	}
+	if meetupETag(meetup, now.Add(2*time.Hour)) != etag(3) {
+		t.Errorf("Expected the version once the call for papers is closed, got %v", meetupETag(meetup, now.Add(2*time.Hour)))
+	}
+}
+
+func TestAgendaETag(t *testing.T) {
+	meetup := Meetup{Version: 2, Agenda: []AgendaSlot{{Kind: agendaSlotPresentation, PresentationID: 1}, {Kind: agendaSlotBreak}}}
+	presentations := map[int64]Presentation{1: {Title: "Go", Version: 1}}
+	before := agendaETag(jsonEncoder{}, &meetup, presentations)
+
+	presentations[1] = Presentation{Title: "Go 2", Version: 2}
+	if agendaETag(jsonEncoder{}, &meetup, presentations) == before {
+		t.Error("Agenda ETag should change with the presentations in it.")
+	}
+	presentations[1] = Presentation{Title: "Go", Version: 1}
+	presentations[3] = Presentation{Title: "Rust", Version: 5}
+	if agendaETag(jsonEncoder{}, &meetup, presentations) != before {
+		t.Error("Agenda ETag should only depend on the scheduled presentations.")
+	}
+}
@@ -0,0 +1,350 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+)
+
+// Something that happened, published by the handlers once it's stored.
+type Event interface {
+	EventName() string
+}
+
+type SpeakerCreated struct {
+	ID      int64
+	Speaker Speaker
+}
+
+type SpeakerUpdated struct {
+	ID      int64
+	Speaker Speaker
+}
+
+// The speaker was moved to the trash.
+type SpeakerDeleted struct {
+	ID int64
+}
+
+type PresentationCreated struct {
+	ID           int64
+	Presentation Presentation
+}
+
+// Published for edits, status changes and restored revisions alike.
+type PresentationUpdated struct {
+	ID           int64
+	Presentation Presentation
+}
+
+// The presentation was moved to the trash.
+type PresentationDeleted struct {
+	ID int64
+}
+
+type PresentationVoted struct {
+	ID           int64
+	Presentation Presentation
+	// The upvote was withdrawn.
+	Withdrawn bool
+}
+
+type MeetupCreated struct {
+	ID     int64
+	Meetup Meetup
+}
+
+// Published for edits, agenda changes and presentations detached by cascading deletes alike.
+type MeetupUpdated struct {
+	ID     int64
+	Meetup Meetup
+}
+
+// The meetup was moved to the trash.
+type MeetupDeleted struct {
+	ID int64
+}
+
+type BallotCast struct {
+	MeetupID int64
+	Voter    string
+	Mode     VotingMode
+	// The ballot was withdrawn.
+	Withdrawn bool
+}
+
+// The presentation was selected for the meetup when its voting closed. The meetup is updated separately.
+type PresentationSelected struct {
+	MeetupID       int64
+	Meetup         Meetup
+	PresentationID int64
+	Presentation   Presentation
+}
+
+// Kind is one of the trash kinds.
+type TrashRestored struct {
+	Kind string
+	ID   int64
+}
+
+type TrashPurged struct {
+	Kind string
+	ID   int64
+}
+
+// The keys of the archive. Skipped entities are listed too, subscribers read what was stored.
+type ArchiveImported struct {
+	Speakers      []int64
+	Presentations []int64
+	Meetups       []int64
+}
+
+type VotesAnonymized struct {
+	Upvotes int
+	Ballots int
+}
+
+type VotesInvalidated struct {
+	Voters        []string
+	Presentations []int64
+	Ballots       int
+	Blocked       bool
+}
+
+func (e SpeakerCreated) EventName() string       { return "SpeakerCreated" }
+func (e SpeakerUpdated) EventName() string       { return "SpeakerUpdated" }
+func (e SpeakerDeleted) EventName() string       { return "SpeakerDeleted" }
+func (e PresentationCreated) EventName() string  { return "PresentationCreated" }
+func (e PresentationUpdated) EventName() string  { return "PresentationUpdated" }
+func (e PresentationDeleted) EventName() string  { return "PresentationDeleted" }
+func (e PresentationVoted) EventName() string    { return "PresentationVoted" }
+func (e MeetupCreated) EventName() string        { return "MeetupCreated" }
+func (e MeetupUpdated) EventName() string        { return "MeetupUpdated" }
+func (e MeetupDeleted) EventName() string        { return "MeetupDeleted" }
+func (e BallotCast) EventName() string           { return "BallotCast" }
+func (e PresentationSelected) EventName() string { return "PresentationSelected" }
+func (e TrashRestored) EventName() string        { return "TrashRestored" }
+func (e TrashPurged) EventName() string          { return "TrashPurged" }
+func (e ArchiveImported) EventName() string      { return "ArchiveImported" }
+func (e VotesAnonymized) EventName() string      { return "VotesAnonymized" }
+func (e VotesInvalidated) EventName() string     { return "VotesInvalidated" }
+
+// How an event reaches a subscriber.
+type Delivery int
+
+const (
+	// The subscriber handles the event before Publish returns, in the order of subscription.
+	DeliverSync Delivery = iota
+	// The subscriber handles the events in its own goroutine, Publish doesn't wait for it. A request gets one
+	// goroutine per subscriber, so the events of all its Publish calls are handled one at a time, in order.
+	// The request waits for them before ending. Outside a request they're handled before Publish returns.
+	DeliverAsync
+)
+
+// Subscribers get every event and ignore the ones they aren't interested in. Their errors are logged,
+// the change that caused the event is already stored.
+type EventHandler func(ctx context.Context, event Event) error
+
+type subscriber struct {
+	name     string
+	delivery Delivery
+	handler  EventHandler
+}
+
+// Delivers the events published by the handlers to the subscribers, which register independently.
+// An App Engine request can't leave goroutines behind, so NewHandler waits for the async deliveries of
+// every request before ending it.
+type EventBus struct {
+	mutex       sync.Mutex
+	subscribers []subscriber
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+func (b *EventBus) Subscribe(name string, delivery Delivery, handler EventHandler) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscribers = append(b.subscribers, subscriber{name: name, delivery: delivery, handler: handler})
+}
+
+// Deliver the events, in order, to all the subscribers.
+func (b *EventBus) Publish(ctx context.Context, events ...Event) {
+	b.mutex.Lock()
+	subscribers := append([]subscriber(nil), b.subscribers...)
+	b.mutex.Unlock()
+
+	pending, _ := ctx.Value(pendingEventsKey{}).(*pendingEvents)
+	for _, event := range events {
+		for _, s := range subscribers {
+			if s.delivery == DeliverSync || pending == nil {
+				b.deliver(ctx, s, event)
+			}
+		}
+	}
+	if pending == nil {
+		return
+	}
+
+	for index, s := range subscribers {
+		if s.delivery == DeliverAsync {
+			pending.enqueue(b, index, s, detach(ctx), events)
+		}
+	}
+}
+
+func (b *EventBus) deliver(ctx context.Context, s subscriber, event Event) {
+	err := s.handler(ctx, event)
+	if err != nil {
+		log.Errorf(ctx, "Subscriber %v failed to handle %v: %v", s.name, event.EventName(), err)
+	}
+}
+
+// Context keeping the values of its parent, but not its deadline, so async deliveries outlive the timeout of the handler.
+type detachedContext struct {
+	context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
+
+// Context value holding the async deliveries of the events published while handling the request.
+type pendingEventsKey struct{}
+
+type pendingEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+// The events an async subscriber still has to handle in a request, and whether its goroutine is running.
+type deliveryQueue struct {
+	events  []pendingEvent
+	running bool
+}
+
+// Something to do once the async deliveries of a request are done.
+type pendingAction struct {
+	ctx context.Context
+	f   func() error
+}
+
+// The async deliveries of a request, and what runs once they're done.
+type pendingEvents struct {
+	wait   sync.WaitGroup
+	mutex  sync.Mutex
+	queues map[int]*deliveryQueue
+	after  map[string]pendingAction
+	// The keys of after, in the order they were added.
+	order []string
+}
+
+func newPendingEvents() *pendingEvents {
+	return &pendingEvents{queues: make(map[int]*deliveryQueue), after: make(map[string]pendingAction)}
+}
+
+// Queue the events for the subscriber at index, starting its goroutine unless it's running already.
+func (p *pendingEvents) enqueue(b *EventBus, index int, s subscriber, ctx context.Context, events []Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	queue := p.queues[index]
+	if queue == nil {
+		queue = &deliveryQueue{}
+		p.queues[index] = queue
+	}
+	for _, event := range events {
+		queue.events = append(queue.events, pendingEvent{ctx: ctx, event: event})
+	}
+	if queue.running {
+		return
+	}
+
+	queue.running = true
+	p.wait.Add(1)
+	go func() {
+		defer p.wait.Done()
+		for {
+			p.mutex.Lock()
+			if len(queue.events) == 0 {
+				queue.running = false
+				p.mutex.Unlock()
+				return
+			}
+			next := queue.events[0]
+			queue.events = queue.events[1:]
+			p.mutex.Unlock()
+
+			b.deliver(next.ctx, s, next.event)
+		}
+	}()
+}
+
+// Run f once the async deliveries of the request are done, only the first time it's asked for with the key
+// in that request. It lets a subscriber handle many events with one call. Its error is logged then.
+// Outside a request it runs right away and its error is returned.
+func afterEvents(ctx context.Context, key string, f func() error) error {
+	pending, _ := ctx.Value(pendingEventsKey{}).(*pendingEvents)
+	if pending == nil {
+		return f()
+	}
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	if _, ok := pending.after[key]; !ok {
+		pending.after[key] = pendingAction{ctx: ctx, f: f}
+		pending.order = append(pending.order, key)
+	}
+	return nil
+}
+
+// Wait for the async deliveries of the events published while handling the request before ending it,
+// then run what they left for afterwards. Other requests' deliveries don't hold it up.
+func waitForEvents(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pending := newPendingEvents()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), pendingEventsKey{}, pending)))
+		pending.wait.Wait()
+		for _, key := range pending.order {
+			action := pending.after[key]
+			err := action.f()
+			if err != nil {
+				log.Errorf(action.ctx, "Running %v after the events failed: %v", key, err)
+			}
+		}
+	})
+}
+
+// The App Engine context of the request, keeping the values the middleware added to the request's context.
+func requestContext(r *http.Request) context.Context {
+	return appengine.WithContext(r.Context(), r)
+}
+
+// Log every event with its content.
+func subscribeAuditLog(Events *EventBus) {
+	Events.Subscribe("audit", DeliverSync, func(ctx context.Context, event Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		log.Infof(ctx, "Event %v: %s", event.EventName(), data)
+		return nil
+	})
+}
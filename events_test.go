@@ -0,0 +1,128 @@
+package MeetupRest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestEventBus(t *testing.T) {
+	events := NewEventBus()
+	delivered := make([]string, 0)
+	events.Subscribe("first", DeliverSync, func(ctx context.Context, event Event) error {
+		delivered = append(delivered, "first "+event.EventName())
+		return nil
+	})
+	events.Subscribe("second", DeliverSync, func(ctx context.Context, event Event) error {
+		delivered = append(delivered, "second "+event.EventName())
+		return nil
+	})
+
+	var mutex sync.Mutex
+	async := make([]Event, 0)
+	release := make(chan bool)
+	events.Subscribe("async", DeliverAsync, func(ctx context.Context, event Event) error {
+		<-release
+		if ctx.Err() != nil {
+			t.Errorf("Async deliveries shouldn't be cancelled with the request: %v", ctx.Err())
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		async = append(async, event)
+		return nil
+	})
+
+	published := make(chan bool)
+	handler := waitForEvents(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		events.Publish(ctx, SpeakerCreated{ID: 1})
+		events.Publish(ctx, SpeakerDeleted{ID: 1})
+		cancel()
+		close(published)
+	}))
+	served := make(chan bool)
+	go func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		close(served)
+	}()
+	<-published
+
+	expected := []string{"first SpeakerCreated", "second SpeakerCreated", "first SpeakerDeleted", "second SpeakerDeleted"}
+	if len(delivered) != len(expected) {
+		t.Fatalf("Expected %v before Publish returns, got %v", expected, delivered)
+	}
+	for index := range expected {
+		if delivered[index] != expected[index] {
+			t.Errorf("Expected %v before Publish returns, got %v", expected, delivered)
+			break
+		}
+	}
+	select {
+	case <-served:
+		t.Fatal("The request should wait for its async deliveries")
+	default:
+	}
+
+	close(release)
+	<-served
+	if len(async) != 2 || async[0].EventName() != "SpeakerCreated" || async[1].EventName() != "SpeakerDeleted" {
+		t.Errorf("Both events should be delivered asynchronously, in the order of the Publish calls, before the request ends, got %v", async)
+	}
+
+	// Outside a request nothing waits for them, so they're delivered right away.
+	events.Publish(context.Background(), SpeakerUpdated{ID: 1})
+	if len(async) != 3 {
+		t.Errorf("Expected the event delivered before Publish returns, got %v", async)
+	}
+}
+
+func TestMeetupSync(t *testing.T) {
+	type requestKey struct{}
+	ctx := context.WithValue(context.Background(), requestKey{}, 1)
+	updates := 0
+	created := make([]int64, 0)
+	handle := meetupSync(
+		func(ctx context.Context) error {
+			if ctx.Value(requestKey{}) != 1 {
+				t.Error("The update should run with the context of the request")
+			}
+			updates++
+			return nil
+		},
+		func(ctx context.Context, ID int64) error {
+			created = append(created, ID)
+			return nil
+		},
+	)
+
+	for _, event := range []Event{MeetupUpdated{ID: 1}, PresentationVoted{ID: 2}, TrashRestored{Kind: trashKindMeetup, ID: 1}, MeetupCreated{ID: 7}, SpeakerUpdated{ID: 1}} {
+		err := handle(ctx, event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if updates != 3 || len(created) != 1 || created[0] != 7 {
+		t.Errorf("Expected an update per change outside a request and meetup 7 created, got %v updates and %v", updates, created)
+	}
+
+	updates = 0
+	handler := waitForEvents(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestKey{}, 1)
+		for _, event := range []Event{MeetupUpdated{ID: 1}, PresentationVoted{ID: 2}, PresentationVoted{ID: 3}} {
+			err := handle(ctx, event)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if updates != 0 {
+			t.Errorf("The update should wait for the end of the request, got %v", updates)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if updates != 1 {
+		t.Errorf("Expected one update for the whole request, got %v", updates)
+	}
+}
@@ -5,49 +5,142 @@ import (
 
 	"fmt"
 	"github.com/gorilla/mux"
-	"google.golang.org/appengine"
+	"golang.org/x/net/context"
 	"google.golang.org/appengine/user"
 	"net/url"
+	"os"
 	"time"
 )
 
 var defaultRequestTimeout = time.Second * 4
 
 func init() {
+	var handler http.Handler
+	var err error
+	if standaloneEnabled() {
+		handler, err = NewHandler(NewMemoryStore(), NewMemorySearchIndex(), NewMemoryRateLimiter(), legacyRoutesEnabled())
+	} else {
+		handler, err = NewHandler(&GoogleDatastoreStore{}, &AppEngineSearchIndex{}, &MemcacheRateLimiter{}, legacyRoutesEnabled())
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	http.Handle("/", handler)
+}
+
+// All the storage the routes need. GoogleDatastoreStore implements it.
+type Store interface {
+	SpeakerStore
+	PresentationStore
+	PresentationRevisionStore
+	ReviewStore
+	MeetupStore
+	BallotStore
+	VoteEventStore
+	MetadataStore
+	TransactionStore
+	ReserveIDs(ctx context.Context, kind string, IDs []int64) error
+}
 
+// Build the handler serving the whole application, the router wrapped in the middleware.
+func NewHandler(Storage Store, Index SearchIndex, Limiter RateLimiter, legacyRoutes bool) (http.Handler, error) {
+	Events := NewEventBus()
+	m, err := NewRouter(Storage, Index, Limiter, Events, legacyRoutes)
+	if err != nil {
+		return nil, err
+	}
+	return guardResponses(rejectCrossSiteRequests(waitForEvents(m))), nil
+}
+
+// Build the router serving the whole application. The routes publish their changes to Events, where the search index,
+// meetup.com, the speaker notifications, the audit log and the presentation stream subscribe. The votes are rate limited with Limiter.
+func NewRouter(Storage Store, Index SearchIndex, Limiter RateLimiter, Events *EventBus, legacyRoutes bool) (*mux.Router, error) {
 	m := mux.NewRouter()
-	Storage := GoogleDatastoreStore{}
 
-	MeetupAPIUpdateFunction := getMeetupUpdateFunction(&Storage, &Storage)
-	MeetupAPICreateFunction := getMeetupCreateFunction(&Storage, &Storage)
+	MeetupAPIUpdateFunction := getMeetupUpdateFunction(Storage, Storage, Storage)
+	Guard := NewVoteGuard(Limiter, Storage, Storage)
+	Bus := NewPresentationBus()
+
+	subscribeAuditLog(Events)
+	subscribeSearchIndex(Events, Index, Storage, Storage, Storage)
+	subscribePresentationStream(Events, Bus)
+	subscribeMeetupSync(Events, MeetupAPIUpdateFunction, getMeetupCreateFunction(Storage, Storage))
+	subscribeSpeakerNotifications(Events, getSpeakerNotifyFunction(Storage, Storage))
 
-	s := m.PathPrefix("/speaker").Subrouter()
-	err := RegisterSpeakerRoutes(s, &Storage)
+	var err error
+	if legacyRoutes {
+		s := m.PathPrefix("/speaker").Subrouter()
+		err = firstError(err, RegisterSpeakerRoutes(s, Storage, Storage, Storage, Storage, Events))
 
-	s = m.PathPrefix("/presentation").Subrouter()
-	err = RegisterPresentationRoutes(s, &Storage, &Storage, MeetupAPIUpdateFunction)
+		s = m.PathPrefix("/presentation").Subrouter()
+		err = firstError(err, RegisterPresentationRoutes(s, Storage, Storage, Storage, Storage, Storage, Storage, Storage, Guard, Bus, Events))
 
-	s = m.PathPrefix("/meetup").Subrouter()
-	err = RegisterMeetupRoutes(s, &Storage, &Storage, &Storage, MeetupAPIUpdateFunction, MeetupAPICreateFunction)
+		s = m.PathPrefix("/meetup").Subrouter()
+		err = firstError(err, RegisterMeetupRoutes(s, Storage, Storage, Storage, Storage, Events))
 
-	s = m.PathPrefix("/metadata").Subrouter()
-	err = RegisterMetadataRoutes(s, &Storage)
+		s = m.PathPrefix("/metadata").Subrouter()
+		err = firstError(err, RegisterMetadataRoutes(s, Storage))
+	}
+
+	err = firstError(err, RegisterSearchRoutes(m, Index))
+
+	s := m.PathPrefix("/trash").Subrouter()
+	err = firstError(err, RegisterTrashRoutes(s, Storage, Storage, Storage, Storage, Events))
+
+	s = m.PathPrefix("/selection").Subrouter()
+	err = firstError(err, RegisterSelectionRoutes(s, Storage, Storage, Storage, Storage, Storage, Events))
+
+	s = m.PathPrefix("/votes").Subrouter()
+	err = firstError(err, RegisterVoteFraudRoutes(s, Storage))
+
+	api := m.PathPrefix("/api/v1").Subrouter()
+	err = firstError(err, RegisterSpeakerAPIRoutes(api, Storage, Storage, Storage, Storage, Events))
+	err = firstError(err, RegisterPresentationAPIRoutes(api, Storage, Storage, Storage, Storage, Storage, Storage, Storage, Guard, Bus, Events))
+	err = firstError(err, RegisterMeetupAPIRoutes(api, Storage, Storage, Storage, Storage, Storage, Events, MeetupAPIUpdateFunction))
+	err = firstError(err, RegisterMetadataAPIRoutes(api, Storage))
+	err = firstError(err, RegisterReviewAPIRoutes(api, Storage, Storage, Storage))
+	err = firstError(err, RegisterVotingAPIRoutes(api, Storage, Storage, Storage, Storage, Storage, Guard, Events))
+	err = firstError(err, RegisterVoterAPIRoutes(api, Storage, Storage, Storage, Storage, Storage, Events))
+	err = firstError(err, RegisterVoteFraudAPIRoutes(api, Storage, Storage, Storage, Storage, Storage, Storage, Events))
+	err = firstError(err, RegisterTrashAPIRoutes(api, Storage, Storage, Storage, Storage, Events))
+	err = firstError(err, RegisterArchiveAPIRoutes(api, Storage, Events))
+	err = firstError(err, RegisterSearchAPIRoutes(api, Index, Storage, Storage, Storage))
+
+	err = firstError(err, RegisterOpenAPIRoutes(m, legacyRoutes))
 
 	m.PathPrefix("/public/").Handler(http.StripPrefix("/public/", http.FileServer(http.Dir("public/"))))
 
 	m.HandleFunc("/isLoggedIn", isLoggedIn)
 	m.HandleFunc("/getLoginAddress", getLoginAddress)
+	m.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("No route for %v %v", r.Method, r.URL.Path))
+	})
 
+	return m, err
+}
+
+func firstError(err error, next error) error {
 	if err != nil {
-		panic(err)
+		return err
 	}
+	return next
+}
 
-	http.Handle("/", m)
+// The legacy routes are used by the React frontend. Set LEGACY_ROUTES to false in app.yaml to only serve /api/v1.
+func legacyRoutesEnabled() bool {
+	return os.Getenv("LEGACY_ROUTES") != "false"
+}
+
+// Standalone, everything is kept in the memory of the instance, e.g. for trying it out with dev_appserver.py.
+// Set STANDALONE to true in app.yaml to turn it on.
+func standaloneEnabled() bool {
+	return os.Getenv("STANDALONE") == "true"
 }
 
 func isLoggedIn(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
-	u := user.Current(ctx)
+	ctx := requestContext(r)
+	u := currentUser(ctx, r)
 	if u == nil {
 		fmt.Fprint(w, "false")
 		return
@@ -56,15 +149,15 @@ func isLoggedIn(w http.ResponseWriter, r *http.Request) {
 }
 
 func getLoginAddress(w http.ResponseWriter, r *http.Request) {
-	ctx := appengine.NewContext(r)
+	ctx := requestContext(r)
 
 	vars, err := url.ParseQuery(r.URL.RawQuery)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	if err != nil || vars.Get("url") == "" {
+		writeError(w, http.StatusBadRequest, "Parameter url is mandatory.")
 		return
 	}
 
-	url, _ := user.LoginURL(ctx, fmt.Sprintf("%v", vars["url"][0]))
+	url, _ := user.LoginURL(ctx, vars.Get("url"))
 	fmt.Fprint(w, url)
 	return
 }
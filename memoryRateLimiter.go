@@ -0,0 +1,44 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Rate limiter counting in memory, for running on a single instance or in tests.
+type MemoryRateLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]memoryBucket
+}
+
+type memoryBucket struct {
+	count   int
+	expires time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: make(map[string]memoryBucket)}
+}
+
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	for bucket, value := range l.buckets {
+		if !now.Before(value.expires) {
+			delete(l.buckets, bucket)
+		}
+	}
+
+	start := now.Truncate(window)
+	bucket := fmt.Sprintf("%v:%v", key, start.UnixNano())
+	value := l.buckets[bucket]
+	value.count++
+	value.expires = start.Add(window)
+	l.buckets[bucket] = value
+	return value.count <= limit, nil
+}
@@ -0,0 +1,118 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Search index held in memory, for running standalone and in tests.
+// Documents are ranked by TF-IDF, with the matches weighted by the field they're in.
+type MemorySearchIndex struct {
+	lock      sync.Mutex
+	documents map[string]memorySearchDocument
+}
+
+type memorySearchDocument struct {
+	SearchDocument
+	// Weighted number of occurrences of each word.
+	frequencies map[string]float64
+}
+
+func NewMemorySearchIndex() *MemorySearchIndex {
+	return &MemorySearchIndex{documents: make(map[string]memorySearchDocument)}
+}
+
+func searchDocumentID(kind string, key int64) string {
+	return fmt.Sprintf("%v-%v", kind, key)
+}
+
+func (i *MemorySearchIndex) IndexDocument(ctx context.Context, document SearchDocument) error {
+	frequencies := make(map[string]float64)
+	for _, field := range document.Fields {
+		for _, token := range tokenize(field.Text) {
+			frequencies[token.Word] += field.Weight
+		}
+	}
+	document.Fields = append([]SearchField(nil), document.Fields...)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.documents[searchDocumentID(document.Kind, document.Key)] = memorySearchDocument{SearchDocument: document, frequencies: frequencies}
+	return nil
+}
+
+func (i *MemorySearchIndex) RemoveDocument(ctx context.Context, kind string, key int64) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	delete(i.documents, searchDocumentID(kind, key))
+	return nil
+}
+
+func (i *MemorySearchIndex) Search(ctx context.Context, query string, kind string, limit int) ([]SearchResult, error) {
+	terms := searchTerms(query)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	documentFrequencies := make(map[string]int)
+	for _, document := range i.documents {
+		for _, term := range terms {
+			if document.frequencies[term] > 0 {
+				documentFrequencies[term]++
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0)
+	for _, document := range i.documents {
+		if kind != "" && document.Kind != kind {
+			continue
+		}
+
+		score := 0.0
+		for _, term := range terms {
+			frequency := document.frequencies[term]
+			if frequency == 0 {
+				score = 0
+				break
+			}
+			score += frequency * math.Log(1+float64(len(i.documents))/float64(documentFrequencies[term]))
+		}
+		if score == 0 {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Kind:       document.Kind,
+			Key:        document.Key,
+			Title:      document.Title,
+			Score:      score,
+			Highlights: highlightFields(document.Fields, terms),
+		})
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		if results[a].Kind != results[b].Kind {
+			return results[a].Kind < results[b].Kind
+		}
+		return results[a].Key < results[b].Key
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+func (i *MemorySearchIndex) Clear(ctx context.Context) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.documents = make(map[string]memorySearchDocument)
+	return nil
+}
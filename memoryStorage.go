@@ -0,0 +1,696 @@
+package MeetupRest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/appengine/datastore"
+)
+
+// Store keeping everything in memory, for tests and local tools. It behaves like GoogleDatastoreStore:
+// deleted entities go to the trash, writes bump the version and transactions roll back on errors.
+type MemoryStore struct {
+	mutex            sync.Mutex
+	transactionMutex sync.Mutex
+	state            memoryState
+}
+
+type memoryState struct {
+	nextID        int64
+	speakers      map[int64]Speaker
+	presentations map[int64]Presentation
+	revisions     map[int64]map[int64]PresentationRevision
+	reviews       map[int64]map[string]Review
+	meetups       map[int64]Meetup
+	ballots       map[int64]map[string]Ballot
+	voteEvents    []VoteEvent
+	blocked       map[string]bool
+	metadata      map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{state: memoryState{
+		nextID:        1,
+		speakers:      make(map[int64]Speaker),
+		presentations: make(map[int64]Presentation),
+		revisions:     make(map[int64]map[int64]PresentationRevision),
+		reviews:       make(map[int64]map[string]Review),
+		meetups:       make(map[int64]Meetup),
+		ballots:       make(map[int64]map[string]Ballot),
+		blocked:       make(map[string]bool),
+		metadata:      make(map[string]string),
+	}}
+}
+
+// The entities stored in the maps are never modified, so copying the maps is enough for a snapshot.
+func (s memoryState) snapshot() memoryState {
+	copied := memoryState{
+		nextID:        s.nextID,
+		speakers:      make(map[int64]Speaker, len(s.speakers)),
+		presentations: make(map[int64]Presentation, len(s.presentations)),
+		revisions:     make(map[int64]map[int64]PresentationRevision, len(s.revisions)),
+		reviews:       make(map[int64]map[string]Review, len(s.reviews)),
+		meetups:       make(map[int64]Meetup, len(s.meetups)),
+		ballots:       make(map[int64]map[string]Ballot, len(s.ballots)),
+		voteEvents:    append([]VoteEvent(nil), s.voteEvents...),
+		blocked:       make(map[string]bool, len(s.blocked)),
+		metadata:      make(map[string]string, len(s.metadata)),
+	}
+	for key, value := range s.blocked {
+		copied.blocked[key] = value
+	}
+	for key, value := range s.speakers {
+		copied.speakers[key] = value
+	}
+	for key, value := range s.presentations {
+		copied.presentations[key] = value
+	}
+	for key, value := range s.revisions {
+		revisions := make(map[int64]PresentationRevision, len(value))
+		for revisionKey, revision := range value {
+			revisions[revisionKey] = revision
+		}
+		copied.revisions[key] = revisions
+	}
+	for key, value := range s.reviews {
+		reviews := make(map[string]Review, len(value))
+		for reviewer, review := range value {
+			reviews[reviewer] = review
+		}
+		copied.reviews[key] = reviews
+	}
+	for key, value := range s.meetups {
+		copied.meetups[key] = value
+	}
+	for key, value := range s.ballots {
+		ballots := make(map[string]Ballot, len(value))
+		for voter, ballot := range value {
+			ballots[voter] = ballot
+		}
+		copied.ballots[key] = ballots
+	}
+	for key, value := range s.metadata {
+		copied.metadata[key] = value
+	}
+	return copied
+}
+
+// Transactions are serialized. If f fails, the store is rolled back to its state before the transaction.
+func (ms *MemoryStore) RunInTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if ctx.Value(transactionContextKey{}) != nil {
+		return f(ctx)
+	}
+
+	ms.transactionMutex.Lock()
+	defer ms.transactionMutex.Unlock()
+
+	ms.mutex.Lock()
+	snapshot := ms.state.snapshot()
+	ms.mutex.Unlock()
+
+	err := f(context.WithValue(ctx, transactionContextKey{}, true))
+	if err != nil {
+		ms.mutex.Lock()
+		ms.state = snapshot
+		ms.mutex.Unlock()
+	}
+	return err
+}
+
+func sortedIDs(IDs []int64) []int64 {
+	sort.Slice(IDs, func(i, j int) bool { return IDs[i] < IDs[j] })
+	return IDs
+}
+
+func (ms *MemoryStore) GetSpeaker(ctx context.Context, ID int64) (Speaker, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	speaker, ok := ms.state.speakers[ID]
+	if !ok || speaker.Deleted {
+		return Speaker{}, datastore.ErrNoSuchEntity
+	}
+	return speaker, nil
+}
+
+func (ms *MemoryStore) GetSpeakerIdByName(ctx context.Context, name string) (int64, error) {
+	IDs, speakers, _ := ms.GetAllSpeakers(ctx)
+	for index, speaker := range speakers {
+		if speaker.GetSpeakerFullName() == name {
+			return IDs[index], nil
+		}
+	}
+	return 0, datastore.ErrNoSuchEntity
+}
+
+func (ms *MemoryStore) GetAllSpeakers(ctx context.Context) ([]int64, []Speaker, error) {
+	return ms.getSpeakers(false)
+}
+
+func (ms *MemoryStore) GetDeletedSpeakers(ctx context.Context) ([]int64, []Speaker, error) {
+	return ms.getSpeakers(true)
+}
+
+func (ms *MemoryStore) getSpeakers(deleted bool) ([]int64, []Speaker, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	IDs := make([]int64, 0, len(ms.state.speakers))
+	for ID, speaker := range ms.state.speakers {
+		if speaker.Deleted == deleted {
+			IDs = append(IDs, ID)
+		}
+	}
+	speakers := make([]Speaker, 0, len(IDs))
+	for _, ID := range sortedIDs(IDs) {
+		speakers = append(speakers, ms.state.speakers[ID])
+	}
+	return IDs, speakers, nil
+}
+
+func (ms *MemoryStore) PutSpeaker(ctx context.Context, ID int64, speaker *Speaker) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if stored, ok := ms.state.speakers[ID]; ok && stored.Version != speaker.Version {
+		return ErrVersionConflict
+	}
+	speaker.Version++
+	ms.state.speakers[ID] = *speaker
+	if ID >= ms.state.nextID {
+		ms.state.nextID = ID + 1
+	}
+	return nil
+}
+
+func (ms *MemoryStore) AddSpeaker(ctx context.Context, speaker *Speaker) (int64, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ID := ms.state.nextID
+	ms.state.nextID++
+	speaker.Version = 1
+	ms.state.speakers[ID] = *speaker
+	return ID, nil
+}
+
+func (ms *MemoryStore) DeleteSpeaker(ctx context.Context, ID int64) error {
+	return ms.setSpeakerDeleted(ID, true)
+}
+
+func (ms *MemoryStore) UndeleteSpeaker(ctx context.Context, ID int64) error {
+	return ms.setSpeakerDeleted(ID, false)
+}
+
+func (ms *MemoryStore) setSpeakerDeleted(ID int64, deleted bool) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	speaker, ok := ms.state.speakers[ID]
+	if !ok || speaker.Deleted == deleted {
+		return datastore.ErrNoSuchEntity
+	}
+	speaker.Deleted = deleted
+	speaker.Version++
+	speaker.DeletedAt = time.Time{}
+	if deleted {
+		speaker.DeletedAt = time.Now()
+	}
+	ms.state.speakers[ID] = speaker
+	return nil
+}
+
+func (ms *MemoryStore) PurgeSpeaker(ctx context.Context, ID int64) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.state.speakers, ID)
+	return nil
+}
+
+// Presentations and meetups hold slices, so they're copied on the way in and out
+// to keep handlers from modifying the stored entities in place.
+func copyPresentation(presentation Presentation) Presentation {
+	presentation.Speakers = append([]string(nil), presentation.Speakers...)
+	presentation.Voters = append([]string(nil), presentation.Voters...)
+	presentation.Answers = append([]string(nil), presentation.Answers...)
+	return presentation
+}
+
+func (ms *MemoryStore) GetPresentation(ctx context.Context, ID int64) (Presentation, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	presentation, ok := ms.state.presentations[ID]
+	if !ok || presentation.Deleted {
+		return Presentation{}, datastore.ErrNoSuchEntity
+	}
+	return copyPresentation(presentation), nil
+}
+
+func (ms *MemoryStore) GetAllPresentations(ctx context.Context) ([]int64, []Presentation, error) {
+	return ms.getPresentations(false)
+}
+
+func (ms *MemoryStore) GetDeletedPresentations(ctx context.Context) ([]int64, []Presentation, error) {
+	return ms.getPresentations(true)
+}
+
+func (ms *MemoryStore) getPresentations(deleted bool) ([]int64, []Presentation, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	IDs := make([]int64, 0, len(ms.state.presentations))
+	for ID, presentation := range ms.state.presentations {
+		if presentation.Deleted == deleted {
+			IDs = append(IDs, ID)
+		}
+	}
+	presentations := make([]Presentation, 0, len(IDs))
+	for _, ID := range sortedIDs(IDs) {
+		presentations = append(presentations, copyPresentation(ms.state.presentations[ID]))
+	}
+	return IDs, presentations, nil
+}
+
+// Put the presentation, keeping the previous content as a revision if it changed.
+func (ms *MemoryStore) PutPresentation(ctx context.Context, ID int64, presentation *Presentation) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	previous, ok := ms.state.presentations[ID]
+	if ok && previous.Version != presentation.Version {
+		return ErrVersionConflict
+	}
+	if ok {
+		revision := previous.NewRevision(time.Now())
+		if revision.Differs(presentation) {
+			if ms.state.revisions[ID] == nil {
+				ms.state.revisions[ID] = make(map[int64]PresentationRevision)
+			}
+			ms.state.revisions[ID][ms.state.nextID] = revision
+			ms.state.nextID++
+		}
+	}
+
+	presentation.Version++
+	ms.state.presentations[ID] = copyPresentation(*presentation)
+	if ID >= ms.state.nextID {
+		ms.state.nextID = ID + 1
+	}
+	return nil
+}
+
+func (ms *MemoryStore) AddPresentation(ctx context.Context, presentation *Presentation) (int64, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ID := ms.state.nextID
+	ms.state.nextID++
+	presentation.Version = 1
+	ms.state.presentations[ID] = copyPresentation(*presentation)
+	return ID, nil
+}
+
+func (ms *MemoryStore) DeletePresentation(ctx context.Context, ID int64) error {
+	return ms.setPresentationDeleted(ID, true)
+}
+
+func (ms *MemoryStore) UndeletePresentation(ctx context.Context, ID int64) error {
+	return ms.setPresentationDeleted(ID, false)
+}
+
+func (ms *MemoryStore) setPresentationDeleted(ID int64, deleted bool) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	presentation, ok := ms.state.presentations[ID]
+	if !ok || presentation.Deleted == deleted {
+		return datastore.ErrNoSuchEntity
+	}
+	presentation.Deleted = deleted
+	presentation.Version++
+	presentation.DeletedAt = time.Time{}
+	if deleted {
+		presentation.DeletedAt = time.Now()
+	}
+	ms.state.presentations[ID] = presentation
+	return nil
+}
+
+func (ms *MemoryStore) PurgePresentation(ctx context.Context, ID int64) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.state.presentations, ID)
+	delete(ms.state.revisions, ID)
+	delete(ms.state.reviews, ID)
+	return nil
+}
+
+func (ms *MemoryStore) GetReview(ctx context.Context, presentationID int64, reviewer string) (Review, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	review, ok := ms.state.reviews[presentationID][reviewer]
+	if !ok {
+		return Review{}, datastore.ErrNoSuchEntity
+	}
+	return review, nil
+}
+
+func (ms *MemoryStore) GetReviews(ctx context.Context, presentationID int64) ([]Review, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	reviewers := make([]string, 0, len(ms.state.reviews[presentationID]))
+	for reviewer := range ms.state.reviews[presentationID] {
+		reviewers = append(reviewers, reviewer)
+	}
+	sort.Strings(reviewers)
+	reviews := make([]Review, 0, len(reviewers))
+	for _, reviewer := range reviewers {
+		reviews = append(reviews, ms.state.reviews[presentationID][reviewer])
+	}
+	return reviews, nil
+}
+
+func (ms *MemoryStore) GetAllReviews(ctx context.Context) ([]int64, []Review, error) {
+	ms.mutex.Lock()
+	presentationIDs := make([]int64, 0, len(ms.state.reviews))
+	for presentationID := range ms.state.reviews {
+		presentationIDs = append(presentationIDs, presentationID)
+	}
+	ms.mutex.Unlock()
+	sort.Slice(presentationIDs, func(i, j int) bool { return presentationIDs[i] < presentationIDs[j] })
+
+	IDs := make([]int64, 0)
+	reviews := make([]Review, 0)
+	for _, presentationID := range presentationIDs {
+		presentationReviews, _ := ms.GetReviews(ctx, presentationID)
+		for _, review := range presentationReviews {
+			IDs = append(IDs, presentationID)
+			reviews = append(reviews, review)
+		}
+	}
+	return IDs, reviews, nil
+}
+
+func (ms *MemoryStore) PutReview(ctx context.Context, presentationID int64, review *Review) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if ms.state.reviews[presentationID] == nil {
+		ms.state.reviews[presentationID] = make(map[string]Review)
+	}
+	ms.state.reviews[presentationID][review.Reviewer] = *review
+	return nil
+}
+
+func (ms *MemoryStore) GetPresentationRevisions(ctx context.Context, presentationID int64) ([]int64, []PresentationRevision, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	IDs := make([]int64, 0, len(ms.state.revisions[presentationID]))
+	for ID := range ms.state.revisions[presentationID] {
+		IDs = append(IDs, ID)
+	}
+	revisions := make([]PresentationRevision, 0, len(IDs))
+	for _, ID := range sortedIDs(IDs) {
+		revisions = append(revisions, ms.state.revisions[presentationID][ID])
+	}
+	return IDs, revisions, nil
+}
+
+func (ms *MemoryStore) GetPresentationRevision(ctx context.Context, presentationID int64, ID int64) (PresentationRevision, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	revision, ok := ms.state.revisions[presentationID][ID]
+	if !ok {
+		return PresentationRevision{}, datastore.ErrNoSuchEntity
+	}
+	return revision, nil
+}
+
+func (ms *MemoryStore) PutPresentationRevision(ctx context.Context, presentationID int64, ID int64, revision *PresentationRevision) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if ms.state.revisions[presentationID] == nil {
+		ms.state.revisions[presentationID] = make(map[int64]PresentationRevision)
+	}
+	ms.state.revisions[presentationID][ID] = *revision
+	if ID >= ms.state.nextID {
+		ms.state.nextID = ID + 1
+	}
+	return nil
+}
+
+// All the kinds share the IDs, so reserving only moves the next one past them.
+func (ms *MemoryStore) ReserveIDs(ctx context.Context, kind string, IDs []int64) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	for _, ID := range IDs {
+		if ID >= ms.state.nextID {
+			ms.state.nextID = ID + 1
+		}
+	}
+	return nil
+}
+
+func copyMeetup(meetup Meetup) Meetup {
+	meetup.Presentations = append([]int64(nil), meetup.Presentations...)
+	meetup.CallForPapers.Questions = append([]string(nil), meetup.CallForPapers.Questions...)
+	meetup.Agenda = append([]AgendaSlot(nil), meetup.Agenda...)
+	return meetup
+}
+
+func (ms *MemoryStore) GetMeetup(ctx context.Context, ID int64) (Meetup, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	meetup, ok := ms.state.meetups[ID]
+	if !ok || meetup.Deleted {
+		return Meetup{}, datastore.ErrNoSuchEntity
+	}
+	return copyMeetup(meetup), nil
+}
+
+func (ms *MemoryStore) GetAllMeetups(ctx context.Context) ([]int64, []Meetup, error) {
+	return ms.getMeetups(false)
+}
+
+func (ms *MemoryStore) GetDeletedMeetups(ctx context.Context) ([]int64, []Meetup, error) {
+	return ms.getMeetups(true)
+}
+
+func (ms *MemoryStore) getMeetups(deleted bool) ([]int64, []Meetup, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	IDs := make([]int64, 0, len(ms.state.meetups))
+	for ID, meetup := range ms.state.meetups {
+		if meetup.Deleted == deleted {
+			IDs = append(IDs, ID)
+		}
+	}
+	meetups := make([]Meetup, 0, len(IDs))
+	for _, ID := range sortedIDs(IDs) {
+		meetups = append(meetups, copyMeetup(ms.state.meetups[ID]))
+	}
+	return IDs, meetups, nil
+}
+
+func (ms *MemoryStore) PutMeetup(ctx context.Context, ID int64, meetup *Meetup) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if stored, ok := ms.state.meetups[ID]; ok && stored.Version != meetup.Version {
+		return ErrVersionConflict
+	}
+	meetup.Version++
+	ms.state.meetups[ID] = copyMeetup(*meetup)
+	if ID >= ms.state.nextID {
+		ms.state.nextID = ID + 1
+	}
+	return nil
+}
+
+func (ms *MemoryStore) AddMeetup(ctx context.Context, meetup *Meetup) (int64, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ID := ms.state.nextID
+	ms.state.nextID++
+	meetup.Version = 1
+	ms.state.meetups[ID] = copyMeetup(*meetup)
+	return ID, nil
+}
+
+func (ms *MemoryStore) DeleteMeetup(ctx context.Context, ID int64) error {
+	return ms.setMeetupDeleted(ID, true)
+}
+
+func (ms *MemoryStore) UndeleteMeetup(ctx context.Context, ID int64) error {
+	return ms.setMeetupDeleted(ID, false)
+}
+
+func (ms *MemoryStore) setMeetupDeleted(ID int64, deleted bool) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	meetup, ok := ms.state.meetups[ID]
+	if !ok || meetup.Deleted == deleted {
+		return datastore.ErrNoSuchEntity
+	}
+	meetup.Deleted = deleted
+	meetup.Version++
+	meetup.DeletedAt = time.Time{}
+	if deleted {
+		meetup.DeletedAt = time.Now()
+	}
+	ms.state.meetups[ID] = meetup
+	return nil
+}
+
+func (ms *MemoryStore) PurgeMeetup(ctx context.Context, ID int64) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.state.meetups, ID)
+	delete(ms.state.ballots, ID)
+	return nil
+}
+
+func copyBallot(ballot Ballot) Ballot {
+	ballot.Choices = append([]BallotChoice(nil), ballot.Choices...)
+	return ballot
+}
+
+func (ms *MemoryStore) GetBallot(ctx context.Context, meetupID int64, voter string) (Ballot, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ballot, ok := ms.state.ballots[meetupID][voter]
+	if !ok {
+		return Ballot{}, datastore.ErrNoSuchEntity
+	}
+	return copyBallot(ballot), nil
+}
+
+func (ms *MemoryStore) GetBallots(ctx context.Context, meetupID int64) ([]Ballot, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	voters := make([]string, 0, len(ms.state.ballots[meetupID]))
+	for voter := range ms.state.ballots[meetupID] {
+		voters = append(voters, voter)
+	}
+	sort.Strings(voters)
+	ballots := make([]Ballot, 0, len(voters))
+	for _, voter := range voters {
+		ballots = append(ballots, copyBallot(ms.state.ballots[meetupID][voter]))
+	}
+	return ballots, nil
+}
+
+func (ms *MemoryStore) PutBallot(ctx context.Context, meetupID int64, ballot *Ballot) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if ms.state.ballots[meetupID] == nil {
+		ms.state.ballots[meetupID] = make(map[string]Ballot)
+	}
+	ms.state.ballots[meetupID][ballot.Voter] = copyBallot(*ballot)
+	return nil
+}
+
+func (ms *MemoryStore) DeleteBallot(ctx context.Context, meetupID int64, voter string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.state.ballots[meetupID], voter)
+	return nil
+}
+
+func (ms *MemoryStore) AddVoteEvent(ctx context.Context, event *VoteEvent) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.state.voteEvents = append(ms.state.voteEvents, *event)
+	return nil
+}
+
+func (ms *MemoryStore) GetVoteEvents(ctx context.Context, since time.Time) ([]VoteEvent, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	events := make([]VoteEvent, 0)
+	for _, event := range ms.state.voteEvents {
+		if !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+func (ms *MemoryStore) DeleteVoteEvents(ctx context.Context, before time.Time) (int, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	kept := make([]VoteEvent, 0, len(ms.state.voteEvents))
+	for _, event := range ms.state.voteEvents {
+		if !event.Time.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	deleted := len(ms.state.voteEvents) - len(kept)
+	ms.state.voteEvents = kept
+	return deleted, nil
+}
+
+func (ms *MemoryStore) BlockVoter(ctx context.Context, voter string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.state.blocked[voter] = true
+	return nil
+}
+
+func (ms *MemoryStore) UnblockVoter(ctx context.Context, voter string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	if !ms.state.blocked[voter] {
+		return datastore.ErrNoSuchEntity
+	}
+	delete(ms.state.blocked, voter)
+	return nil
+}
+
+func (ms *MemoryStore) IsVoterBlocked(ctx context.Context, voter string) (bool, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	return ms.state.blocked[voter], nil
+}
+
+func (ms *MemoryStore) GetBlockedVoters(ctx context.Context) ([]string, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	voters := make([]string, 0, len(ms.state.blocked))
+	for voter := range ms.state.blocked {
+		voters = append(voters, voter)
+	}
+	sort.Strings(voters)
+	return voters, nil
+}
+
+func (ms *MemoryStore) GetData(ctx context.Context, key string) (string, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	value, ok := ms.state.metadata[key]
+	if !ok {
+		return "", datastore.ErrNoSuchEntity
+	}
+	return value, nil
+}
+
+func (ms *MemoryStore) PutData(ctx context.Context, key string, value string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.state.metadata[key] = value
+	return nil
+}
+
+func (ms *MemoryStore) DeleteData(ctx context.Context, key string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.state.metadata, key)
+	return nil
+}
+
+func (ms *MemoryStore) GetAllData(ctx context.Context) ([]string, []string, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	keys := make([]string, 0, len(ms.state.metadata))
+	for key := range ms.state.metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, ms.state.metadata[key])
+	}
+	return keys, values, nil
+}
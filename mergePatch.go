@@ -0,0 +1,87 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+var errUnsupportedPatchType = errors.New("PATCH requests have to be sent as application/merge-patch+json")
+
+// Read the update sent in the request into form, which holds the current state of the entity.
+// PUT replaces the whole form, any other method is treated as a JSON merge patch (RFC 7396):
+// omitted fields stay untouched and fields set to null are cleared.
+func decodeUpdate(r *http.Request, form interface{}) error {
+	if r.Method == "PATCH" {
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != mergePatchContentType && contentType != "application/json" {
+			return errUnsupportedPatchType
+		}
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	if r.Method == "PUT" {
+		value := reflect.ValueOf(form).Elem()
+		value.Set(reflect.Zero(value.Type()))
+		return json.Unmarshal(body, form)
+	}
+
+	return mergePatch(form, body)
+}
+
+// Apply the merge patch to the JSON representation of target.
+func mergePatch(target interface{}, patch []byte) error {
+	var patchDocument interface{}
+	err := json.Unmarshal(patch, &patchDocument)
+	if err != nil {
+		return err
+	}
+
+	current, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var document interface{}
+	err = json.Unmarshal(current, &document)
+	if err != nil {
+		return err
+	}
+
+	patched, err := json.Marshal(mergeValue(document, patchDocument))
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(target).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	return json.Unmarshal(patched, target)
+}
+
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeValue(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,19 @@
+package MeetupRest
+
+import (
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	form := SpeakerForm{Name: "Jan", Surname: "Kowalski", About: "Gopher", Email: "jan@example.com", Company: "Company"}
+
+	err := mergePatch(&form, []byte(`{"Surname": "Nowak", "Company": null}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := SpeakerForm{Name: "Jan", Surname: "Nowak", About: "Gopher", Email: "jan@example.com"}
+	if form != expected {
+		t.Errorf("Omitted fields should stay and null fields should be cleared. Received: %+v", form)
+	}
+}
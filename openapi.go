@@ -0,0 +1,387 @@
+package MeetupRest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// A documented route. Every route registered on the router has to have one, see TestOpenAPICoversRoutes.
+type apiOperation struct {
+	Method  string
+	Path    string
+	Tag     string
+	Summary string
+	// Zero value of the JSON request body, nil if there is none.
+	Request interface{}
+	// Zero value of the JSON response body, nil if the response is plain text.
+	Response interface{}
+	// Content type of responses which aren't JSON, text/plain if not set.
+	ContentType string
+	// The response is encoded for the Accept header, in any of the registered encodings.
+	Negotiated bool
+	// Status of a successful response, 200 if not set.
+	Status int
+	Query  []apiParameter
+	Errors []int
+	// Only served when the legacy routes are enabled.
+	Legacy bool
+}
+
+type apiParameter struct {
+	Name        string
+	Description string
+}
+
+var cascadeParameter = apiParameter{"cascade", "Set to true to also remove the references to the entity (admin only)."}
+var diffParameters = []apiParameter{
+	{"from", "Key of the older revision, 0 or omitted for the current version."},
+	{"to", "Key of the newer revision, 0 or omitted for the current version."},
+}
+
+const streamSummary = "Push vote counts and presentation changes as server-sent events named votes, added, updated, deleted and reload, each carrying a PresentationEvent. Polling the list with If-None-Match is the fallback where responses are buffered."
+
+const exportSummary = "Export the presentations with their votes as CSV, most voted first (admins, or the owner of the exported meetup). Drafts are left out."
+
+var exportParameter = apiParameter{"meetup", "Key of a meetup, to only export its presentations."}
+
+const statusSummary = "Move a presentation to another status of the review workflow. Speakers submit and withdraw, the organizers of its meetup review, accept, reject and schedule."
+
+var statusParameter = apiParameter{"status", "Comma separated statuses, to only list the presentations in one of them."}
+var submittedToParameter = apiParameter{"meetup", "Key of a meetup, to only list the presentations submitted to its call for papers."}
+var draftParameter = apiParameter{"draft", "Set to true to save the presentation as a draft instead of submitting it."}
+
+const searchSummary = "Search the speakers, presentations and meetups, best matches first, with the matches highlighted."
+
+const autoScheduleSummary = "Replace the agenda with all the presentations of the meetup back to back from its start, most voted first (owner or admin)."
+
+var autoScheduleParameters = []apiParameter{
+	{"minutes", "Length of every presentation slot, 30 if omitted."},
+	{"breakEvery", "Add a break after this many presentations, no breaks if omitted."},
+}
+
+var suspiciousParameter = apiParameter{"hours", "Report the votes of this many last hours, 24 if omitted, at most 720."}
+
+var searchParameters = []apiParameter{
+	{"q", "Words to search for, all of them have to match."},
+	{"kind", "Only return results of this kind: speaker, presentation or meetup."},
+	{"limit", "Maximum number of results, 20 if omitted, at most 100."},
+}
+
+var (
+	exportErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError}
+	getErrors    = []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError}
+	statusErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusInternalServerError}
+	reviewErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError}
+	agendaErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity, http.StatusInternalServerError}
+	ballotErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError}
+	searchErrors = []int{http.StatusBadRequest, http.StatusInternalServerError}
+	addErrors    = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusUnprocessableEntity, http.StatusInternalServerError}
+	updateErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusInternalServerError}
+	deleteErrors = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusInternalServerError}
+	voteErrors   = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusTooManyRequests, http.StatusInternalServerError}
+	adminErrors  = []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError}
+)
+
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/speaker/{ID}/", Tag: "speakers", Summary: "Get a speaker.", Response: SpeakerPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "POST", Path: "/speaker/", Tag: "speakers", Summary: "Add a speaker, responds with its key.", Request: SpeakerForm{}, Status: http.StatusCreated, Errors: addErrors, Legacy: true},
+	{Method: "GET", Path: "/speaker/list", Tag: "speakers", Summary: "List the speakers.", Response: []SpeakerPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "POST", Path: "/speaker/{ID}/update", Tag: "speakers", Summary: "Update a speaker with a JSON merge patch.", Request: SpeakerForm{}, Status: http.StatusCreated, Errors: updateErrors, Legacy: true},
+	{Method: "GET", Path: "/speaker/{ID}/delete", Tag: "speakers", Summary: "Move a speaker to the trash.", Query: []apiParameter{cascadeParameter}, Status: http.StatusTeapot, Errors: deleteErrors, Legacy: true},
+	{Method: "GET", Path: "/speaker/form/update", Tag: "speakers", Summary: "HTML form for updating a speaker.", ContentType: "text/html", Legacy: true},
+
+	{Method: "GET", Path: "/presentation/{ID}/", Tag: "presentations", Summary: "Get a presentation.", Response: PresentationPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "POST", Path: "/presentation/", Tag: "presentations", Summary: "Add a presentation, responds with its key.", Request: PresentationForm{}, Query: []apiParameter{draftParameter}, Status: http.StatusCreated, Errors: addErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/{ID}/delete", Tag: "presentations", Summary: "Move a presentation to the trash.", Query: []apiParameter{cascadeParameter}, Status: http.StatusTeapot, Errors: deleteErrors, Legacy: true},
+	{Method: "POST", Path: "/presentation/{ID}/update", Tag: "presentations", Summary: "Update a presentation with a JSON merge patch.", Request: PresentationForm{}, Status: http.StatusCreated, Errors: updateErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/list", Tag: "presentations", Summary: "List the presentations.", Response: []PresentationPublicView{}, Query: []apiParameter{statusParameter, submittedToParameter}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/export.csv", Tag: "presentations", Summary: exportSummary, Query: []apiParameter{exportParameter}, ContentType: "text/csv", Errors: exportErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/stream", Tag: "presentations", Summary: streamSummary, ContentType: "text/event-stream", Errors: []int{http.StatusNotImplemented}, Legacy: true},
+	{Method: "GET", Path: "/presentation/{ID}/upvote", Tag: "votes", Summary: "Upvote a presentation.", Errors: voteErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/{ID}/downvote", Tag: "votes", Summary: "Withdraw the upvote of a presentation.", Errors: voteErrors, Legacy: true},
+	{Method: "POST", Path: "/presentation/{ID}/status", Tag: "presentations", Summary: statusSummary, Request: PresentationStatusForm{}, Errors: statusErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/{ID}/hasUpvoted", Tag: "votes", Summary: "Check whether the user upvoted the presentation, responds with true or false.", Errors: getErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/{ID}/revisions", Tag: "revisions", Summary: "List the revisions of a presentation, newest first.", Response: []PresentationRevisionPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/{ID}/revisions/diff", Tag: "revisions", Summary: "Diff two revisions of a presentation.", Response: PresentationRevisionDiff{}, Query: diffParameters, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "GET", Path: "/presentation/{ID}/revisions/{Revision}/", Tag: "revisions", Summary: "Get a revision of a presentation.", Response: PresentationRevisionPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "POST", Path: "/presentation/{ID}/revisions/{Revision}/restore", Tag: "revisions", Summary: "Restore a presentation to a revision.", Status: http.StatusCreated, Errors: updateErrors, Legacy: true},
+
+	{Method: "GET", Path: "/meetup/{ID}/", Tag: "meetups", Summary: "Get a meetup.", Response: MeetupPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "POST", Path: "/meetup/", Tag: "meetups", Summary: "Add a meetup, responds with its key.", Request: MeetupForm{}, Status: http.StatusCreated, Errors: addErrors, Legacy: true},
+	{Method: "GET", Path: "/meetup/{ID}/delete", Tag: "meetups", Summary: "Move a meetup to the trash.", Status: http.StatusTeapot, Errors: deleteErrors, Legacy: true},
+	{Method: "POST", Path: "/meetup/{ID}/update", Tag: "meetups", Summary: "Update a meetup with a JSON merge patch.", Request: MeetupForm{}, Status: http.StatusCreated, Errors: updateErrors, Legacy: true},
+	{Method: "GET", Path: "/meetup/list", Tag: "meetups", Summary: "List the meetups.", Response: []MeetupPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+	{Method: "GET", Path: "/meetup/{ID}/agenda", Tag: "agenda", Summary: "Get the agenda of a meetup.", Response: []AgendaSlotPublicView{}, Negotiated: true, Errors: getErrors, Legacy: true},
+
+	{Method: "GET", Path: "/metadata/{key}/", Tag: "metadata", Summary: "Get a metadata value (admin only).", Errors: adminErrors, Legacy: true},
+	{Method: "POST", Path: "/metadata/{key}/", Tag: "metadata", Summary: "Set a metadata value (admin only).", Query: []apiParameter{{"data", "The value to set."}}, Errors: adminErrors, Legacy: true},
+
+	{Method: "GET", Path: "/search", Tag: "search", Summary: searchSummary, Response: []SearchResult{}, Query: searchParameters, Negotiated: true, Errors: searchErrors},
+
+	{Method: "GET", Path: "/trash/", Tag: "trash", Summary: "List the deleted entities (admin only).", Response: []TrashItemPublicView{}, Negotiated: true, Errors: adminErrors},
+	{Method: "POST", Path: "/trash/{Kind}/{ID}/undelete", Tag: "trash", Summary: "Restore a deleted speaker, presentation or meetup (admin only). Fails with 409 if it references entities which are gone.", Errors: append([]int{http.StatusNotFound, http.StatusConflict}, adminErrors...)},
+	{Method: "GET", Path: "/trash/purge", Tag: "trash", Summary: "Purge the entities deleted longer than the retention period (cron or admin).", Errors: adminErrors},
+
+	{Method: "GET", Path: "/votes/prune", Tag: "votes", Summary: "Delete the vote events older than the longest suspicious votes report (cron or admin).", Errors: adminErrors},
+
+	{Method: "GET", Path: "/selection/run", Tag: "meetups", Summary: "Select the most voted presentations of the meetups whose voting closed, notify their speakers and sync meetup.com (cron or admin).", Errors: adminErrors},
+
+	{Method: "GET", Path: "/api/v1/speakers", Tag: "speakers", Summary: "List the speakers.", Response: []SpeakerPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "POST", Path: "/api/v1/speakers", Tag: "speakers", Summary: "Add a speaker, responds with its key.", Request: SpeakerForm{}, Status: http.StatusCreated, Errors: addErrors},
+	{Method: "GET", Path: "/api/v1/speakers/{ID}", Tag: "speakers", Summary: "Get a speaker.", Response: SpeakerPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "PUT", Path: "/api/v1/speakers/{ID}", Tag: "speakers", Summary: "Replace a speaker.", Request: SpeakerForm{}, Status: http.StatusCreated, Errors: updateErrors},
+	{Method: "PATCH", Path: "/api/v1/speakers/{ID}", Tag: "speakers", Summary: "Update a speaker with a JSON merge patch.", Request: SpeakerForm{}, Status: http.StatusCreated, Errors: updateErrors},
+	{Method: "DELETE", Path: "/api/v1/speakers/{ID}", Tag: "speakers", Summary: "Move a speaker to the trash.", Query: []apiParameter{cascadeParameter}, Status: http.StatusTeapot, Errors: deleteErrors},
+
+	{Method: "GET", Path: "/api/v1/presentations", Tag: "presentations", Summary: "List the presentations.", Response: []PresentationPublicView{}, Query: []apiParameter{statusParameter, submittedToParameter}, Negotiated: true, Errors: getErrors},
+	{Method: "POST", Path: "/api/v1/presentations", Tag: "presentations", Summary: "Add a presentation, responds with its key.", Request: PresentationForm{}, Query: []apiParameter{draftParameter}, Status: http.StatusCreated, Errors: addErrors},
+	{Method: "GET", Path: "/api/v1/presentations/export.csv", Tag: "presentations", Summary: exportSummary, Query: []apiParameter{exportParameter}, ContentType: "text/csv", Errors: exportErrors},
+	{Method: "GET", Path: "/api/v1/presentations/stream", Tag: "presentations", Summary: streamSummary, ContentType: "text/event-stream", Errors: []int{http.StatusNotImplemented}},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}", Tag: "presentations", Summary: "Get a presentation.", Response: PresentationPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "PUT", Path: "/api/v1/presentations/{ID}", Tag: "presentations", Summary: "Replace a presentation.", Request: PresentationForm{}, Status: http.StatusCreated, Errors: updateErrors},
+	{Method: "PATCH", Path: "/api/v1/presentations/{ID}", Tag: "presentations", Summary: "Update a presentation with a JSON merge patch.", Request: PresentationForm{}, Status: http.StatusCreated, Errors: updateErrors},
+	{Method: "DELETE", Path: "/api/v1/presentations/{ID}", Tag: "presentations", Summary: "Move a presentation to the trash.", Query: []apiParameter{cascadeParameter}, Status: http.StatusTeapot, Errors: deleteErrors},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}/vote", Tag: "votes", Summary: "Check whether the user upvoted the presentation, responds with true or false.", Errors: getErrors},
+	{Method: "PUT", Path: "/api/v1/presentations/{ID}/vote", Tag: "votes", Summary: "Upvote a presentation.", Errors: voteErrors},
+	{Method: "DELETE", Path: "/api/v1/presentations/{ID}/vote", Tag: "votes", Summary: "Withdraw the upvote of a presentation.", Errors: voteErrors},
+	{Method: "PUT", Path: "/api/v1/presentations/{ID}/status", Tag: "presentations", Summary: statusSummary, Request: PresentationStatusForm{}, Errors: statusErrors},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}/votes", Tag: "votes", Summary: "List the anonymized IDs of the voters (admin only).", Response: []string{}, Negotiated: true, Errors: append([]int{http.StatusBadRequest, http.StatusNotFound}, adminErrors...)},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}/review", Tag: "reviews", Summary: "Get the review the current reviewer gave the presentation.", Response: ReviewPublicView{}, Negotiated: true, Errors: reviewErrors},
+	{Method: "PUT", Path: "/api/v1/presentations/{ID}/review", Tag: "reviews", Summary: "Score the presentation as the current reviewer, replacing an earlier review.", Request: ReviewForm{}, Errors: append([]int{http.StatusConflict, http.StatusUnprocessableEntity}, reviewErrors...)},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}/reviews", Tag: "reviews", Summary: "List the reviews of the presentation with its mean scores (reviewers and admins).", Response: PresentationReviewsPublicView{}, Negotiated: true, Errors: reviewErrors},
+	{Method: "GET", Path: "/api/v1/reviews/queue", Tag: "reviews", Summary: "List the presentations in review the current reviewer hasn't scored yet. Speakers and answers are hidden in blind review.", Response: []ReviewQueueItem{}, Negotiated: true, Errors: adminErrors},
+	{Method: "GET", Path: "/api/v1/reviews/scores", Tag: "reviews", Summary: "List the mean scores of the reviewed presentations, best first (reviewers and admins).", Response: []ReviewScores{}, Negotiated: true, Errors: adminErrors},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}/revisions", Tag: "revisions", Summary: "List the revisions of a presentation, newest first.", Response: []PresentationRevisionPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}/revisions/diff", Tag: "revisions", Summary: "Diff two revisions of a presentation.", Response: PresentationRevisionDiff{}, Query: diffParameters, Negotiated: true, Errors: getErrors},
+	{Method: "GET", Path: "/api/v1/presentations/{ID}/revisions/{Revision}", Tag: "revisions", Summary: "Get a revision of a presentation.", Response: PresentationRevisionPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "POST", Path: "/api/v1/presentations/{ID}/revisions/{Revision}/restore", Tag: "revisions", Summary: "Restore a presentation to a revision.", Status: http.StatusCreated, Errors: updateErrors},
+
+	{Method: "GET", Path: "/api/v1/meetups", Tag: "meetups", Summary: "List the meetups.", Response: []MeetupPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "POST", Path: "/api/v1/meetups", Tag: "meetups", Summary: "Add a meetup, responds with its key.", Request: MeetupForm{}, Status: http.StatusCreated, Errors: addErrors},
+	{Method: "GET", Path: "/api/v1/meetups/{ID}", Tag: "meetups", Summary: "Get a meetup.", Response: MeetupPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "PUT", Path: "/api/v1/meetups/{ID}", Tag: "meetups", Summary: "Replace a meetup.", Request: MeetupForm{}, Status: http.StatusCreated, Errors: updateErrors},
+	{Method: "PATCH", Path: "/api/v1/meetups/{ID}", Tag: "meetups", Summary: "Update a meetup with a JSON merge patch.", Request: MeetupForm{}, Status: http.StatusCreated, Errors: updateErrors},
+	{Method: "DELETE", Path: "/api/v1/meetups/{ID}", Tag: "meetups", Summary: "Move a meetup to the trash.", Status: http.StatusTeapot, Errors: deleteErrors},
+	{Method: "POST", Path: "/api/v1/votes/anonymize", Tag: "votes", Summary: "Replace the voter emails stored before the votes were anonymized by salted hashes (admin only).", Errors: adminErrors},
+	{Method: "GET", Path: "/api/v1/votes/suspicious", Tag: "votes", Summary: "Report voters sharing an address and bursts of votes from new voters (admin only).", Response: SuspiciousVotesReport{}, Negotiated: true, Query: []apiParameter{suspiciousParameter}, Errors: append([]int{http.StatusBadRequest}, adminErrors...)},
+	{Method: "POST", Path: "/api/v1/votes/invalidate", Tag: "votes", Summary: "Remove the upvotes and ballots of voters, given by ID or email, and optionally block them from voting (admin only).", Request: InvalidateVotesForm{}, Errors: append([]int{http.StatusBadRequest, http.StatusUnprocessableEntity}, adminErrors...)},
+	{Method: "GET", Path: "/api/v1/votes/blocked", Tag: "votes", Summary: "List the IDs of the blocked voters (admin only).", Response: []string{}, Negotiated: true, Errors: adminErrors},
+	{Method: "DELETE", Path: "/api/v1/votes/blocked/{Voter}", Tag: "votes", Summary: "Let a blocked voter vote again (admin only).", Errors: append([]int{http.StatusNotFound}, adminErrors...)},
+	{Method: "GET", Path: "/api/v1/meetups/{ID}/ballot", Tag: "votes", Summary: "Get the ballot the user cast in the meetup.", Response: BallotPublicView{}, Negotiated: true, Errors: append([]int{http.StatusUnauthorized}, getErrors...)},
+	{Method: "DELETE", Path: "/api/v1/meetups/{ID}/ballot", Tag: "votes", Summary: "Withdraw the ballot the user cast in the meetup.", Errors: ballotErrors},
+	{Method: "PUT", Path: "/api/v1/meetups/{ID}/ballot/limited", Tag: "votes", Summary: "Vote for at most VotesPerVoter presentations of a meetup with limited voting, replacing an earlier ballot.", Request: LimitedBallotForm{}, Errors: ballotErrors},
+	{Method: "PUT", Path: "/api/v1/meetups/{ID}/ballot/budget", Tag: "votes", Summary: "Spread at most VotesPerVoter points over the presentations of a meetup with budget voting, replacing an earlier ballot.", Request: BudgetBallotForm{}, Errors: ballotErrors},
+	{Method: "PUT", Path: "/api/v1/meetups/{ID}/ballot/ranked", Tag: "votes", Summary: "Rank the presentations of a meetup with ranked choice voting, most preferred first, replacing an earlier ballot.", Request: RankedBallotForm{}, Errors: ballotErrors},
+	{Method: "GET", Path: "/api/v1/meetups/{ID}/results", Tag: "votes", Summary: "Tally the votes of a meetup in its voting mode, best first, with the method used.", Response: VotingResults{}, Negotiated: true, Errors: getErrors},
+	{Method: "GET", Path: "/api/v1/meetups/{ID}/agenda", Tag: "agenda", Summary: "Get the agenda of a meetup.", Response: []AgendaSlotPublicView{}, Negotiated: true, Errors: getErrors},
+	{Method: "PUT", Path: "/api/v1/meetups/{ID}/agenda", Tag: "agenda", Summary: "Replace the agenda of a meetup. Slots may not overlap and only schedule presentations of the meetup (owner or admin).", Request: []AgendaSlot{}, Response: []AgendaSlotPublicView{}, Negotiated: true, Errors: agendaErrors},
+	{Method: "POST", Path: "/api/v1/meetups/{ID}/agenda/auto", Tag: "agenda", Summary: autoScheduleSummary, Response: []AgendaSlotPublicView{}, Query: autoScheduleParameters, Negotiated: true, Errors: agendaErrors},
+
+	{Method: "POST", Path: "/api/v1/sync", Tag: "meetups", Summary: "Push the meetups to meetup.com (admin only).", Errors: adminErrors},
+
+	{Method: "GET", Path: "/api/v1/metadata/{key}", Tag: "metadata", Summary: "Get a metadata value (admin only).", Errors: adminErrors},
+	{Method: "PUT", Path: "/api/v1/metadata/{key}", Tag: "metadata", Summary: "Set a metadata value (admin only).", Query: []apiParameter{{"data", "The value to set."}}, Errors: adminErrors},
+
+	{Method: "GET", Path: "/api/v1/trash", Tag: "trash", Summary: "List the deleted entities (admin only).", Response: []TrashItemPublicView{}, Negotiated: true, Errors: adminErrors},
+	{Method: "POST", Path: "/api/v1/trash/{Kind}/{ID}/undelete", Tag: "trash", Summary: "Restore a deleted speaker, presentation or meetup (admin only). Fails with 409 if it references entities which are gone.", Errors: append([]int{http.StatusNotFound, http.StatusConflict}, adminErrors...)},
+
+	{Method: "GET", Path: "/api/v1/export", Tag: "archive", Summary: "Export all the data as a JSON archive (admin only).", Response: Archive{}, Errors: adminErrors},
+	{Method: "POST", Path: "/api/v1/import", Tag: "archive", Summary: "Import a JSON archive, keeping the keys (admin only).", Request: Archive{}, Response: ImportResult{}, Query: []apiParameter{{"conflict", "What to do with entities which already exist: fail (default), skip or overwrite."}}, Errors: append([]int{http.StatusBadRequest, http.StatusConflict}, adminErrors...)},
+
+	{Method: "GET", Path: "/api/v1/search", Tag: "search", Summary: searchSummary, Response: []SearchResult{}, Query: searchParameters, Negotiated: true, Errors: searchErrors},
+	{Method: "POST", Path: "/api/v1/search/reindex", Tag: "search", Summary: "Rebuild the search index from the stored entities (admin only).", Errors: adminErrors},
+
+	{Method: "GET", Path: "/api/openapi.json", Tag: "meta", Summary: "This document."},
+	{Method: "GET", Path: "/isLoggedIn", Tag: "users", Summary: "Check whether the user is logged in, responds with true or false."},
+	{Method: "GET", Path: "/getLoginAddress", Tag: "users", Summary: "Get the address of the login page.", Query: []apiParameter{{"url", "Where to redirect after logging in."}}, Errors: []int{http.StatusBadRequest}},
+}
+
+// Register the route serving the OpenAPI document of the application.
+func RegisterOpenAPIRoutes(m *mux.Router, legacyRoutes bool) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering OpenAPI routes")
+	}
+	spec, err := json.Marshal(openAPISpec(legacyRoutes))
+	if err != nil {
+		return err
+	}
+	m.HandleFunc("/api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}).Methods("GET")
+
+	return nil
+}
+
+var pathParameterRegexp = regexp.MustCompile(`{([^}]+)}`)
+
+// Build the OpenAPI 3 document from apiOperations.
+func openAPISpec(legacyRoutes bool) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	errorSchema := schemaOf(reflect.TypeOf(ErrorResponse{}), schemas)
+
+	paths := make(map[string]map[string]interface{})
+	for _, operation := range apiOperations {
+		if operation.Legacy && !legacyRoutes {
+			continue
+		}
+
+		parameters := make([]interface{}, 0)
+		for _, match := range pathParameterRegexp.FindAllStringSubmatch(operation.Path, -1) {
+			schema := map[string]interface{}{"type": "string"}
+			if match[1] == "ID" || match[1] == "Revision" {
+				schema = map[string]interface{}{"type": "integer", "format": "int64"}
+			}
+			parameters = append(parameters, map[string]interface{}{"name": match[1], "in": "path", "required": true, "schema": schema})
+		}
+		for _, parameter := range operation.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        parameter.Name,
+				"in":          "query",
+				"description": parameter.Description,
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+
+		status := operation.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]interface{}{"description": http.StatusText(status)}
+		if operation.Response != nil && operation.Negotiated {
+			schema := schemaOf(reflect.TypeOf(operation.Response), schemas)
+			content := make(map[string]interface{})
+			for _, encoder := range encoders {
+				content[encoder.MediaTypes()[0]] = map[string]interface{}{"schema": schema}
+			}
+			success["content"] = content
+		} else if operation.Response != nil {
+			success["content"] = jsonContent(schemaOf(reflect.TypeOf(operation.Response), schemas))
+		} else {
+			contentType := operation.ContentType
+			if contentType == "" {
+				contentType = "text/plain"
+			}
+			success["content"] = map[string]interface{}{contentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+		}
+		responses := map[string]interface{}{strconv.Itoa(status): success}
+		errorCodes := operation.Errors
+		if operation.Negotiated {
+			errorCodes = append([]int{http.StatusNotAcceptable}, errorCodes...)
+		}
+		for _, code := range errorCodes {
+			responses[strconv.Itoa(code)] = map[string]interface{}{
+				"description": http.StatusText(code),
+				"content":     jsonContent(errorSchema),
+			}
+		}
+
+		item := map[string]interface{}{
+			"tags":       []string{operation.Tag},
+			"summary":    operation.Summary,
+			"parameters": parameters,
+			"responses":  responses,
+		}
+		if operation.Request != nil {
+			content := jsonContent(schemaOf(reflect.TypeOf(operation.Request), schemas))
+			if operation.Method == "PATCH" || strings.HasSuffix(operation.Path, "/update") {
+				content[mergePatchContentType] = content["application/json"]
+			}
+			item["requestBody"] = map[string]interface{}{"required": true, "content": content}
+		}
+
+		if paths[operation.Path] == nil {
+			paths[operation.Path] = make(map[string]interface{})
+		}
+		paths[operation.Path][strings.ToLower(operation.Method)] = item
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "MeetupRest",
+			"version": "1",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// Describe the JSON encoding of t. Named structs are put into schemas and referenced.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		if t == reflect.TypeOf(StatusDraft) {
+			return map[string]interface{}{"type": "string", "enum": presentationStatuses}
+		}
+		if t == reflect.TypeOf(VotingApproval) {
+			return map[string]interface{}{"type": "string", "enum": votingModes}
+		}
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		// Placeholder, so recursive types terminate.
+		schemas[t.Name()] = nil
+
+		properties := make(map[string]interface{})
+		addProperties(t, properties, schemas)
+		schemas[t.Name()] = map[string]interface{}{"type": "object", "properties": properties}
+		return ref
+	}
+
+	// Interfaces can hold anything.
+	return map[string]interface{}{}
+}
+
+// Add the JSON properties of the struct. Fields of embedded structs are flattened, like encoding/json does.
+func addProperties(t reflect.Type, properties map[string]interface{}, schemas map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+			addProperties(field.Type, properties, schemas)
+			continue
+		}
+		if field.PkgPath != "" || tag == "-" {
+			continue
+		}
+		name := field.Name
+		if tagName := strings.Split(tag, ",")[0]; tagName != "" {
+			name = tagName
+		}
+		properties[name] = schemaOf(field.Type, schemas)
+	}
+}
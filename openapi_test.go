@@ -0,0 +1,41 @@
+package MeetupRest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	router, err := NewRouter(nil, NewMemorySearchIndex(), NewMemoryRateLimiter(), NewEventBus(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := openAPISpec(true)["paths"].(map[string]map[string]interface{})
+
+	err = router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// Path prefixes are subrouters and static files, not operations.
+		if expression, _ := route.GetPathRegexp(); !strings.HasSuffix(expression, "$") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+
+		for _, method := range methods {
+			if _, ok := paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("%v %v is missing from the OpenAPI document.", method, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -90,8 +90,8 @@ const AddMeetup = React.createClass({
                     }.bind(this),
             error: function(xhr, status, err) {
                         switch (xhr.status) {
-                            case 403:
-                            window.location.href = xhr.responseText
+                            case 401:
+                            window.location.href = xhr.responseJSON.login_url
                             //this.context.router.push(xhr.responseText);
                             break;
                         }
@@ -65,8 +65,8 @@ const AddSpeaker = React.createClass({
                     }.bind(this),
             error: function(xhr, status, err) {
                         switch (xhr.status) {
-                            case 403:
-                            window.location.href = xhr.responseText
+                            case 401:
+                            window.location.href = xhr.responseJSON.login_url
                             //this.context.router.push(xhr.responseText);
                             break;
                         }
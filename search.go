@@ -0,0 +1,446 @@
+package MeetupRest
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/context"
+
+	"github.com/cube2222/MeetupRest/api"
+	"github.com/gorilla/mux"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/user"
+)
+
+const (
+	searchKindSpeaker      = "speaker"
+	searchKindPresentation = "presentation"
+	searchKindMeetup       = "meetup"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Runes of context kept around the first match of a highlight.
+const highlightContext = 60
+
+// Longest highlight, in runes.
+const highlightLength = 200
+
+// What the index knows about a speaker, presentation or meetup.
+type SearchDocument struct {
+	Kind   string
+	Key    int64
+	Title  string
+	Fields []SearchField
+}
+
+type SearchField struct {
+	Name string
+	Text string
+	// How much a match in this field counts for the ranking, if the index supports it.
+	Weight float64
+}
+
+type SearchResult = api.SearchResult
+
+// Full-text index over the speakers, presentations and meetups.
+// AppEngineSearchIndex is used in production, MemorySearchIndex when running standalone.
+type SearchIndex interface {
+	IndexDocument(ctx context.Context, document SearchDocument) error
+	RemoveDocument(ctx context.Context, kind string, key int64) error
+	// Find the documents matching all the words of the query, best matches first. Kind is optional.
+	Search(ctx context.Context, query string, kind string, limit int) ([]SearchResult, error)
+	// Remove all the documents.
+	Clear(ctx context.Context) error
+}
+
+func speakerSearchDocument(key int64, speaker *Speaker) SearchDocument {
+	return SearchDocument{
+		Kind:  searchKindSpeaker,
+		Key:   key,
+		Title: speaker.GetSpeakerFullName(),
+		Fields: []SearchField{
+			{Name: "Name", Text: speaker.GetSpeakerFullName(), Weight: 2},
+			{Name: "Company", Text: speaker.Company, Weight: 1.5},
+			{Name: "About", Text: speaker.About, Weight: 1},
+		},
+	}
+}
+
+func presentationSearchDocument(key int64, presentation *Presentation) SearchDocument {
+	return SearchDocument{
+		Kind:  searchKindPresentation,
+		Key:   key,
+		Title: presentation.Title,
+		Fields: []SearchField{
+			{Name: "Title", Text: presentation.Title, Weight: 3},
+			{Name: "Speakers", Text: strings.Join(presentation.Speakers, ", "), Weight: 2},
+			{Name: "Description", Text: presentation.Description, Weight: 1},
+		},
+	}
+}
+
+func meetupSearchDocument(key int64, meetup *Meetup) SearchDocument {
+	return SearchDocument{
+		Kind:  searchKindMeetup,
+		Key:   key,
+		Title: meetup.Title,
+		Fields: []SearchField{
+			{Name: "Title", Text: meetup.Title, Weight: 3},
+		},
+	}
+}
+
+// Register the search route to the router.
+func RegisterSearchRoutes(m *mux.Router, Index SearchIndex) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering search routes")
+	}
+	h := searchHandler{Index: Index}
+	m.HandleFunc("/search", h.Search).Methods("GET")
+
+	return nil
+}
+
+// Register the search routes of the versioned API to the router.
+func RegisterSearchAPIRoutes(m *mux.Router, Index SearchIndex, SpeakerStorage SpeakerStore, PresentationStorage PresentationStore, MeetupStorage MeetupStore) error {
+	if m == nil {
+		return errors.New("m may not be nil when registering search API routes")
+	}
+	h := searchHandler{Index: Index, SpeakerStorage: SpeakerStorage, PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage}
+	m.HandleFunc("/search", h.Search).Methods("GET")
+	m.HandleFunc("/search/reindex", h.Reindex).Methods("POST")
+
+	return nil
+}
+
+type searchHandler struct {
+	Index               SearchIndex
+	SpeakerStorage      SpeakerStore
+	PresentationStorage PresentationStore
+	MeetupStorage       MeetupStore
+}
+
+func (h *searchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, defaultRequestTimeout)
+	defer done()
+
+	query := r.URL.Query()
+	if len(searchTerms(query.Get("q"))) == 0 {
+		writeError(w, http.StatusBadRequest, "Parameter q is mandatory.")
+		return
+	}
+
+	kind := query.Get("kind")
+	if kind != "" && kind != searchKindSpeaker && kind != searchKindPresentation && kind != searchKindMeetup {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Kind not valid: %v", kind))
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Limit has to be between 1 and %v: %v", maxSearchLimit, value))
+			return
+		}
+	}
+
+	encoder, ok := negotiateEncoder(w, r)
+	if !ok {
+		return
+	}
+
+	results, err := h.Index.Search(ctx, query.Get("q"), kind, limit)
+	if err != nil {
+		log.Errorf(ctx, "Can't search: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	err = encoder.Encode(w, results)
+	if err != nil {
+		log.Errorf(ctx, "Failed to write search results: %v", err)
+		writeInternalError(w)
+		return
+	}
+}
+
+// Rebuild the index from the storage, after it got out of sync or to fill it the first time.
+func (h *searchHandler) Reindex(w http.ResponseWriter, r *http.Request) {
+	ctx := requestContext(r)
+	ctx, done := context.WithTimeout(ctx, archiveRequestTimeout)
+	defer done()
+
+	u := currentUser(ctx, r)
+	if u == nil {
+		url, _ := user.LoginURL(ctx, fmt.Sprint("/"))
+		writeLoginRequired(w, url)
+		return
+	}
+	if !u.Admin {
+		writeError(w, http.StatusForbidden, "You have to be admin.")
+		return
+	}
+
+	documents, err := h.getSearchDocuments(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't get entities to index: %v", err)
+		writeInternalError(w)
+		return
+	}
+
+	err = h.Index.Clear(ctx)
+	if err != nil {
+		log.Errorf(ctx, "Can't clear search index: %v", err)
+		writeInternalError(w)
+		return
+	}
+	for _, document := range documents {
+		err = h.Index.IndexDocument(ctx, document)
+		if err != nil {
+			log.Errorf(ctx, "Can't index %v %v: %v", document.Kind, document.Key, err)
+			writeInternalError(w)
+			return
+		}
+	}
+
+	fmt.Fprintf(w, "Indexed %v documents.", len(documents))
+}
+
+func (h *searchHandler) getSearchDocuments(ctx context.Context) ([]SearchDocument, error) {
+	documents := make([]SearchDocument, 0)
+
+	speakerIDs, speakers, err := h.SpeakerStorage.GetAllSpeakers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for index := range speakers {
+		documents = append(documents, speakerSearchDocument(speakerIDs[index], &speakers[index]))
+	}
+
+	presentationIDs, presentations, err := h.PresentationStorage.GetAllPresentations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for index := range presentations {
+		if presentations[index].Status == StatusDraft {
+			continue
+		}
+		documents = append(documents, presentationSearchDocument(presentationIDs[index], &presentations[index]))
+	}
+
+	meetupIDs, meetups, err := h.MeetupStorage.GetAllMeetups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for index := range meetups {
+		documents = append(documents, meetupSearchDocument(meetupIDs[index], &meetups[index]))
+	}
+
+	return documents, nil
+}
+
+// A word of a text, lower cased, with its position in bytes.
+type searchToken struct {
+	Word       string
+	Start, End int
+}
+
+// Split the text into words made of letters and digits.
+func tokenize(text string) []searchToken {
+	tokens := make([]searchToken, 0)
+	start := -1
+	for index, r := range text + " " {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWordRune && start == -1 {
+			start = index
+		}
+		if !isWordRune && start != -1 {
+			tokens = append(tokens, searchToken{Word: strings.ToLower(text[start:index]), Start: start, End: index})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// The distinct words of a query.
+func searchTerms(query string) []string {
+	terms := make([]string, 0)
+	for _, token := range tokenize(query) {
+		if !contains(terms, token.Word) {
+			terms = append(terms, token.Word)
+		}
+	}
+	return terms
+}
+
+// Excerpt of the text around the first match of the terms, with the matches in <b> tags.
+// Empty if none of the terms is in the text.
+func highlight(text string, terms []string) string {
+	tokens := tokenize(text)
+	matches := make([]searchToken, 0)
+	for _, token := range tokens {
+		if contains(terms, token.Word) {
+			matches = append(matches, token)
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	// Start a few words before the first match, end after highlightLength runes, both at word boundaries.
+	start := 0
+	for _, token := range tokens {
+		if token.Start >= matches[0].Start {
+			break
+		}
+		if utf8.RuneCountInString(text[token.Start:matches[0].Start]) <= highlightContext {
+			start = token.Start
+			break
+		}
+	}
+	end := len(text)
+	for _, token := range tokens {
+		if token.Start > start && utf8.RuneCountInString(text[start:token.End]) > highlightLength {
+			end = token.Start
+			break
+		}
+	}
+
+	excerpt := strings.Builder{}
+	if start > 0 {
+		excerpt.WriteString("…")
+	}
+	position := start
+	for _, match := range matches {
+		if match.Start < start || match.End > end {
+			continue
+		}
+		excerpt.WriteString(html.EscapeString(text[position:match.Start]))
+		excerpt.WriteString("<b>" + html.EscapeString(text[match.Start:match.End]) + "</b>")
+		position = match.End
+	}
+	excerpt.WriteString(html.EscapeString(strings.TrimRightFunc(text[position:end], unicode.IsSpace)))
+	if end < len(text) {
+		excerpt.WriteString("…")
+	}
+	return excerpt.String()
+}
+
+// The highlights of all the fields matching the terms.
+func highlightFields(fields []SearchField, terms []string) map[string]string {
+	highlights := make(map[string]string)
+	for _, field := range fields {
+		if excerpt := highlight(field.Text, terms); excerpt != "" {
+			highlights[field.Name] = excerpt
+		}
+	}
+	return highlights
+}
+
+// Keeps the search index up to date with the changes. Delivered synchronously, so the index is current
+// when the response is written. Failures are only logged, the reindex route fixes them.
+type searchSubscriber struct {
+	Index               SearchIndex
+	SpeakerStorage      SpeakerStore
+	PresentationStorage PresentationStore
+	MeetupStorage       MeetupStore
+}
+
+func subscribeSearchIndex(Events *EventBus, Index SearchIndex, SpeakerStorage SpeakerStore, PresentationStorage PresentationStore, MeetupStorage MeetupStore) {
+	s := searchSubscriber{Index: Index, SpeakerStorage: SpeakerStorage, PresentationStorage: PresentationStorage, MeetupStorage: MeetupStorage}
+	Events.Subscribe("search", DeliverSync, s.handle)
+}
+
+func (s *searchSubscriber) handle(ctx context.Context, event Event) error {
+	switch e := event.(type) {
+	case SpeakerCreated:
+		return s.Index.IndexDocument(ctx, speakerSearchDocument(e.ID, &e.Speaker))
+	case SpeakerUpdated:
+		return s.Index.IndexDocument(ctx, speakerSearchDocument(e.ID, &e.Speaker))
+	case SpeakerDeleted:
+		return s.Index.RemoveDocument(ctx, searchKindSpeaker, e.ID)
+	case PresentationCreated:
+		return s.indexPresentation(ctx, e.ID, &e.Presentation)
+	case PresentationUpdated:
+		return s.indexPresentation(ctx, e.ID, &e.Presentation)
+	case PresentationDeleted:
+		return s.Index.RemoveDocument(ctx, searchKindPresentation, e.ID)
+	case MeetupCreated:
+		return s.Index.IndexDocument(ctx, meetupSearchDocument(e.ID, &e.Meetup))
+	case MeetupUpdated:
+		return s.Index.IndexDocument(ctx, meetupSearchDocument(e.ID, &e.Meetup))
+	case MeetupDeleted:
+		return s.Index.RemoveDocument(ctx, searchKindMeetup, e.ID)
+	case TrashRestored:
+		return s.reindex(ctx, e.Kind, e.ID)
+	case TrashPurged:
+		return s.Index.RemoveDocument(ctx, e.Kind, e.ID)
+	case ArchiveImported:
+		var err error
+		for _, ID := range e.Speakers {
+			err = firstError(err, s.reindex(ctx, searchKindSpeaker, ID))
+		}
+		for _, ID := range e.Presentations {
+			err = firstError(err, s.reindex(ctx, searchKindPresentation, ID))
+		}
+		for _, ID := range e.Meetups {
+			err = firstError(err, s.reindex(ctx, searchKindMeetup, ID))
+		}
+		return err
+	}
+	return nil
+}
+
+// Drafts are only seen by their owner, so they're kept out of the index until submitted.
+func (s *searchSubscriber) indexPresentation(ctx context.Context, ID int64, presentation *Presentation) error {
+	if presentation.Status == StatusDraft {
+		return s.Index.RemoveDocument(ctx, searchKindPresentation, ID)
+	}
+	return s.Index.IndexDocument(ctx, presentationSearchDocument(ID, presentation))
+}
+
+// Index the stored entity, or remove it from the index if it's gone.
+func (s *searchSubscriber) reindex(ctx context.Context, kind string, ID int64) error {
+	var document SearchDocument
+	var err error
+	switch kind {
+	case searchKindSpeaker:
+		var speaker Speaker
+		speaker, err = s.SpeakerStorage.GetSpeaker(ctx, ID)
+		document = speakerSearchDocument(ID, &speaker)
+	case searchKindPresentation:
+		var presentation Presentation
+		presentation, err = s.PresentationStorage.GetPresentation(ctx, ID)
+		if err == nil && presentation.Status == StatusDraft {
+			return s.Index.RemoveDocument(ctx, kind, ID)
+		}
+		document = presentationSearchDocument(ID, &presentation)
+	case searchKindMeetup:
+		var meetup Meetup
+		meetup, err = s.MeetupStorage.GetMeetup(ctx, ID)
+		document = meetupSearchDocument(ID, &meetup)
+	default:
+		return fmt.Errorf("unknown kind: %v", kind)
+	}
+	if err == datastore.ErrNoSuchEntity {
+		return s.Index.RemoveDocument(ctx, kind, ID)
+	}
+	if err != nil {
+		return err
+	}
+	return s.Index.IndexDocument(ctx, document)
+}
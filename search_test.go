@@ -0,0 +1,120 @@
+package MeetupRest
+
+import (
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestSearchSubscriber(t *testing.T) {
+	ctx := context.Background()
+	index := NewMemorySearchIndex()
+	store := NewMemoryStore()
+	events := NewEventBus()
+	subscribeSearchIndex(events, index, store, store, store)
+
+	speaker := Speaker{Name: "Jan", Surname: "Kowalski", Company: "Gophers", About: "Writes Go at work."}
+	speakerID, err := store.AddSpeaker(ctx, &speaker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.Publish(ctx, SpeakerCreated{ID: speakerID, Speaker: speaker})
+	goTalk := Presentation{Title: "Concurrency in Go", Description: "Channels & goroutines.", Speakers: []string{"Jan Kowalski"}}
+	goID, err := store.AddPresentation(ctx, &goTalk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rustTalk := Presentation{Title: "Rust for gophers", Description: "Ownership, compared to Go."}
+	rustID, err := store.AddPresentation(ctx, &rustTalk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.Publish(ctx, PresentationCreated{ID: goID, Presentation: goTalk}, PresentationCreated{ID: rustID, Presentation: rustTalk})
+
+	results, err := index.Search(ctx, "go", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 || results[0].Kind != searchKindPresentation || results[0].Key != goID {
+		t.Fatalf("The presentation with Go in the title should come first: %+v", results)
+	}
+	if results[0].Highlights["Title"] != "Concurrency in <b>Go</b>" {
+		t.Errorf("Title not highlighted: %v", results[0].Highlights["Title"])
+	}
+
+	results, err = index.Search(ctx, "GO gophers", searchKindPresentation, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != rustID {
+		t.Fatalf("Only the presentation matching both words should be found: %+v", results)
+	}
+
+	err = store.DeleteSpeaker(ctx, speakerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.Publish(ctx, SpeakerDeleted{ID: speakerID})
+	results, err = index.Search(ctx, "kowalski", searchKindSpeaker, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Deleted speaker should not be found: %+v", results)
+	}
+
+	err = store.UndeleteSpeaker(ctx, speakerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.Publish(ctx, TrashRestored{Kind: trashKindSpeaker, ID: speakerID})
+	results, err = index.Search(ctx, "kowalski", searchKindSpeaker, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != speakerID {
+		t.Errorf("Restored speaker should be found again: %+v", results)
+	}
+	draft := Presentation{Title: "Generics in Go", Status: StatusDraft}
+	draftID, err := store.AddPresentation(ctx, &draft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.Publish(ctx, PresentationCreated{ID: draftID, Presentation: draft})
+	results, err = index.Search(ctx, "generics", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Drafts should not be found: %+v", results)
+	}
+
+	draft.Status = StatusSubmitted
+	events.Publish(ctx, PresentationUpdated{ID: draftID, Presentation: draft})
+	results, err = index.Search(ctx, "generics", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Key != draftID {
+		t.Errorf("Submitted presentation should be found: %+v", results)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		text     string
+		terms    []string
+		expected string
+	}{
+		{"Go & <Rust>", []string{"rust"}, "Go &amp; &lt;<b>Rust</b>&gt;"},
+		{"Nothing here", []string{"go"}, ""},
+		{"This talk starts with a long introduction about the history of programming languages before it gets to Go, which it then compares to everything else.", []string{"go"},
+			"…the history of programming languages before it gets to <b>Go</b>, which it then compares to everything else."},
+	}
+
+	for _, c := range cases {
+		if excerpt := highlight(c.text, c.terms); excerpt != c.expected {
+			t.Errorf("Highlight of %v should be %v, got %v", c.text, c.expected, excerpt)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package MeetupRest
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate the fields of a struct according to their validate tags. The tag holds comma separated rules:
+//
+//	required  the field may not be empty (zero time, empty string or slice)
+//	min=N     minimum length of a string or minimum value of a number
+//	max=N     maximum length of a string or maximum value of a number
+//	email     the string, if not empty, has to be an email address
+//
+// All the failing fields are returned, so the client can show every problem at once.
+func validateStruct(v interface{}) []FieldError {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	fieldErrors := make([]FieldError, 0)
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			message := checkRule(value.Field(i), rule)
+			if message != "" {
+				fieldErrors = append(fieldErrors, FieldError{Field: field.Name, Message: message})
+				break
+			}
+		}
+	}
+
+	return fieldErrors
+}
+
+func checkRule(field reflect.Value, rule string) string {
+	name, param := rule, ""
+	if index := strings.Index(rule, "="); index != -1 {
+		name, param = rule[:index], rule[index+1:]
+	}
+
+	switch name {
+	case "required":
+		if isEmpty(field) {
+			return "This field is mandatory."
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("invalid %v rule: %v", name, rule))
+		}
+		size, unit := measure(field)
+		if name == "min" && size < limit {
+			return fmt.Sprintf("Must be at least %v%v.", param, unit)
+		}
+		if name == "max" && size > limit {
+			return fmt.Sprintf("Must be at most %v%v.", param, unit)
+		}
+	case "email":
+		if field.String() == "" {
+			return ""
+		}
+		address, err := mail.ParseAddress(field.String())
+		if err != nil || address.Address != field.String() {
+			return "Must be a valid email address."
+		}
+	default:
+		panic(fmt.Sprintf("unknown validation rule: %v", rule))
+	}
+	return ""
+}
+
+func isEmpty(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(field.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return field.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return field.IsNil()
+	}
+	if t, ok := field.Interface().(time.Time); ok {
+		return t.IsZero()
+	}
+	return false
+}
+
+// The size the min and max rules compare against, along with the unit to report.
+func measure(field reflect.Value) (float64, string) {
+	switch field.Kind() {
+	case reflect.String:
+		return float64(len([]rune(field.String()))), " characters"
+	case reflect.Slice:
+		return float64(field.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(field.Int()), ""
+	case reflect.Float32, reflect.Float64:
+		return field.Float(), ""
+	}
+	panic(fmt.Sprintf("min and max rules aren't supported on %v", field.Type()))
+}
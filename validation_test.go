@@ -0,0 +1,26 @@
+package MeetupRest
+
+import (
+	"testing"
+)
+
+func TestValidateSpeakerForm(t *testing.T) {
+	form := SpeakerForm{Name: "Jan", Surname: "", Email: "not an email", Company: "Company"}
+
+	fieldErrors := validateSpeakerForm(&form)
+
+	fields := make(map[string]bool)
+	for _, fieldError := range fieldErrors {
+		fields[fieldError.Field] = true
+	}
+	if len(fieldErrors) != 2 || !fields["Surname"] || !fields["Email"] {
+		t.Errorf("Expected errors on Surname and Email. Received: %v", fieldErrors)
+	}
+
+	form.Surname = "Kowalski"
+	form.Email = "jan@example.com"
+	fieldErrors = validateSpeakerForm(&form)
+	if len(fieldErrors) != 0 {
+		t.Errorf("Expected a valid form. Received: %v", fieldErrors)
+	}
+}